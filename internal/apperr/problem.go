@@ -0,0 +1,55 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// RequestIDHeader carries the request ID, which problem responses repeat so
+// that clients can quote it.
+const RequestIDHeader = "X-Request-ID"
+
+// Problem is an RFC 7807 problem details document. Code and RequestID are
+// extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Write sends err as application/problem+json. Errors that are not an
+// *Error, such as database driver errors, are logged and reported as a
+// generic internal error so that their text never reaches the client.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	requestID := w.Header().Get(RequestIDHeader)
+
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Code: CodeInternal, Message: "internal server error", Err: err}
+	}
+	status := e.Code.Status()
+	if status >= http.StatusInternalServerError {
+		log.Printf("request %s: %s %s: %v", requestID, r.Method, r.URL.Path, err)
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Instance:  r.URL.Path,
+		Code:      e.Code,
+		RequestID: requestID,
+		Errors:    e.Fields,
+	})
+}
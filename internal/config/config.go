@@ -2,18 +2,107 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/i18n"
+	"github.com/ScriptVandal/backend-go/internal/mail"
 )
 
 type Config struct {
 	Port             string
 	DatabaseURL      string
+	JSONWritable     bool
+	MigrateOnStart   bool
 	CORSOrigins      []string
 	JWTSecret        string
 	JWTRefreshSecret string
 	AccessTTL        time.Duration
 	RefreshTTL       time.Duration
+	AdminEmail       string
+	// WriteToken is the bearer token content writes need when authentication
+	// is not configured. JSON_WRITABLE requires it.
+	WriteToken string
+	// SchedulerInterval is how often scheduled posts are checked for publishing.
+	SchedulerInterval time.Duration
+	// SiteURL is the public front-end base URL, without a trailing slash.
+	// Feeds link to posts under it.
+	SiteURL   string
+	SiteTitle string
+	// PublicURL is the public base URL of this API, without a trailing
+	// slash. Feeds, the sitemap and robots.txt link to themselves under it,
+	// never under the Host of a request.
+	PublicURL string
+	// PostURLTemplate and ProjectURLTemplate give the front-end page of an
+	// item. {slug} and {id} are substituted; relative templates are
+	// resolved against SiteURL.
+	PostURLTemplate    string
+	ProjectURLTemplate string
+	// RobotsDisallow lists the paths robots.txt asks crawlers to skip.
+	RobotsDisallow []string
+	// AllowClientIDs lets create requests supply their own id, e.g. when
+	// importing content. Otherwise IDs are always generated by the server.
+	AllowClientIDs bool
+	// RequireIfMatch refuses PUT, PATCH and DELETE of content without an
+	// If-Match header (428), so clients cannot overwrite edits blindly.
+	RequireIfMatch bool
+	// TrashRetention is how long deleted content stays in the trash before
+	// it is purged for good. Zero keeps it until it is restored.
+	TrashRetention time.Duration
+	// TrashPurgeInterval is how often the trash is checked for expired items.
+	TrashPurgeInterval time.Duration
+	// TrustProxy takes client IPs from TrustProxyHeader, X-Forwarded-For or
+	// X-Real-IP. Enable it only behind a reverse proxy that sets that header.
+	TrustProxy       bool
+	TrustProxyHeader string
+	// Locales are the content locales. Items are stored in the default
+	// locale and may be translated into the others.
+	Locales i18n.Locales
+	// MessageRateLimit caps contact-form submissions per client IP in each
+	// MessageRateWindow. Zero disables the limit.
+	MessageRateLimit  int
+	MessageRateWindow time.Duration
+	// MessageMinDelay is how long the contact form must be open before it
+	// is sent; faster submissions are dropped as spam.
+	MessageMinDelay time.Duration
+	// MessageFormSecret signs contact-form tokens. If empty, a random key is
+	// generated at startup.
+	MessageFormSecret string
+	// MailDriver picks how mail is sent: "smtp", "outbox" (written to
+	// MailOutboxDir as .eml files, for development) or "none".
+	MailDriver    string
+	MailOutboxDir string
+	// SMTP is the mail server used by the smtp driver. Its From is MAIL_FROM.
+	SMTP mail.SMTPConfig
+	// MailNotifyTo receives notifications about new messages and users. It
+	// defaults to AdminEmail.
+	MailNotifyTo []string
+	// MailQueueInterval is how often the mail queue is checked for mail to
+	// retry; new mail is sent right away.
+	MailQueueInterval time.Duration
+	// MailMaxAttempts is how many times delivery is tried before a mail is
+	// marked failed.
+	MailMaxAttempts int
+	// RequireEmailVerification refuses logins until the user has followed
+	// the link in the verification mail.
+	RequireEmailVerification bool
+	// EmailVerifyTTL and PasswordResetTTL are how long the links mailed for
+	// email verification and password reset stay valid.
+	EmailVerifyTTL   time.Duration
+	PasswordResetTTL time.Duration
+	// EmailVerifyURLTemplate and PasswordResetURLTemplate give the front-end
+	// pages those links open. {token} is substituted; relative templates are
+	// resolved against SiteURL.
+	EmailVerifyURLTemplate   string
+	PasswordResetURLTemplate string
+	// LoginMaxFailures is how many failed logins in a row lock an account
+	// for LoginLockout; earlier failures slow it down exponentially.
+	// LoginIPMaxFailures does the same per client IP. Zero turns the
+	// protection off.
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+	LoginLockout       time.Duration
 }
 
 func Load() *Config {
@@ -36,15 +125,94 @@ func Load() *Config {
 	accessTTL := parseDuration(os.Getenv("ACCESS_TTL"), 15*time.Minute)
 	refreshTTL := parseDuration(os.Getenv("REFRESH_TTL"), 168*time.Hour) // 7 days
 
+	siteURL := strings.TrimRight(os.Getenv("SITE_URL"), "/")
+	if siteURL == "" {
+		siteURL = "http://localhost:3000"
+	}
+	publicURL := strings.TrimRight(os.Getenv("PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = "http://localhost:" + port
+	}
+
+	// An empty ROBOTS_DISALLOW means the default; "none" disallows nothing.
+	robotsDisallow := parseList(envOr("ROBOTS_DISALLOW", "/api/"))
+	if len(robotsDisallow) == 1 && robotsDisallow[0] == "none" {
+		robotsDisallow = nil
+	}
+
+	adminEmail := strings.ToLower(strings.TrimSpace(os.Getenv("ADMIN_EMAIL")))
+
 	return &Config{
-		Port:             port,
-		DatabaseURL:      os.Getenv("DATABASE_URL"),
-		CORSOrigins:      origins,
-		JWTSecret:        os.Getenv("JWT_SECRET"),
-		JWTRefreshSecret: os.Getenv("JWT_REFRESH_SECRET"),
-		AccessTTL:        accessTTL,
-		RefreshTTL:       refreshTTL,
+		Port:               port,
+		DatabaseURL:        os.Getenv("DATABASE_URL"),
+		JSONWritable:       parseBool(os.Getenv("JSON_WRITABLE"), false),
+		WriteToken:         os.Getenv("WRITE_TOKEN"),
+		MigrateOnStart:     parseBool(os.Getenv("MIGRATE_ON_START"), true),
+		CORSOrigins:        origins,
+		JWTSecret:          os.Getenv("JWT_SECRET"),
+		JWTRefreshSecret:   os.Getenv("JWT_REFRESH_SECRET"),
+		AccessTTL:          accessTTL,
+		RefreshTTL:         refreshTTL,
+		AdminEmail:         adminEmail,
+		SchedulerInterval:  parsePositiveDuration(os.Getenv("SCHEDULER_INTERVAL"), time.Minute),
+		SiteURL:            siteURL,
+		SiteTitle:          envOr("SITE_TITLE", "Blog"),
+		PublicURL:          publicURL,
+		PostURLTemplate:    envOr("POST_URL_TEMPLATE", "/posts/{slug}"),
+		ProjectURLTemplate: envOr("PROJECT_URL_TEMPLATE", "/projects/{slug}"),
+		RobotsDisallow:     robotsDisallow,
+		AllowClientIDs:     parseBool(os.Getenv("ALLOW_CLIENT_IDS"), false),
+		RequireIfMatch:     parseBool(os.Getenv("REQUIRE_IF_MATCH"), false),
+		TrashRetention:     time.Duration(parseInt(os.Getenv("TRASH_RETENTION_DAYS"), 30)) * 24 * time.Hour,
+		TrashPurgeInterval: parsePositiveDuration(os.Getenv("TRASH_PURGE_INTERVAL"), time.Hour),
+		TrustProxy:         parseBool(os.Getenv("TRUST_PROXY"), false),
+		TrustProxyHeader:   envOr("TRUST_PROXY_HEADER", "X-Forwarded-For"),
+		Locales:            i18n.New(envOr("DEFAULT_LOCALE", "ru"), parseList(envOr("LOCALES", "ru,en"))),
+		MessageRateLimit:   parseInt(os.Getenv("MESSAGE_RATE_LIMIT"), 5),
+		MessageRateWindow:  parseDuration(os.Getenv("MESSAGE_RATE_WINDOW"), time.Hour),
+		MessageMinDelay:    parseDuration(os.Getenv("MESSAGE_MIN_DELAY"), 3*time.Second),
+		MessageFormSecret:  os.Getenv("MESSAGE_FORM_SECRET"),
+		MailDriver:         strings.ToLower(envOr("MAIL_DRIVER", "outbox")),
+		MailOutboxDir:      envOr("MAIL_OUTBOX_DIR", "data/outbox"),
+		SMTP: mail.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     parseInt(os.Getenv("SMTP_PORT"), 587),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     envOr("MAIL_FROM", "noreply@localhost"),
+			Security: strings.ToLower(envOr("SMTP_SECURITY", mail.SecurityStartTLS)),
+			Timeout:  parseDuration(os.Getenv("SMTP_TIMEOUT"), 30*time.Second),
+		},
+		MailNotifyTo:             parseList(envOr("MAIL_NOTIFY_TO", adminEmail)),
+		MailQueueInterval:        parsePositiveDuration(os.Getenv("MAIL_QUEUE_INTERVAL"), time.Minute),
+		MailMaxAttempts:          parseInt(os.Getenv("MAIL_MAX_ATTEMPTS"), 8),
+		RequireEmailVerification: parseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION"), false),
+		EmailVerifyTTL:           parseDuration(os.Getenv("EMAIL_VERIFY_TTL"), 48*time.Hour),
+		PasswordResetTTL:         parseDuration(os.Getenv("PASSWORD_RESET_TTL"), time.Hour),
+		EmailVerifyURLTemplate:   envOr("EMAIL_VERIFY_URL_TEMPLATE", "/verify-email?token={token}"),
+		PasswordResetURLTemplate: envOr("PASSWORD_RESET_URL_TEMPLATE", "/reset-password?token={token}"),
+		LoginMaxFailures:         parseInt(os.Getenv("LOGIN_MAX_FAILURES"), 10),
+		LoginIPMaxFailures:       parseInt(os.Getenv("LOGIN_IP_MAX_FAILURES"), 50),
+		LoginLockout:             parseDuration(os.Getenv("LOGIN_LOCKOUT"), 15*time.Minute),
+	}
+}
+
+func envOr(name, defaultValue string) string {
+	if s := os.Getenv(name); s != "" {
+		return s
+	}
+	return defaultValue
+}
+
+// parseList splits a comma-separated list, dropping empty items.
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
 
 func parseDuration(s string, defaultDuration time.Duration) time.Duration {
@@ -57,3 +225,35 @@ func parseDuration(s string, defaultDuration time.Duration) time.Duration {
 	}
 	return d
 }
+
+// parsePositiveDuration is parseDuration for intervals, which must be
+// positive: zero and negative values fall back to defaultDuration.
+func parsePositiveDuration(s string, defaultDuration time.Duration) time.Duration {
+	if d := parseDuration(s, defaultDuration); d > 0 {
+		return d
+	}
+	return defaultDuration
+}
+
+func parseBool(s string, defaultValue bool) bool {
+	if s == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return defaultValue
+	}
+	return b
+}
+
+// parseInt parses a non-negative integer, falling back to defaultValue.
+func parseInt(s string, defaultValue int) int {
+	if s == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return defaultValue
+	}
+	return n
+}
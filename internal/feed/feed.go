@@ -0,0 +1,206 @@
+// Package feed encodes syndication feeds as RSS 2.0, Atom 1.0 and JSON
+// Feed 1.1.
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+// Content types of the encoded feeds.
+const (
+	ContentTypeRSS  = "application/rss+xml; charset=utf-8"
+	ContentTypeAtom = "application/atom+xml; charset=utf-8"
+	ContentTypeJSON = "application/feed+json; charset=utf-8"
+)
+
+// Feed is the format-independent description of a feed.
+type Feed struct {
+	Title       string
+	Description string
+	// Link is the HTML page the feed belongs to, SelfURL the feed itself.
+	Link    string
+	SelfURL string
+	Updated time.Time
+	Items   []Item
+}
+
+type Item struct {
+	// ID is a permanent identifier that survives changes to Link.
+	ID          string
+	Title       string
+	Link        string
+	ContentHTML string
+	Tags        []string
+	Published   time.Time
+	Updated     time.Time
+}
+
+// RSS encodes f as RSS 2.0.
+func RSS(f Feed) ([]byte, error) {
+	type guid struct {
+		IsPermaLink bool   `xml:"isPermaLink,attr"`
+		Value       string `xml:",chardata"`
+	}
+	type item struct {
+		Title       string   `xml:"title"`
+		Link        string   `xml:"link"`
+		GUID        guid     `xml:"guid"`
+		PubDate     string   `xml:"pubDate"`
+		Categories  []string `xml:"category"`
+		Description string   `xml:"description"`
+	}
+	type atomLink struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	}
+	type channel struct {
+		Title         string   `xml:"title"`
+		Link          string   `xml:"link"`
+		Description   string   `xml:"description"`
+		LastBuildDate string   `xml:"lastBuildDate"`
+		Self          atomLink `xml:"atom:link"`
+		Items         []item   `xml:"item"`
+	}
+	type rss struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string   `xml:"version,attr"`
+		AtomNS  string   `xml:"xmlns:atom,attr"`
+		Channel channel  `xml:"channel"`
+	}
+
+	doc := rss{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: channel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+			Self:          atomLink{Href: f.SelfURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	for _, it := range f.Items {
+		doc.Channel.Items = append(doc.Channel.Items, item{
+			Title:       it.Title,
+			Link:        it.Link,
+			GUID:        guid{Value: it.ID},
+			PubDate:     it.Published.UTC().Format(time.RFC1123Z),
+			Categories:  it.Tags,
+			Description: it.ContentHTML,
+		})
+	}
+	return encodeXML(doc)
+}
+
+// Atom encodes f as Atom 1.0.
+func Atom(f Feed) ([]byte, error) {
+	type link struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr,omitempty"`
+		Type string `xml:"type,attr,omitempty"`
+	}
+	type category struct {
+		Term string `xml:"term,attr"`
+	}
+	type content struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:",chardata"`
+	}
+	type entry struct {
+		ID         string     `xml:"id"`
+		Title      string     `xml:"title"`
+		Link       link       `xml:"link"`
+		Published  string     `xml:"published"`
+		Updated    string     `xml:"updated"`
+		Categories []category `xml:"category"`
+		Content    content    `xml:"content"`
+	}
+	type feed struct {
+		XMLName  xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID       string   `xml:"id"`
+		Title    string   `xml:"title"`
+		Subtitle string   `xml:"subtitle,omitempty"`
+		Updated  string   `xml:"updated"`
+		Links    []link   `xml:"link"`
+		Entries  []entry  `xml:"entry"`
+	}
+
+	doc := feed{
+		ID:       f.SelfURL,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  f.Updated.UTC().Format(time.RFC3339),
+		Links: []link{
+			{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, it := range f.Items {
+		e := entry{
+			ID:        it.ID,
+			Title:     it.Title,
+			Link:      link{Href: it.Link, Rel: "alternate", Type: "text/html"},
+			Published: it.Published.UTC().Format(time.RFC3339),
+			Updated:   it.Updated.UTC().Format(time.RFC3339),
+			Content:   content{Type: "html", Value: it.ContentHTML},
+		}
+		for _, tag := range it.Tags {
+			e.Categories = append(e.Categories, category{Term: tag})
+		}
+		doc.Entries = append(doc.Entries, e)
+	}
+	return encodeXML(doc)
+}
+
+// JSON encodes f as JSON Feed 1.1.
+func JSON(f Feed) ([]byte, error) {
+	type item struct {
+		ID            string   `json:"id"`
+		URL           string   `json:"url"`
+		Title         string   `json:"title"`
+		ContentHTML   string   `json:"content_html"`
+		DatePublished string   `json:"date_published"`
+		DateModified  string   `json:"date_modified"`
+		Tags          []string `json:"tags,omitempty"`
+	}
+	type feed struct {
+		Version     string `json:"version"`
+		Title       string `json:"title"`
+		Description string `json:"description,omitempty"`
+		HomePageURL string `json:"home_page_url"`
+		FeedURL     string `json:"feed_url"`
+		Items       []item `json:"items"`
+	}
+
+	doc := feed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		Description: f.Description,
+		HomePageURL: f.Link,
+		FeedURL:     f.SelfURL,
+		Items:       []item{},
+	}
+	for _, it := range f.Items {
+		doc.Items = append(doc.Items, item{
+			ID:            it.ID,
+			URL:           it.Link,
+			Title:         it.Title,
+			ContentHTML:   it.ContentHTML,
+			DatePublished: it.Published.UTC().Format(time.RFC3339),
+			DateModified:  it.Updated.UTC().Format(time.RFC3339),
+			Tags:          it.Tags,
+		})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func encodeXML(doc any) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
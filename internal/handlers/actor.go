@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ScriptVandal/backend-go/internal/middleware"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// actorOf describes who made r, for the audit log and revision history.
+func actorOf(r *http.Request) models.Actor {
+	return models.Actor{
+		UserID:    middleware.GetUserID(r),
+		IP:        middleware.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/services"
+)
+
+type AuditHandler struct {
+	svc *services.AuditService
+}
+
+func NewAuditHandler(svc *services.AuditService) *AuditHandler {
+	return &AuditHandler{svc: svc}
+}
+
+// List handles GET /api/audit with the usual paging parameters and the
+// filters actor, action, entity_type, entity_id, since and until.
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+	q, err := parseListQuery(r, "actor", "action", "entity_type", "entity_id", "since", "until")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page, err := h.svc.List(q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
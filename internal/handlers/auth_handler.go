@@ -2,8 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"maps"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/ScriptVandal/backend-go/internal/apperr"
 	"github.com/ScriptVandal/backend-go/internal/models"
 	"github.com/ScriptVandal/backend-go/internal/services"
 )
@@ -18,31 +25,36 @@ func NewAuthHandler(authService *services.AuthService) *AuthHandler {
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeError(w, r, apperr.MethodNotAllowed())
 		return
 	}
 
 	var req models.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if req.Email == "" || req.Password == "" {
-		http.Error(w, "email and password are required", http.StatusBadRequest)
+	if fields := requiredFields(map[string]string{"email": req.Email, "password": req.Password}); fields != nil {
+		writeError(w, r, apperr.Validation(fields...))
 		return
 	}
 
-	user, err := h.authService.Register(req.Email, req.Password)
+	user, err := h.authService.Register(req.Email, req.Password, actorOf(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	// Auto-login after registration
-	_, accessToken, refreshToken, err := h.authService.Login(req.Email, req.Password)
+	// Auto-login after registration, unless the email must be verified first
+	_, accessToken, refreshToken, err := h.authService.Login(req.Email, req.Password, actorOf(r))
+	if errors.Is(err, services.ErrEmailNotVerified) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.AuthResponse{User: *user, EmailVerificationRequired: true})
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeLoginError(w, r, err)
 		return
 	}
 
@@ -58,24 +70,24 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeError(w, r, apperr.MethodNotAllowed())
 		return
 	}
 
 	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if req.Email == "" || req.Password == "" {
-		http.Error(w, "email and password are required", http.StatusBadRequest)
+	if fields := requiredFields(map[string]string{"email": req.Email, "password": req.Password}); fields != nil {
+		writeError(w, r, apperr.Validation(fields...))
 		return
 	}
 
-	user, accessToken, refreshToken, err := h.authService.Login(req.Email, req.Password)
+	user, accessToken, refreshToken, err := h.authService.Login(req.Email, req.Password, actorOf(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeLoginError(w, r, err)
 		return
 	}
 
@@ -91,29 +103,30 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeError(w, r, apperr.MethodNotAllowed())
 		return
 	}
 
 	var req models.RefreshRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	if req.RefreshToken == "" {
-		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		writeError(w, r, apperr.Validation(apperr.Field("refresh_token", "is required")))
 		return
 	}
 
-	accessToken, err := h.authService.Refresh(req.RefreshToken)
+	accessToken, refreshToken, err := h.authService.Refresh(req.RefreshToken, actorOf(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, r, err)
 		return
 	}
 
 	response := map[string]string{
-		"access_token": accessToken,
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -122,25 +135,179 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeError(w, r, apperr.MethodNotAllowed())
 		return
 	}
 
 	var req models.RefreshRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	if req.RefreshToken == "" {
-		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		writeError(w, r, apperr.Validation(apperr.Field("refresh_token", "is required")))
+		return
+	}
+
+	if err := h.authService.Logout(req.RefreshToken, actorOf(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail handles POST /api/auth/verify with the token from a
+// verification mail.
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+
+	var req models.VerifyEmailRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if req.Token == "" {
+		writeError(w, r, apperr.Validation(apperr.Field("token", "is required")))
+		return
+	}
+
+	user, err := h.authService.VerifyEmail(req.Token, actorOf(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// ResendVerification handles POST /api/auth/verify/resend. The response is
+// the same whether or not the address belongs to an unverified user.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	h.mailRequest(w, r, func(email string) {
+		h.authService.ResendVerification(email)
+	})
+}
+
+// ForgotPassword handles POST /api/auth/forgot. The response is the same
+// whether or not the address belongs to a user.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	h.mailRequest(w, r, func(email string) {
+		h.authService.ForgotPassword(email, actorOf(r))
+	})
+}
+
+// mailRequest handles a request to mail a link to an address, answering 202
+// whatever send does with it.
+func (h *AuthHandler) mailRequest(w http.ResponseWriter, r *http.Request, send func(email string)) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+
+	var req models.EmailRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if req.Email == "" {
+		writeError(w, r, apperr.Validation(apperr.Field("email", "is required")))
 		return
 	}
 
-	if err := h.authService.Logout(req.RefreshToken); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	send(req.Email)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+// ResetPassword handles POST /api/auth/reset with the token from a password
+// reset mail and the new password.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if fields := requiredFields(map[string]string{"token": req.Token, "password": req.Password}); fields != nil {
+		writeError(w, r, apperr.Validation(fields...))
+		return
+	}
+
+	if err := h.authService.ResetPassword(req.Token, req.Password, actorOf(r)); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// writeLoginError writes an error from Login, telling throttled clients
+// when to try again.
+func writeLoginError(w http.ResponseWriter, r *http.Request, err error) {
+	var throttled *services.ThrottledError
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	}
+	writeError(w, r, err)
+}
+
+// requiredFields returns an error for each empty value, in field name order.
+func requiredFields(values map[string]string) []apperr.FieldError {
+	var fields []apperr.FieldError
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if values[name] == "" {
+			fields = append(fields, apperr.Field(name, "is required"))
+		}
+	}
+	return fields
+}
+
+// SetRole handles PUT /api/users/{id}/role. Only admins reach this handler.
+func (h *AuthHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/users/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "role" {
+		writeError(w, r, apperr.NotFound("not found"))
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !models.ValidRole(req.Role) {
+		writeError(w, r, apperr.Validation(apperr.Field("role", "must be one of admin, editor, viewer")))
+		return
+	}
+
+	user, err := h.authService.SetUserRole(parts[0], req.Role, actorOf(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// writeCached serves a generated document with an ETag derived from its
+// content and, unless modTime is zero, a Last-Modified header. Conditional
+// requests are answered with 304 Not Modified.
+func writeCached(w http.ResponseWriter, r *http.Request, contentType string, modTime time.Time, body []byte) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(body))
+}
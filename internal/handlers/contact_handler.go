@@ -5,27 +5,34 @@ import (
     "net/http"
     "strings"
 
+    "github.com/ScriptVandal/backend-go/internal/apperr"
     "github.com/ScriptVandal/backend-go/internal/models"
-    "github.com/ScriptVandal/backend-go/internal/repositories"
     "github.com/ScriptVandal/backend-go/internal/services"
 )
 
 type ContactHandler struct {
     svc *services.ContactService
+    // requireIfMatch refuses writes that do not send If-Match.
+    requireIfMatch bool
 }
 
-func NewContactHandler(svc *services.ContactService) *ContactHandler {
-    return &ContactHandler{svc: svc}
+func NewContactHandler(svc *services.ContactService, requireIfMatch bool) *ContactHandler {
+    return &ContactHandler{svc: svc, requireIfMatch: requireIfMatch}
 }
 
 func (h *ContactHandler) List(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
         return
     }
-    items, err := h.svc.ListContacts()
+    q, err := parseListQuery(r)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, r, err)
+        return
+    }
+    items, err := h.svc.ListContacts(q)
+    if err != nil {
+        writeError(w, r, err)
         return
     }
     w.Header().Set("Content-Type", "application/json")
@@ -38,7 +45,15 @@ func (h *ContactHandler) HandleItem(w http.ResponseWriter, r *http.Request) {
     id := strings.Split(path, "/")[0]
 
     if id == "" {
-        http.Error(w, "ID is required", http.StatusBadRequest)
+        writeError(w, r, apperr.BadRequest("id is required"))
+        return
+    }
+    if strings.TrimPrefix(path, id) == "/restore" {
+        if r.Method != http.MethodPost {
+            writeError(w, r, apperr.MethodNotAllowed())
+            return
+        }
+        h.Restore(w, r, id)
         return
     }
 
@@ -47,84 +62,117 @@ func (h *ContactHandler) HandleItem(w http.ResponseWriter, r *http.Request) {
         h.Get(w, r, id)
     case http.MethodPut:
         h.Update(w, r, id)
+    case http.MethodPatch:
+        h.Patch(w, r, id)
     case http.MethodDelete:
         h.Delete(w, r, id)
     default:
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
     }
 }
 
 func (h *ContactHandler) Get(w http.ResponseWriter, r *http.Request, id string) {
     item, err := h.svc.GetContact(id)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, r, err)
         return
     }
     if item == nil {
-        http.Error(w, "contact not found", http.StatusNotFound)
+        writeError(w, r, apperr.NotFound("contact not found"))
         return
     }
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(item)
+    if notModified(w, r, versionETag(item.Version)) {
+        return
+    }
+    writeItem(w, http.StatusOK, versionETag(item.Version), item)
 }
 
 func (h *ContactHandler) Create(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
         return
     }
 
     var contact models.Contact
-    if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
-        http.Error(w, "invalid request body", http.StatusBadRequest)
+    if err := decodeJSON(w, r, &contact); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    if err := h.svc.CreateContact(&contact); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.svc.CreateContact(&contact, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(contact)
+    writeItem(w, http.StatusCreated, versionETag(contact.Version), contact)
 }
 
 func (h *ContactHandler) Update(w http.ResponseWriter, r *http.Request, id string) {
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
     var contact models.Contact
-    if err := json.NewDecoder(r.Body).Decode(&contact); err != nil {
-        http.Error(w, "invalid request body", http.StatusBadRequest)
+    if err := decodeJSON(w, r, &contact); err != nil {
+        writeError(w, r, err)
         return
     }
 
     contact.ID = id
 
-    if err := h.svc.UpdateContact(&contact); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.svc.UpdateContact(&contact, ifMatch, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(contact)
+    writeItem(w, http.StatusOK, versionETag(contact.Version), contact)
+}
+
+// Patch applies a JSON Merge Patch or JSON Patch to the contact.
+func (h *ContactHandler) Patch(w http.ResponseWriter, r *http.Request, id string) {
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    patch, err := readPatch[models.Contact](w, r)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
+    item, err := h.svc.PatchContact(id, ifMatch, actorOf(r), patch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
+    writeItem(w, http.StatusOK, versionETag(item.Version), item)
 }
 
 func (h *ContactHandler) Delete(w http.ResponseWriter, r *http.Request, id string) {
-    if err := h.svc.DeleteContact(id); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    if err := h.svc.DeleteContact(id, ifMatch, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
     w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore serves POST /api/contacts/{id}/restore, taking the contact out of the
+// trash.
+func (h *ContactHandler) Restore(w http.ResponseWriter, r *http.Request, id string) {
+    item, err := h.svc.RestoreContact(id, actorOf(r))
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    writeItem(w, http.StatusOK, versionETag(item.Version), item)
+}
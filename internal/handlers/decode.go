@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+)
+
+// maxBodyBytes limits the size of JSON request bodies.
+const maxBodyBytes = 1 << 20
+
+// decodeJSON reads a single JSON value from the request body into v,
+// rejecting unknown fields, trailing data and bodies over maxBodyBytes.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	return decodeStrict(http.MaxBytesReader(w, r.Body, maxBodyBytes), v)
+}
+
+// decodeStrict is decodeJSON for an arbitrary reader.
+func decodeStrict(rd io.Reader, v any) error {
+	dec := json.NewDecoder(rd)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return apperr.BadRequest("request body must contain a single JSON value")
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var maxErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &maxErr):
+		return apperr.New(apperr.CodePayloadTooLarge, fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit))
+	case errors.As(err, &typeErr):
+		return apperr.Validation(apperr.Field(typeErr.Field, "must be of type "+typeErr.Type.String()))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return apperr.BadRequest("request body is not valid JSON")
+	case errors.Is(err, io.EOF):
+		return apperr.BadRequest("request body is empty")
+	}
+	// encoding/json has no typed error for unknown fields.
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return apperr.Validation(apperr.Field(strings.Trim(name, `"`), "is not a known field"))
+	}
+	return apperr.BadRequest("invalid request body")
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+)
+
+// writeError sends err as an RFC 7807 problem. Domain errors keep their code
+// and message; anything else becomes a generic internal error.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apperr.Write(w, r, err)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// versionETag is the entity tag of an item at version, as stored: writes
+// answer with it.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// localizedETag is the entity tag of an item at version as read in locale,
+// with or without its translations. Each of these is a different body, so a
+// 304 must not carry one over to another.
+func localizedETag(version int, locale string, translations bool) string {
+	tag := strconv.Itoa(version) + "-" + locale
+	if translations {
+		tag += "+translations"
+	}
+	return `"` + tag + `"`
+}
+
+// writeItem writes item as JSON with etag.
+func writeItem(w http.ResponseWriter, status int, etag string, item any) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(item)
+}
+
+// notModified answers 304 and returns true if the request's If-None-Match
+// matches etag, the tag of the current representation.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// parseIfMatch reads the If-Match precondition of a write. When required is
+// set, a request without If-Match is refused with 428 Precondition Required.
+// Both version tags and localized tags name the version they were read at.
+// Weak or malformed tags never match, as RFC 9110 asks for a strong
+// comparison.
+func parseIfMatch(r *http.Request, required bool) (models.IfMatch, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if required {
+			return models.IfMatch{}, apperr.New(apperr.CodePreconditionRequired, "If-Match header is required")
+		}
+		return models.IfMatch{}, nil
+	}
+	m := models.IfMatch{Enforced: true}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return models.IfMatch{}, nil
+		}
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+		if strings.Trim(version, "0123456789") != "" {
+			continue
+		}
+		if version, err := strconv.Atoi(version); err == nil {
+			m.Versions = append(m.Versions, version)
+		}
+	}
+	return m, nil
+}
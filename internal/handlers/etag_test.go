@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+)
+
+func TestNotModifiedComparesRepresentations(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch, etag string
+		want              bool
+	}{
+		{`"3-en"`, localizedETag(3, "en", false), true},
+		{`W/"3-en"`, localizedETag(3, "en", false), true},
+		{`"3-ru", "3-en"`, localizedETag(3, "en", false), true},
+		{`*`, localizedETag(3, "en", false), true},
+		{`"3-en"`, localizedETag(3, "ru", false), false},
+		{`"3-en"`, localizedETag(3, "en", true), false},
+		{`"3-en+translations"`, localizedETag(3, "en", false), false},
+		{`"3"`, localizedETag(3, "en", false), false},
+		{`"2-en"`, localizedETag(3, "en", false), false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/posts/p", nil)
+		r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		w := httptest.NewRecorder()
+		if got := notModified(w, r, tt.etag); got != tt.want {
+			t.Errorf("If-None-Match %s against %s: notModified = %v, want %v", tt.ifNoneMatch, tt.etag, got, tt.want)
+		}
+		if tt.want && w.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: status %d, want 304", tt.ifNoneMatch, w.Code)
+		}
+	}
+}
+
+// TestParseIfMatch checks which If-Match headers let a write of an item at
+// version 3 through. A parsed header that does not allow it ends in 412.
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name, header string
+		required     bool
+		status       int // of the parse error, 0 if none
+		allows       bool
+	}{
+		{"absent, optional", "", false, 0, true},
+		{"absent, required", "", true, http.StatusPreconditionRequired, false},
+		{"version tag", `"3"`, true, 0, true},
+		{"localized tag", `"3-en"`, true, 0, true},
+		{"localized tag with translations", `"3-ru+translations"`, true, 0, true},
+		{"one of several", `"1", "3-en"`, true, 0, true},
+		{"any", `*`, true, 0, true},
+		{"stale version", `"2"`, true, 0, false},
+		{"stale localized tag", `"2-en"`, false, 0, false},
+		{"weak tag", `W/"3"`, true, 0, false},
+		{"unquoted", `3`, true, 0, false},
+		{"not a version", `"abc"`, true, 0, false},
+		{"signed version", `"+3"`, true, 0, false},
+		{"empty tag", `""`, true, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/api/posts/p", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			m, err := parseIfMatch(r, tt.required)
+			if tt.status != 0 {
+				var e *apperr.Error
+				if !errors.As(err, &e) || e.Code.Status() != tt.status {
+					t.Fatalf("error = %v, want status %d", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Allows(3); got != tt.allows {
+				t.Errorf("Allows(3) = %v, want %v (parsed %+v)", got, tt.allows, m)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/feed"
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/services"
+)
+
+// feedItems is how many of the latest posts a feed carries.
+const feedItems = 20
+
+type FeedHandler struct {
+	svc       *services.PostService
+	links     *SiteLinks
+	siteTitle string
+}
+
+func NewFeedHandler(svc *services.PostService, links *SiteLinks, siteTitle string) *FeedHandler {
+	return &FeedHandler{svc: svc, links: links, siteTitle: siteTitle}
+}
+
+// Serve handles GET /feeds/posts.{rss,atom,json} and
+// /feeds/tags/{tag}.{rss,atom,json}.
+func (h *FeedHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/feeds/")
+	ext := path.Ext(name)
+	name = strings.TrimSuffix(name, ext)
+
+	q := models.ListQuery{Limit: feedItems, Sort: "-published_at", PublishedOnly: true, Filters: map[string]string{}}
+	title := h.siteTitle
+	switch {
+	case name == "posts":
+	case strings.HasPrefix(name, "tags/") && len(name) > len("tags/"):
+		tag := strings.TrimPrefix(name, "tags/")
+		q.Filters["tag"] = tag
+		title = h.siteTitle + ": " + tag
+	default:
+		writeError(w, r, apperr.NotFound("not found"))
+		return
+	}
+
+	var encode func(feed.Feed) ([]byte, error)
+	var contentType string
+	switch ext {
+	case ".rss":
+		encode, contentType = feed.RSS, feed.ContentTypeRSS
+	case ".atom":
+		encode, contentType = feed.Atom, feed.ContentTypeAtom
+	case ".json":
+		encode, contentType = feed.JSON, feed.ContentTypeJSON
+	default:
+		writeError(w, r, apperr.NotFound("not found"))
+		return
+	}
+
+	page, err := h.svc.ListPosts(q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	f := feed.Feed{
+		Title:   title,
+		Link:    h.links.Home(),
+		SelfURL: h.links.API(r.URL.Path),
+	}
+	for _, p := range page.Items {
+		published := *p.PublishedAt
+		updated := published
+		if p.UpdatedAt.After(updated) {
+			updated = p.UpdatedAt
+		}
+		if updated.After(f.Updated) {
+			f.Updated = updated
+		}
+		f.Items = append(f.Items, feed.Item{
+			ID:          h.entryID(p),
+			Title:       p.Title,
+			Link:        h.links.Post(p.ID, p.Slug),
+			ContentHTML: p.ContentHTML,
+			Tags:        p.Tags,
+			Published:   published,
+			Updated:     updated,
+		})
+	}
+	lastModified := f.Updated
+	if f.Updated.IsZero() {
+		f.Updated = time.Unix(0, 0)
+	}
+
+	body, err := encode(f)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeCached(w, r, contentType, lastModified, body)
+}
+
+// entryID returns a tag URI (RFC 4151) for a post, which stays the same when
+// the post's slug or the site's URL scheme changes.
+func (h *FeedHandler) entryID(p models.Post) string {
+	host := h.links.Home()
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	return "tag:" + host + "," + p.PublishedAt.UTC().Format("2006-01-02") + ":posts/" + p.ID
+}
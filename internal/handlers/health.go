@@ -1,10 +1,14 @@
 package handlers
 
-import "net/http"
+import (
+    "net/http"
+
+    "github.com/ScriptVandal/backend-go/internal/apperr"
+)
 
 func Health(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
         return
     }
     w.Write([]byte("ok"))
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SiteLinks builds front-end URLs of posts and projects from the configured
+// templates.
+type SiteLinks struct {
+	base    string
+	api     string
+	post    string
+	project string
+}
+
+func NewSiteLinks(base, api, postTemplate, projectTemplate string) *SiteLinks {
+	return &SiteLinks{base: base, api: api, post: postTemplate, project: projectTemplate}
+}
+
+// Home returns the front-end base URL.
+func (l *SiteLinks) Home() string {
+	return l.base
+}
+
+// API returns the public URL of path on this API. It is configured rather
+// than taken from the request, whose Host a client can forge into responses
+// that shared caches keep.
+func (l *SiteLinks) API(path string) string {
+	return l.api + path
+}
+
+func (l *SiteLinks) Post(id, slug string) string {
+	return l.expand(l.post, id, slug)
+}
+
+func (l *SiteLinks) Project(id, slug string) string {
+	return l.expand(l.project, id, slug)
+}
+
+func (l *SiteLinks) expand(template, id, slug string) string {
+	link := strings.NewReplacer("{id}", url.PathEscape(id), "{slug}", url.PathEscape(slug)).Replace(template)
+	if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		return link
+	}
+	return l.base + "/" + strings.TrimPrefix(link, "/")
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// parseListQuery reads limit, cursor and sort from the query string, plus the
+// given filter parameters.
+func parseListQuery(r *http.Request, filters ...string) (models.ListQuery, error) {
+	values := r.URL.Query()
+	q := models.ListQuery{
+		Cursor:  values.Get("cursor"),
+		Sort:    values.Get("sort"),
+		Filters: map[string]string{},
+	}
+	if s := values.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return q, apperr.Validation(apperr.Field("limit", "must be a positive integer"))
+		}
+		q.Limit = limit
+	}
+	for _, name := range filters {
+		if v := values.Get(name); v != "" {
+			q.Filters[name] = v
+		}
+	}
+	return q, nil
+}
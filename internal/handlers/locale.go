@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ScriptVandal/backend-go/internal/i18n"
+	"github.com/ScriptVandal/backend-go/internal/middleware"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// requestLocales returns the locales r asks for, most preferred first: the
+// lang query parameter, then Accept-Language.
+func requestLocales(r *http.Request) []string {
+	return i18n.Preferences(r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+}
+
+// showTranslations reports whether the caller gets the translations of
+// localized items, so that editors can save them back.
+func showTranslations(r *http.Request) bool {
+	return middleware.HasRole(r, models.RoleAdmin, models.RoleEditor)
+}
+
+// setContentLanguage sets Content-Language to the locales a response is in
+// and marks it as varying with Accept-Language.
+func setContentLanguage(w http.ResponseWriter, locales []string) {
+	w.Header().Add("Vary", "Accept-Language")
+	if len(locales) > 0 {
+		w.Header().Set("Content-Language", strings.Join(locales, ", "))
+	}
+}
+
+// localizePage localizes every item of a page with localize and sets
+// Content-Language to the distinct locales used.
+func localizePage[T any](w http.ResponseWriter, r *http.Request, page *models.Page[T], localize func(item *T, prefs []string) string) {
+	prefs := requestLocales(r)
+	var used []string
+	for i := range page.Items {
+		if locale := localize(&page.Items[i], prefs); !slices.Contains(used, locale) {
+			used = append(used, locale)
+		}
+	}
+	setContentLanguage(w, used)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/middleware"
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/ratelimit"
+	"github.com/ScriptVandal/backend-go/internal/services"
+)
+
+// messageActions maps the action paths under /api/messages/{id} to the
+// status they set.
+var messageActions = map[string]string{
+	"read":    models.MessageStatusRead,
+	"unread":  models.MessageStatusNew,
+	"archive": models.MessageStatusArchived,
+}
+
+type MessageHandler struct {
+	svc *services.MessageService
+	// limiter caps submissions per client IP.
+	limiter *ratelimit.Limiter
+}
+
+func NewMessageHandler(svc *services.MessageService, limiter *ratelimit.Limiter) *MessageHandler {
+	return &MessageHandler{svc: svc, limiter: limiter}
+}
+
+// Form handles GET /api/messages/form, issuing the token a contact form
+// sends back with its submission.
+func (h *MessageHandler) Form(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(h.svc.Form())
+}
+
+// Submit handles POST /api/messages from the public contact form. Dropped
+// spam gets the same 202 answer as a stored message.
+func (h *MessageHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+	if ok, retryAfter := h.limiter.Allow(middleware.ClientIP(r), time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		writeError(w, r, apperr.New(apperr.CodeTooManyRequests, "too many messages, try again later"))
+		return
+	}
+
+	var sub models.MessageSubmission
+	if err := decodeJSON(w, r, &sub); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := h.svc.Submit(&sub, actorOf(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "received"})
+}
+
+// List handles GET /api/messages with the usual paging parameters and the
+// filters status and email.
+func (h *MessageHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+	q, err := parseListQuery(r, "status", "email")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page, err := h.svc.ListMessages(q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// HandleItem serves /api/messages/{id} (GET, DELETE) and the status actions
+// POST /api/messages/{id}/read, /unread and /archive.
+func (h *MessageHandler) HandleItem(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/messages/"), "/")
+	if id == "" {
+		writeError(w, r, apperr.BadRequest("id is required"))
+		return
+	}
+
+	if action != "" {
+		status, ok := messageActions[action]
+		if !ok {
+			writeError(w, r, apperr.NotFound("not found"))
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeError(w, r, apperr.MethodNotAllowed())
+			return
+		}
+		item, err := h.svc.SetMessageStatus(id, status, actorOf(r))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(item)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		item, err := h.svc.GetMessage(id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if item == nil {
+			writeError(w, r, apperr.NotFound("message not found"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(item)
+	case http.MethodDelete:
+		if err := h.svc.DeleteMessage(id, actorOf(r)); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, apperr.MethodNotAllowed())
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/patch"
+)
+
+// acceptPatch lists the PATCH formats, for the Accept-Patch header.
+const acceptPatch = patch.MediaTypeMerge + ", " + patch.MediaTypeJSON
+
+// readPatch reads a PATCH request body and returns a function that applies
+// it to an item. The patched item is decoded with the same rules as a PUT
+// body, so unknown fields and wrong types are rejected.
+func readPatch[T any](w http.ResponseWriter, r *http.Request) (func(*T) error, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var apply func(doc, p []byte) ([]byte, error)
+	switch mediaType {
+	case patch.MediaTypeMerge:
+		apply = patch.Merge
+	case patch.MediaTypeJSON:
+		apply = patch.Apply
+	default:
+		w.Header().Set("Accept-Patch", acceptPatch)
+		return nil, apperr.New(apperr.CodeUnsupportedMediaType, "Content-Type must be "+patch.MediaTypeMerge+" or "+patch.MediaTypeJSON)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	if !json.Valid(body) {
+		return nil, apperr.BadRequest("request body is not valid JSON")
+	}
+
+	return func(item *T) error {
+		doc, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		patched, err := apply(doc, body)
+		if err != nil {
+			return err
+		}
+		var next T
+		if err := decodeStrict(bytes.NewReader(patched), &next); err != nil {
+			return err
+		}
+		*item = next
+		return nil
+	}, nil
+}
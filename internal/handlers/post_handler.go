@@ -3,31 +3,44 @@ package handlers
 import (
     "encoding/json"
     "net/http"
+    "net/url"
     "strings"
 
+    "github.com/ScriptVandal/backend-go/internal/apperr"
+    "github.com/ScriptVandal/backend-go/internal/middleware"
     "github.com/ScriptVandal/backend-go/internal/models"
-    "github.com/ScriptVandal/backend-go/internal/repositories"
     "github.com/ScriptVandal/backend-go/internal/services"
 )
 
 type PostHandler struct {
     svc *services.PostService
+    // requireIfMatch refuses writes that do not send If-Match.
+    requireIfMatch bool
 }
 
-func NewPostHandler(svc *services.PostService) *PostHandler {
-    return &PostHandler{svc: svc}
+func NewPostHandler(svc *services.PostService, requireIfMatch bool) *PostHandler {
+    return &PostHandler{svc: svc, requireIfMatch: requireIfMatch}
 }
 
 func (h *PostHandler) List(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
         return
     }
-    items, err := h.svc.ListPosts()
+    q, err := parseListQuery(r, "tag", "status")
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, r, err)
         return
     }
+    q.PublishedOnly = !canSeeDrafts(r)
+    items, err := h.svc.ListPosts(q)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    localizePage(w, r, items, func(item *models.Post, prefs []string) string {
+        return h.localize(r, item, prefs)
+    })
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(items)
 }
@@ -35,10 +48,38 @@ func (h *PostHandler) List(w http.ResponseWriter, r *http.Request) {
 func (h *PostHandler) HandleItem(w http.ResponseWriter, r *http.Request) {
     // Extract ID from path: /api/posts/{id}
     path := strings.TrimPrefix(r.URL.Path, "/api/posts/")
+    if slug, ok := strings.CutPrefix(path, "by-slug/"); ok {
+        if r.Method != http.MethodGet {
+            writeError(w, r, apperr.MethodNotAllowed())
+            return
+        }
+        h.GetBySlug(w, r, slug)
+        return
+    }
     id := strings.Split(path, "/")[0]
 
     if id == "" {
-        http.Error(w, "ID is required", http.StatusBadRequest)
+        writeError(w, r, apperr.BadRequest("id is required"))
+        return
+    }
+    if strings.TrimPrefix(path, id) == "/locales" {
+        if r.Method != http.MethodGet {
+            writeError(w, r, apperr.MethodNotAllowed())
+            return
+        }
+        h.Locales(w, r, id)
+        return
+    }
+    if strings.TrimPrefix(path, id) == "/restore" {
+        if r.Method != http.MethodPost {
+            writeError(w, r, apperr.MethodNotAllowed())
+            return
+        }
+        h.Restore(w, r, id)
+        return
+    }
+    if rest, ok := strings.CutPrefix(strings.TrimPrefix(path, id), "/revisions"); ok {
+        h.Revisions(w, r, id, rest)
         return
     }
 
@@ -47,84 +88,200 @@ func (h *PostHandler) HandleItem(w http.ResponseWriter, r *http.Request) {
         h.Get(w, r, id)
     case http.MethodPut:
         h.Update(w, r, id)
+    case http.MethodPatch:
+        h.Patch(w, r, id)
     case http.MethodDelete:
         h.Delete(w, r, id)
     default:
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
     }
 }
 
 func (h *PostHandler) Get(w http.ResponseWriter, r *http.Request, id string) {
-    item, err := h.svc.GetPost(id)
+    item, err := h.svc.GetPost(id, canSeeDrafts(r))
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, r, err)
         return
     }
     if item == nil {
-        http.Error(w, "post not found", http.StatusNotFound)
+        writeError(w, r, apperr.NotFound("post not found"))
+        return
+    }
+    locale := h.localize(r, item, requestLocales(r))
+    setContentLanguage(w, []string{locale})
+    etag := localizedETag(item.Version, locale, showTranslations(r))
+    if notModified(w, r, etag) {
+        return
+    }
+    writeItem(w, http.StatusOK, etag, item)
+}
+
+// GetBySlug serves /api/posts/by-slug/{slug}. Slugs retired by a rename
+// answer with a permanent redirect to the current one.
+func (h *PostHandler) GetBySlug(w http.ResponseWriter, r *http.Request, slug string) {
+    item, redirectSlug, err := h.svc.GetPostBySlug(slug, canSeeDrafts(r))
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    if redirectSlug != "" {
+        http.Redirect(w, r, "/api/posts/by-slug/"+url.PathEscape(redirectSlug), http.StatusMovedPermanently)
+        return
+    }
+    if item == nil {
+        writeError(w, r, apperr.NotFound("post not found"))
+        return
+    }
+    locale := h.localize(r, item, requestLocales(r))
+    setContentLanguage(w, []string{locale})
+    etag := localizedETag(item.Version, locale, showTranslations(r))
+    if notModified(w, r, etag) {
+        return
+    }
+    writeItem(w, http.StatusOK, etag, item)
+}
+
+// Locales serves GET /api/posts/{id}/locales, listing the locales the
+// post can be read in.
+func (h *PostHandler) Locales(w http.ResponseWriter, r *http.Request, id string) {
+    set, err := h.svc.GetPostLocales(id, canSeeDrafts(r))
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    if set == nil {
+        writeError(w, r, apperr.NotFound("post not found"))
         return
     }
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(item)
+    json.NewEncoder(w).Encode(set)
+}
+
+// localize presents post in the locale negotiated from prefs and returns it.
+// Only editors keep the translations, so that they can save them back.
+func (h *PostHandler) localize(r *http.Request, post *models.Post, prefs []string) string {
+    locale := h.svc.LocalizePost(post, prefs)
+    if !showTranslations(r) {
+        post.Translations = nil
+    }
+    return locale
 }
 
 func (h *PostHandler) Create(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
         return
     }
 
     var post models.Post
-    if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
-        http.Error(w, "invalid request body", http.StatusBadRequest)
+    if err := decodeJSON(w, r, &post); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    if err := h.svc.CreatePost(&post); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.svc.CreatePost(&post, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(post)
+    writeItem(w, http.StatusCreated, versionETag(post.Version), post)
 }
 
 func (h *PostHandler) Update(w http.ResponseWriter, r *http.Request, id string) {
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
     var post models.Post
-    if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
-        http.Error(w, "invalid request body", http.StatusBadRequest)
+    if err := decodeJSON(w, r, &post); err != nil {
+        writeError(w, r, err)
         return
     }
 
     post.ID = id
 
-    if err := h.svc.UpdatePost(&post); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.svc.UpdatePost(&post, ifMatch, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(post)
+    writeItem(w, http.StatusOK, versionETag(post.Version), post)
+}
+
+// Patch applies a JSON Merge Patch or JSON Patch to the post.
+func (h *PostHandler) Patch(w http.ResponseWriter, r *http.Request, id string) {
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    patch, err := readPatch[models.Post](w, r)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
+    item, err := h.svc.PatchPost(id, ifMatch, actorOf(r), patch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
+    writeItem(w, http.StatusOK, versionETag(item.Version), item)
 }
 
 func (h *PostHandler) Delete(w http.ResponseWriter, r *http.Request, id string) {
-    if err := h.svc.DeletePost(id); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    if err := h.svc.DeletePost(id, ifMatch, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
     w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore serves POST /api/posts/{id}/restore, taking the post out of the
+// trash.
+func (h *PostHandler) Restore(w http.ResponseWriter, r *http.Request, id string) {
+    item, err := h.svc.RestorePost(id, actorOf(r))
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    writeItem(w, http.StatusOK, versionETag(item.Version), item)
+}
+
+// Revisions serves /api/posts/{id}/revisions and below; rest is the path
+// after /revisions.
+func (h *PostHandler) Revisions(w http.ResponseWriter, r *http.Request, id, rest string) {
+    revisionRoutes{
+        list: func() ([]models.Revision, error) {
+            return h.svc.ListPostRevisions(id)
+        },
+        get: func(version int) (*models.Revision, error) {
+            return h.svc.GetPostRevision(id, version)
+        },
+        diff: func(from, to int) (*models.RevisionDiff, error) {
+            return h.svc.DiffPostRevisions(id, from, to)
+        },
+        rollback: func(version int, ifMatch models.IfMatch) (int, any, error) {
+            item, err := h.svc.RollbackPost(id, version, ifMatch, actorOf(r))
+            if err != nil {
+                return 0, nil, err
+            }
+            return item.Version, item, nil
+        },
+        requireIfMatch: h.requireIfMatch,
+    }.serve(w, r, rest)
+}
+
+// canSeeDrafts reports whether the caller may see unpublished posts.
+func canSeeDrafts(r *http.Request) bool {
+    return middleware.HasRole(r, models.RoleAdmin, models.RoleEditor)
+}
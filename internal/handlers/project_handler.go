@@ -3,31 +3,42 @@ package handlers
 import (
     "encoding/json"
     "net/http"
+    "net/url"
     "strings"
 
+    "github.com/ScriptVandal/backend-go/internal/apperr"
     "github.com/ScriptVandal/backend-go/internal/models"
-    "github.com/ScriptVandal/backend-go/internal/repositories"
     "github.com/ScriptVandal/backend-go/internal/services"
 )
 
 type ProjectHandler struct {
     svc *services.ProjectService
+    // requireIfMatch refuses writes that do not send If-Match.
+    requireIfMatch bool
 }
 
-func NewProjectHandler(svc *services.ProjectService) *ProjectHandler {
-    return &ProjectHandler{svc: svc}
+func NewProjectHandler(svc *services.ProjectService, requireIfMatch bool) *ProjectHandler {
+    return &ProjectHandler{svc: svc, requireIfMatch: requireIfMatch}
 }
 
 func (h *ProjectHandler) List(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
         return
     }
-    items, err := h.svc.ListProjects()
+    q, err := parseListQuery(r, "tag")
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, r, err)
         return
     }
+    items, err := h.svc.ListProjects(q)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    localizePage(w, r, items, func(item *models.Project, prefs []string) string {
+        return h.localize(r, item, prefs)
+    })
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(items)
 }
@@ -35,10 +46,38 @@ func (h *ProjectHandler) List(w http.ResponseWriter, r *http.Request) {
 func (h *ProjectHandler) HandleItem(w http.ResponseWriter, r *http.Request) {
     // Extract ID from path: /api/projects/{id}
     path := strings.TrimPrefix(r.URL.Path, "/api/projects/")
+    if slug, ok := strings.CutPrefix(path, "by-slug/"); ok {
+        if r.Method != http.MethodGet {
+            writeError(w, r, apperr.MethodNotAllowed())
+            return
+        }
+        h.GetBySlug(w, r, slug)
+        return
+    }
     id := strings.Split(path, "/")[0]
 
     if id == "" {
-        http.Error(w, "ID is required", http.StatusBadRequest)
+        writeError(w, r, apperr.BadRequest("id is required"))
+        return
+    }
+    if strings.TrimPrefix(path, id) == "/locales" {
+        if r.Method != http.MethodGet {
+            writeError(w, r, apperr.MethodNotAllowed())
+            return
+        }
+        h.Locales(w, r, id)
+        return
+    }
+    if strings.TrimPrefix(path, id) == "/restore" {
+        if r.Method != http.MethodPost {
+            writeError(w, r, apperr.MethodNotAllowed())
+            return
+        }
+        h.Restore(w, r, id)
+        return
+    }
+    if rest, ok := strings.CutPrefix(strings.TrimPrefix(path, id), "/revisions"); ok {
+        h.Revisions(w, r, id, rest)
         return
     }
 
@@ -47,84 +86,195 @@ func (h *ProjectHandler) HandleItem(w http.ResponseWriter, r *http.Request) {
         h.Get(w, r, id)
     case http.MethodPut:
         h.Update(w, r, id)
+    case http.MethodPatch:
+        h.Patch(w, r, id)
     case http.MethodDelete:
         h.Delete(w, r, id)
     default:
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
     }
 }
 
 func (h *ProjectHandler) Get(w http.ResponseWriter, r *http.Request, id string) {
     item, err := h.svc.GetProject(id)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, r, err)
+        return
+    }
+    if item == nil {
+        writeError(w, r, apperr.NotFound("project not found"))
+        return
+    }
+    locale := h.localize(r, item, requestLocales(r))
+    setContentLanguage(w, []string{locale})
+    etag := localizedETag(item.Version, locale, showTranslations(r))
+    if notModified(w, r, etag) {
+        return
+    }
+    writeItem(w, http.StatusOK, etag, item)
+}
+
+// GetBySlug serves /api/projects/by-slug/{slug}. Slugs retired by a rename
+// answer with a permanent redirect to the current one.
+func (h *ProjectHandler) GetBySlug(w http.ResponseWriter, r *http.Request, slug string) {
+    item, redirectSlug, err := h.svc.GetProjectBySlug(slug)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    if redirectSlug != "" {
+        http.Redirect(w, r, "/api/projects/by-slug/"+url.PathEscape(redirectSlug), http.StatusMovedPermanently)
         return
     }
     if item == nil {
-        http.Error(w, "project not found", http.StatusNotFound)
+        writeError(w, r, apperr.NotFound("project not found"))
+        return
+    }
+    locale := h.localize(r, item, requestLocales(r))
+    setContentLanguage(w, []string{locale})
+    etag := localizedETag(item.Version, locale, showTranslations(r))
+    if notModified(w, r, etag) {
+        return
+    }
+    writeItem(w, http.StatusOK, etag, item)
+}
+
+// Locales serves GET /api/projects/{id}/locales, listing the locales the
+// project can be read in.
+func (h *ProjectHandler) Locales(w http.ResponseWriter, r *http.Request, id string) {
+    set, err := h.svc.GetProjectLocales(id)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    if set == nil {
+        writeError(w, r, apperr.NotFound("project not found"))
         return
     }
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(item)
+    json.NewEncoder(w).Encode(set)
+}
+
+// localize presents project in the locale negotiated from prefs and returns it.
+// Only editors keep the translations, so that they can save them back.
+func (h *ProjectHandler) localize(r *http.Request, project *models.Project, prefs []string) string {
+    locale := h.svc.LocalizeProject(project, prefs)
+    if !showTranslations(r) {
+        project.Translations = nil
+    }
+    return locale
 }
 
 func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
         return
     }
 
     var project models.Project
-    if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
-        http.Error(w, "invalid request body", http.StatusBadRequest)
+    if err := decodeJSON(w, r, &project); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    if err := h.svc.CreateProject(&project); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.svc.CreateProject(&project, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(project)
+    writeItem(w, http.StatusCreated, versionETag(project.Version), project)
 }
 
 func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request, id string) {
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
     var project models.Project
-    if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
-        http.Error(w, "invalid request body", http.StatusBadRequest)
+    if err := decodeJSON(w, r, &project); err != nil {
+        writeError(w, r, err)
         return
     }
 
     project.ID = id
 
-    if err := h.svc.UpdateProject(&project); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.svc.UpdateProject(&project, ifMatch, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(project)
+    writeItem(w, http.StatusOK, versionETag(project.Version), project)
+}
+
+// Patch applies a JSON Merge Patch or JSON Patch to the project.
+func (h *ProjectHandler) Patch(w http.ResponseWriter, r *http.Request, id string) {
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    patch, err := readPatch[models.Project](w, r)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
+    item, err := h.svc.PatchProject(id, ifMatch, actorOf(r), patch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
+    writeItem(w, http.StatusOK, versionETag(item.Version), item)
 }
 
 func (h *ProjectHandler) Delete(w http.ResponseWriter, r *http.Request, id string) {
-    if err := h.svc.DeleteProject(id); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    if err := h.svc.DeleteProject(id, ifMatch, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
     w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore serves POST /api/projects/{id}/restore, taking the project out of the
+// trash.
+func (h *ProjectHandler) Restore(w http.ResponseWriter, r *http.Request, id string) {
+    item, err := h.svc.RestoreProject(id, actorOf(r))
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    writeItem(w, http.StatusOK, versionETag(item.Version), item)
+}
+
+// Revisions serves /api/projects/{id}/revisions and below; rest is the path
+// after /revisions.
+func (h *ProjectHandler) Revisions(w http.ResponseWriter, r *http.Request, id, rest string) {
+    revisionRoutes{
+        list: func() ([]models.Revision, error) {
+            return h.svc.ListProjectRevisions(id)
+        },
+        get: func(version int) (*models.Revision, error) {
+            return h.svc.GetProjectRevision(id, version)
+        },
+        diff: func(from, to int) (*models.RevisionDiff, error) {
+            return h.svc.DiffProjectRevisions(id, from, to)
+        },
+        rollback: func(version int, ifMatch models.IfMatch) (int, any, error) {
+            item, err := h.svc.RollbackProject(id, version, ifMatch, actorOf(r))
+            if err != nil {
+                return 0, nil, err
+            }
+            return item.Version, item, nil
+        },
+        requireIfMatch: h.requireIfMatch,
+    }.serve(w, r, rest)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// revisionRoutes serves the revision history of one item. The handler of
+// each entity fills it in from its service.
+type revisionRoutes struct {
+	list     func() ([]models.Revision, error)
+	get      func(version int) (*models.Revision, error)
+	diff     func(from, to int) (*models.RevisionDiff, error)
+	rollback func(version int, ifMatch models.IfMatch) (newVersion int, item any, err error)
+	// requireIfMatch refuses rollbacks that do not send If-Match.
+	requireIfMatch bool
+}
+
+// serve routes rest, the path after .../{id}/revisions:
+//
+//	GET  ""                         list revisions, newest first
+//	GET  /diff?from={v}&to={v}      compare two revisions
+//	GET  /{version}                 one revision with its snapshot
+//	POST /{version}/rollback        save the revision as a new edit
+func (rr revisionRoutes) serve(w http.ResponseWriter, r *http.Request, rest string) {
+	rest = strings.Trim(rest, "/")
+	versionPart, action, _ := strings.Cut(rest, "/")
+
+	switch {
+	case rest == "":
+		if r.Method != http.MethodGet {
+			writeError(w, r, apperr.MethodNotAllowed())
+			return
+		}
+		revs, err := rr.list()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Page[models.Revision]{Items: revs, Total: len(revs)})
+
+	case rest == "diff":
+		if r.Method != http.MethodGet {
+			writeError(w, r, apperr.MethodNotAllowed())
+			return
+		}
+		from, err := versionParam(r.URL.Query().Get("from"), "from")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		to, err := versionParam(r.URL.Query().Get("to"), "to")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		d, err := rr.diff(from, to)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d)
+
+	case action == "":
+		if r.Method != http.MethodGet {
+			writeError(w, r, apperr.MethodNotAllowed())
+			return
+		}
+		version, err := versionParam(versionPart, "version")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		rev, err := rr.get(version)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rev)
+
+	case action == "rollback":
+		if r.Method != http.MethodPost {
+			writeError(w, r, apperr.MethodNotAllowed())
+			return
+		}
+		version, err := versionParam(versionPart, "version")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		ifMatch, err := parseIfMatch(r, rr.requireIfMatch)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		newVersion, item, err := rr.rollback(version, ifMatch)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeItem(w, http.StatusOK, versionETag(newVersion), item)
+
+	default:
+		writeError(w, r, apperr.NotFound("not found"))
+	}
+}
+
+// versionParam parses a revision number from the path or query string.
+func versionParam(s, name string) (int, error) {
+	if s == "" {
+		return 0, apperr.Validation(apperr.Field(name, "is required"))
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 1 {
+		return 0, apperr.Validation(apperr.Field(name, "must be a positive integer"))
+	}
+	return v, nil
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/services"
+)
+
+type SearchHandler struct {
+	svc *services.SearchService
+}
+
+func NewSearchHandler(svc *services.SearchService) *SearchHandler {
+	return &SearchHandler{svc: svc}
+}
+
+// Search handles GET /api/search?q=...&limit=...
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+
+	limit := 0
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeError(w, r, apperr.Validation(apperr.Field("limit", "must be a positive integer")))
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.svc.Search(r.URL.Query().Get("q"), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
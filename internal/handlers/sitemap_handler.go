@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/services"
+	"github.com/ScriptVandal/backend-go/internal/sitemap"
+)
+
+type SitemapHandler struct {
+	svc            *services.SitemapService
+	links          *SiteLinks
+	robotsDisallow []string
+}
+
+func NewSitemapHandler(svc *services.SitemapService, links *SiteLinks, robotsDisallow []string) *SitemapHandler {
+	return &SitemapHandler{svc: svc, links: links, robotsDisallow: robotsDisallow}
+}
+
+// Sitemap handles GET /sitemap.xml. Up to sitemap.MaxURLs URLs are listed
+// directly; beyond that it becomes an index of /sitemaps/{n}.xml pages.
+func (h *SitemapHandler) Sitemap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+	urls, err := h.urls()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(urls) <= sitemap.MaxURLs {
+		h.write(w, r, urls, sitemap.URLSet)
+		return
+	}
+
+	var pages []sitemap.URL
+	for n := 1; (n-1)*sitemap.MaxURLs < len(urls); n++ {
+		pages = append(pages, sitemap.URL{
+			Loc:     h.links.API("/sitemaps/" + strconv.Itoa(n) + ".xml"),
+			LastMod: sitemap.LastMod(sitemapPage(urls, n)),
+		})
+	}
+	h.write(w, r, pages, sitemap.Index)
+}
+
+// Page handles GET /sitemaps/{n}.xml, the pages of a sitemap index.
+func (h *SitemapHandler) Page(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/sitemaps/"), ".xml")
+	n, err := strconv.Atoi(name)
+	if !ok || err != nil || n < 1 {
+		writeError(w, r, apperr.NotFound("not found"))
+		return
+	}
+	urls, err := h.urls()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page := sitemapPage(urls, n)
+	if len(page) == 0 {
+		writeError(w, r, apperr.NotFound("not found"))
+		return
+	}
+	h.write(w, r, page, sitemap.URLSet)
+}
+
+// Robots handles GET /robots.txt.
+func (h *SitemapHandler) Robots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if len(h.robotsDisallow) == 0 {
+		b.WriteString("Disallow:\n")
+	}
+	for _, path := range h.robotsDisallow {
+		b.WriteString("Disallow: " + path + "\n")
+	}
+	b.WriteString("\nSitemap: " + h.links.API("/sitemap.xml") + "\n")
+	writeCached(w, r, "text/plain; charset=utf-8", time.Time{}, []byte(b.String()))
+}
+
+func (h *SitemapHandler) urls() ([]sitemap.URL, error) {
+	entries, err := h.svc.Entries()
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]sitemap.URL, 0, len(entries))
+	for _, e := range entries {
+		u := sitemap.URL{LastMod: e.UpdatedAt}
+		switch e.Type {
+		case models.EntityPost:
+			u.Loc = h.links.Post(e.ID, e.Slug)
+		case models.EntityProject:
+			u.Loc = h.links.Project(e.ID, e.Slug)
+		default:
+			continue
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+func (h *SitemapHandler) write(w http.ResponseWriter, r *http.Request, urls []sitemap.URL, encode func([]sitemap.URL) ([]byte, error)) {
+	body, err := encode(urls)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var modTime time.Time
+	if last := sitemap.LastMod(urls); last != nil {
+		modTime = *last
+	}
+	writeCached(w, r, sitemap.ContentType, modTime, body)
+}
+
+// sitemapPage returns the n-th (1-based) page of urls.
+func sitemapPage(urls []sitemap.URL, n int) []sitemap.URL {
+	from := (n - 1) * sitemap.MaxURLs
+	if from >= len(urls) {
+		return nil
+	}
+	return urls[from:min(from+sitemap.MaxURLs, len(urls))]
+}
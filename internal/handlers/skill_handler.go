@@ -5,29 +5,39 @@ import (
     "net/http"
     "strings"
 
+    "github.com/ScriptVandal/backend-go/internal/apperr"
     "github.com/ScriptVandal/backend-go/internal/models"
-    "github.com/ScriptVandal/backend-go/internal/repositories"
     "github.com/ScriptVandal/backend-go/internal/services"
 )
 
 type SkillHandler struct {
     svc *services.SkillService
+    // requireIfMatch refuses writes that do not send If-Match.
+    requireIfMatch bool
 }
 
-func NewSkillHandler(svc *services.SkillService) *SkillHandler {
-    return &SkillHandler{svc: svc}
+func NewSkillHandler(svc *services.SkillService, requireIfMatch bool) *SkillHandler {
+    return &SkillHandler{svc: svc, requireIfMatch: requireIfMatch}
 }
 
 func (h *SkillHandler) List(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
         return
     }
-    items, err := h.svc.ListSkills()
+    q, err := parseListQuery(r, "category", "level")
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, r, err)
         return
     }
+    items, err := h.svc.ListSkills(q)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    localizePage(w, r, items, func(item *models.Skill, prefs []string) string {
+        return h.localize(r, item, prefs)
+    })
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(items)
 }
@@ -38,7 +48,23 @@ func (h *SkillHandler) HandleItem(w http.ResponseWriter, r *http.Request) {
     id := strings.Split(path, "/")[0]
 
     if id == "" {
-        http.Error(w, "ID is required", http.StatusBadRequest)
+        writeError(w, r, apperr.BadRequest("id is required"))
+        return
+    }
+    if strings.TrimPrefix(path, id) == "/locales" {
+        if r.Method != http.MethodGet {
+            writeError(w, r, apperr.MethodNotAllowed())
+            return
+        }
+        h.Locales(w, r, id)
+        return
+    }
+    if strings.TrimPrefix(path, id) == "/restore" {
+        if r.Method != http.MethodPost {
+            writeError(w, r, apperr.MethodNotAllowed())
+            return
+        }
+        h.Restore(w, r, id)
         return
     }
 
@@ -47,84 +73,146 @@ func (h *SkillHandler) HandleItem(w http.ResponseWriter, r *http.Request) {
         h.Get(w, r, id)
     case http.MethodPut:
         h.Update(w, r, id)
+    case http.MethodPatch:
+        h.Patch(w, r, id)
     case http.MethodDelete:
         h.Delete(w, r, id)
     default:
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
     }
 }
 
 func (h *SkillHandler) Get(w http.ResponseWriter, r *http.Request, id string) {
     item, err := h.svc.GetSkill(id)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, r, err)
         return
     }
     if item == nil {
-        http.Error(w, "skill not found", http.StatusNotFound)
+        writeError(w, r, apperr.NotFound("skill not found"))
+        return
+    }
+    locale := h.localize(r, item, requestLocales(r))
+    setContentLanguage(w, []string{locale})
+    etag := localizedETag(item.Version, locale, showTranslations(r))
+    if notModified(w, r, etag) {
+        return
+    }
+    writeItem(w, http.StatusOK, etag, item)
+}
+
+// Locales serves GET /api/skills/{id}/locales, listing the locales the
+// skill can be read in.
+func (h *SkillHandler) Locales(w http.ResponseWriter, r *http.Request, id string) {
+    set, err := h.svc.GetSkillLocales(id)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    if set == nil {
+        writeError(w, r, apperr.NotFound("skill not found"))
         return
     }
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(item)
+    json.NewEncoder(w).Encode(set)
+}
+
+// localize presents skill in the locale negotiated from prefs and returns it.
+// Only editors keep the translations, so that they can save them back.
+func (h *SkillHandler) localize(r *http.Request, skill *models.Skill, prefs []string) string {
+    locale := h.svc.LocalizeSkill(skill, prefs)
+    if !showTranslations(r) {
+        skill.Translations = nil
+    }
+    return locale
 }
 
 func (h *SkillHandler) Create(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
-        w.WriteHeader(http.StatusMethodNotAllowed)
+        writeError(w, r, apperr.MethodNotAllowed())
         return
     }
 
     var skill models.Skill
-    if err := json.NewDecoder(r.Body).Decode(&skill); err != nil {
-        http.Error(w, "invalid request body", http.StatusBadRequest)
+    if err := decodeJSON(w, r, &skill); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    if err := h.svc.CreateSkill(&skill); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.svc.CreateSkill(&skill, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(skill)
+    writeItem(w, http.StatusCreated, versionETag(skill.Version), skill)
 }
 
 func (h *SkillHandler) Update(w http.ResponseWriter, r *http.Request, id string) {
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
     var skill models.Skill
-    if err := json.NewDecoder(r.Body).Decode(&skill); err != nil {
-        http.Error(w, "invalid request body", http.StatusBadRequest)
+    if err := decodeJSON(w, r, &skill); err != nil {
+        writeError(w, r, err)
         return
     }
 
     skill.ID = id
 
-    if err := h.svc.UpdateSkill(&skill); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.svc.UpdateSkill(&skill, ifMatch, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(skill)
+    writeItem(w, http.StatusOK, versionETag(skill.Version), skill)
+}
+
+// Patch applies a JSON Merge Patch or JSON Patch to the skill.
+func (h *SkillHandler) Patch(w http.ResponseWriter, r *http.Request, id string) {
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    patch, err := readPatch[models.Skill](w, r)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
+    item, err := h.svc.PatchSkill(id, ifMatch, actorOf(r), patch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+
+    writeItem(w, http.StatusOK, versionETag(item.Version), item)
 }
 
 func (h *SkillHandler) Delete(w http.ResponseWriter, r *http.Request, id string) {
-    if err := h.svc.DeleteSkill(id); err != nil {
-        if err == repositories.ErrReadOnly {
-            http.Error(w, "write operations not supported in JSON mode", http.StatusForbidden)
-            return
-        }
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    ifMatch, err := parseIfMatch(r, h.requireIfMatch)
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    if err := h.svc.DeleteSkill(id, ifMatch, actorOf(r)); err != nil {
+        writeError(w, r, err)
         return
     }
 
     w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore serves POST /api/skills/{id}/restore, taking the skill out of the
+// trash.
+func (h *SkillHandler) Restore(w http.ResponseWriter, r *http.Request, id string) {
+    item, err := h.svc.RestoreSkill(id, actorOf(r))
+    if err != nil {
+        writeError(w, r, err)
+        return
+    }
+    writeItem(w, http.StatusOK, versionETag(item.Version), item)
+}
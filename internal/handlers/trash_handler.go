@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/services"
+)
+
+type TrashHandler struct {
+	svc *services.TrashService
+}
+
+func NewTrashHandler(svc *services.TrashService) *TrashHandler {
+	return &TrashHandler{svc: svc}
+}
+
+// List handles GET /api/trash?type=...
+func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, apperr.MethodNotAllowed())
+		return
+	}
+
+	items, err := h.svc.List(r.URL.Query().Get("type"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Page[models.TrashItem]{Items: items, Total: len(items)})
+}
@@ -0,0 +1,131 @@
+// Package i18n negotiates the locale content is presented in.
+//
+// Locales are lower-case BCP 47 tags such as "en" or "pt-br". A request's
+// preferences come from the lang query parameter and the Accept-Language
+// header; each preference falls back to its base language, and everything
+// falls back to the site's default locale.
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// Canonical lower-cases tag and reports whether it is a well-formed locale.
+func Canonical(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(tag, "_", "-")))
+	return tag, tagPattern.MatchString(tag)
+}
+
+// Locales describes the content locales of the site. Stored items are
+// written in Default; Supported lists every locale, Default included.
+type Locales struct {
+	Default   string
+	Supported []string
+}
+
+// New builds Locales from a default and a list of supported locales,
+// skipping malformed tags. The default is always supported.
+func New(def string, supported []string) Locales {
+	l := Locales{Default: def}
+	if c, ok := Canonical(def); ok {
+		l.Default = c
+	}
+	l.Supported = []string{l.Default}
+	for _, tag := range supported {
+		if c, ok := Canonical(tag); ok && !slices.Contains(l.Supported, c) {
+			l.Supported = append(l.Supported, c)
+		}
+	}
+	return l
+}
+
+// Supports reports whether locale is one of the supported locales.
+func (l Locales) Supports(locale string) bool {
+	return slices.Contains(l.Supported, locale)
+}
+
+// Translatable reports whether an item may have a translation into locale:
+// it must be supported and not the default.
+func (l Locales) Translatable(locale string) bool {
+	return locale != l.Default && l.Supports(locale)
+}
+
+// Pick returns the first of prefs that is the default locale, or that is
+// supported and for which has reports true. It falls back to the default.
+func (l Locales) Pick(prefs []string, has func(locale string) bool) string {
+	for _, locale := range prefs {
+		if locale == l.Default || (l.Supports(locale) && has(locale)) {
+			return locale
+		}
+	}
+	return l.Default
+}
+
+// Preferences lists the locales a request asks for, most preferred first:
+// lang if it is set, then the ranges of acceptLanguage by quality. Each
+// locale is followed by its base language, so "en-GB" also matches "en".
+func Preferences(lang, acceptLanguage string) []string {
+	var prefs []string
+	add := func(tag string) {
+		tag, ok := Canonical(tag)
+		if !ok {
+			return
+		}
+		for {
+			if !slices.Contains(prefs, tag) {
+				prefs = append(prefs, tag)
+			}
+			i := strings.LastIndexByte(tag, '-')
+			if i < 0 {
+				return
+			}
+			tag = tag[:i]
+		}
+	}
+
+	add(lang)
+	for _, r := range parseAcceptLanguage(acceptLanguage) {
+		add(r)
+	}
+	return prefs
+}
+
+// parseAcceptLanguage returns the language ranges of an Accept-Language
+// header ordered by quality, dropping "*" and ranges with q=0.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			ranges = append(ranges, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	tags := make([]string, len(ranges))
+	for i, r := range ranges {
+		tags[i] = r.tag
+	}
+	return tags
+}
@@ -0,0 +1,153 @@
+// Package mail sends email: a Mailer delivers rendered messages, either over
+// SMTP or to a local outbox for development, and Render builds messages from
+// the templates in templates/.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is an email ready to send. Text is required; HTML, if set, is sent
+// as the alternative part mail clients prefer.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// ErrNoRecipients is returned for a message without recipients.
+var ErrNoRecipients = errors.New("mail: message has no recipients")
+
+// Bytes encodes msg as a MIME message from the address from, dated now.
+func (msg *Message) Bytes(from string, now time.Time) ([]byte, error) {
+	if len(msg.To) == 0 {
+		return nil, ErrNoRecipients
+	}
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("mail: invalid sender %q: %w", from, err)
+	}
+	to := make([]string, len(msg.To))
+	for i, addr := range msg.To {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("mail: invalid recipient %q: %w", addr, err)
+		}
+		to[i] = parsed.String()
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) { fmt.Fprintf(&buf, "%s: %s\r\n", name, value) }
+	header("From", fromAddr.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", fold(len("Subject: "), mime.QEncoding.Encode("utf-8", msg.Subject)))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(fromAddr.Address))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maxLineLen is the line length RFC 5322 recommends for header fields.
+const maxLineLen = 78
+
+// fold folds a header value at its spaces, which include the ones between
+// encoded-words, so that its lines stay within maxLineLen where possible.
+// prefix is the length of the field name and colon before the value.
+func fold(prefix int, value string) string {
+	var b strings.Builder
+	n := prefix
+	for i, word := range strings.Split(value, " ") {
+		switch {
+		case i == 0:
+		case n+1+len(word) > maxLineLen:
+			b.WriteString("\r\n ")
+			n = 1
+		default:
+			b.WriteByte(' ')
+			n++
+		}
+		b.WriteString(word)
+		n += len(word)
+	}
+	return b.String()
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID returns a unique Message-ID in the sender's domain.
+func messageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndexByte(from, '@'); i >= 0 {
+		domain = from[i+1:]
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// parseAddress returns the bare address of an RFC 5322 address, as SMTP
+// commands take it.
+func parseAddress(s string) (string, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", fmt.Errorf("mail: invalid address %q: %w", s, err)
+	}
+	return addr.Address, nil
+}
@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBytesFoldsLongSubject(t *testing.T) {
+	subject := strings.Repeat("Новый ответ на ваше сообщение в портфолио. ", 20)
+	msg := &Message{To: []string{"owner@example.com"}, Subject: subject, Text: "text"}
+	b, err := msg.Bytes("site@example.com", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head, _, _ := bytes.Cut(b, []byte("\r\n\r\n"))
+	for _, line := range strings.Split(string(head), "\r\n") {
+		if len(line) > 998 {
+			t.Fatalf("header line is %d bytes long: %.40q...", len(line), line)
+		}
+		if strings.HasPrefix(line, " ") && len(line) > maxLineLen {
+			t.Errorf("folded line is %d bytes long: %q", len(line), line)
+		}
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != subject {
+		t.Errorf("subject = %q, want %q", got, subject)
+	}
+}
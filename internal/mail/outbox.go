@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// OutboxMailer stands in for SMTP in development: each message is written to
+// Dir as an .eml file that any mail client can open. With an empty Dir
+// messages are only logged.
+type OutboxMailer struct {
+	Dir  string
+	From string
+}
+
+func NewOutboxMailer(dir, from string) *OutboxMailer {
+	return &OutboxMailer{Dir: dir, From: from}
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, msg *Message) error {
+	now := time.Now()
+	data, err := msg.Bytes(m.From, now)
+	if err != nil {
+		return err
+	}
+	if m.Dir == "" {
+		log.Printf("mail: to %v: %s\n%s", msg.To, msg.Subject, msg.Text)
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(m.Dir, strconv.FormatInt(now.UnixMilli(), 10)+"-*.eml")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Printf("mail: to %v: %s (%s)", msg.To, msg.Subject, f.Name())
+	return nil
+}
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Each message <name> has a text template templates/<name>.txt.tmpl, which
+// also defines "<name>.subject", and optionally an HTML alternative
+// templates/<name>.html.tmpl.
+//
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html.tmpl"))
+)
+
+// Render builds the message name for to from its templates and data.
+func Render(name string, data any, to ...string) (*Message, error) {
+	var subject, text bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return nil, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt.tmpl", data); err != nil {
+		return nil, err
+	}
+	msg := &Message{
+		To:      to,
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}
+	if htmlTemplates.Lookup(name+".html.tmpl") != nil {
+		var html bytes.Buffer
+		if err := htmlTemplates.ExecuteTemplate(&html, name+".html.tmpl", data); err != nil {
+			return nil, err
+		}
+		msg.HTML = html.String()
+	}
+	return msg, nil
+}
@@ -0,0 +1,134 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTP connection security modes.
+const (
+	// SecurityStartTLS upgrades a plain connection with STARTTLS and fails
+	// if the server does not offer it.
+	SecurityStartTLS = "starttls"
+	// SecurityTLS connects over TLS from the start, as on port 465.
+	SecurityTLS = "tls"
+	// SecurityNone sends in the clear. Only use it with a local relay.
+	SecurityNone = "none"
+)
+
+// SMTPConfig configures an SMTPMailer.
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password enable PLAIN authentication when Username is set.
+	Username string
+	Password string
+	// From is the sender address, optionally with a display name.
+	From string
+	// Security is one of SecurityStartTLS (the default), SecurityTLS and
+	// SecurityNone.
+	Security string
+	// Timeout bounds a whole delivery; zero means 30 seconds.
+	Timeout time.Duration
+}
+
+// SMTPMailer delivers messages to an SMTP server, one connection per message.
+type SMTPMailer struct {
+	cfg SMTPConfig
+	// rootCAs verifies the server certificate; nil uses the system pool.
+	rootCAs *x509.CertPool
+}
+
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	if cfg.Security == "" {
+		cfg.Security = SecurityStartTLS
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return &SMTPMailer{cfg: cfg}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	from, err := parseAddress(m.cfg.From)
+	if err != nil {
+		return err
+	}
+	data, err := msg.Bytes(m.cfg.From, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if m.cfg.Security == SecurityStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("mail: server does not offer STARTTLS")
+		}
+		if err := c.StartTLS(m.tlsConfig()); err != nil {
+			return err
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range msg.To {
+		to, err := parseAddress(rcpt)
+		if err != nil {
+			return err
+		}
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (m *SMTPMailer) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	if m.cfg.Security == SecurityTLS {
+		d := tls.Dialer{Config: m.tlsConfig()}
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+func (m *SMTPMailer) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: m.cfg.Host, RootCAs: m.rootCAs}
+}
@@ -0,0 +1,268 @@
+package mail
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is an SMTP server on a local port that accepts one session and
+// records what the client did.
+type fakeSMTP struct {
+	ln          net.Listener
+	tls         *tls.Config
+	offerTLS    bool
+	implicitTLS bool
+	done        chan struct{}
+
+	mu       sync.Mutex
+	verbs    []string
+	auth     string
+	authTLS  bool
+	mailFrom string
+	rcptTo   []string
+	data     string
+}
+
+func newFakeSMTP(t *testing.T, offerTLS, implicitTLS bool) (*fakeSMTP, *x509.CertPool) {
+	t.Helper()
+	cert, pool := selfSigned(t)
+	s := &fakeSMTP{
+		tls:         &tls.Config{Certificates: []tls.Certificate{cert}},
+		offerTLS:    offerTLS,
+		implicitTLS: implicitTLS,
+		done:        make(chan struct{}),
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicitTLS {
+		ln = tls.NewListener(ln, s.tls)
+	}
+	s.ln = ln
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		defer close(s.done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		s.serve(conn)
+	}()
+	return s, pool
+}
+
+func (s *fakeSMTP) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	secure := s.implicitTLS
+	tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+		s.mu.Lock()
+		s.verbs = append(s.verbs, verb)
+		s.mu.Unlock()
+
+		switch verb {
+		case "EHLO":
+			tp.PrintfLine("250-fake")
+			if s.offerTLS && !secure {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			tp.PrintfLine("220 go ahead")
+			tc := tls.Server(conn, s.tls)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn, tp, secure = tc, textproto.NewConn(tc), true
+		case "AUTH":
+			mech, resp, _ := strings.Cut(arg, " ")
+			decoded, err := base64.StdEncoding.DecodeString(resp)
+			if mech != "PLAIN" || err != nil {
+				tp.PrintfLine("535 bad credentials")
+				continue
+			}
+			s.mu.Lock()
+			s.auth, s.authTLS = string(decoded), secure
+			s.mu.Unlock()
+			tp.PrintfLine("235 accepted")
+		case "MAIL":
+			s.mu.Lock()
+			s.mailFrom = arg
+			s.mu.Unlock()
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcptTo = append(s.rcptTo, arg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 end with .")
+			lines, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = strings.Join(lines, "\n")
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// wait returns once the session is over.
+func (s *fakeSMTP) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP session did not end")
+	}
+}
+
+// selfSigned returns a certificate for 127.0.0.1 and a pool that trusts it.
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func testMessage() *Message {
+	return &Message{To: []string{"Owner <owner@example.com>"}, Subject: "Hello", Text: "Hi there"}
+}
+
+func TestSMTPMailerStartTLS(t *testing.T) {
+	srv, pool := newFakeSMTP(t, true, false)
+	m := NewSMTPMailer(SMTPConfig{Host: "127.0.0.1", Port: srv.port(), Username: "user", Password: "secret", From: "Blog <site@example.com>"})
+	m.rootCAs = pool
+
+	if err := m.Send(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	srv.wait(t)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	want := []string{"EHLO", "STARTTLS", "EHLO", "AUTH", "MAIL", "RCPT", "DATA", "QUIT"}
+	if strings.Join(srv.verbs, " ") != strings.Join(want, " ") {
+		t.Errorf("commands = %v, want %v", srv.verbs, want)
+	}
+	if srv.auth != "\x00user\x00secret" {
+		t.Errorf("AUTH PLAIN response = %q", srv.auth)
+	}
+	if !srv.authTLS {
+		t.Error("credentials were sent before STARTTLS")
+	}
+	if srv.mailFrom != "FROM:<site@example.com>" {
+		t.Errorf("MAIL %s", srv.mailFrom)
+	}
+	if len(srv.rcptTo) != 1 || srv.rcptTo[0] != "TO:<owner@example.com>" {
+		t.Errorf("RCPT %v", srv.rcptTo)
+	}
+	if !strings.Contains(srv.data, "Subject: Hello") || !strings.Contains(srv.data, "Hi there") {
+		t.Errorf("DATA does not contain the message:\n%s", srv.data)
+	}
+}
+
+func TestSMTPMailerRefusesServerWithoutStartTLS(t *testing.T) {
+	srv, pool := newFakeSMTP(t, false, false)
+	m := NewSMTPMailer(SMTPConfig{Host: "127.0.0.1", Port: srv.port(), Username: "user", Password: "secret", From: "site@example.com"})
+	m.rootCAs = pool
+
+	if err := m.Send(context.Background(), testMessage()); err == nil {
+		t.Fatal("Send succeeded without STARTTLS")
+	}
+	srv.ln.Close()
+	srv.wait(t)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.auth != "" || srv.data != "" {
+		t.Error("credentials or the message were sent in the clear")
+	}
+}
+
+func TestSMTPMailerRejectsUntrustedCertificate(t *testing.T) {
+	srv, _ := newFakeSMTP(t, true, false)
+	m := NewSMTPMailer(SMTPConfig{Host: "127.0.0.1", Port: srv.port(), Username: "user", Password: "secret", From: "site@example.com"})
+
+	if err := m.Send(context.Background(), testMessage()); err == nil {
+		t.Fatal("Send accepted a certificate no CA signed")
+	}
+	srv.ln.Close()
+	srv.wait(t)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.auth != "" {
+		t.Error("credentials were sent to an unverified server")
+	}
+}
+
+func TestSMTPMailerImplicitTLS(t *testing.T) {
+	srv, pool := newFakeSMTP(t, false, true)
+	m := NewSMTPMailer(SMTPConfig{Host: "127.0.0.1", Port: srv.port(), Username: "user", Password: "secret", From: "site@example.com", Security: SecurityTLS})
+	m.rootCAs = pool
+
+	if err := m.Send(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	srv.wait(t)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !srv.authTLS || srv.data == "" {
+		t.Errorf("commands = %v, AUTH over TLS = %v", srv.verbs, srv.authTLS)
+	}
+}
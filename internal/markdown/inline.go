@@ -0,0 +1,351 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// safeSchemes are the URL schemes allowed in links. Relative URLs are
+// always allowed.
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// safeImageSchemes are the URL schemes allowed in image sources.
+var safeImageSchemes = map[string]bool{"http": true, "https": true}
+
+// maxNesting caps the nesting of brackets in link text and of parentheses
+// in link destinations. Deeper input is rendered as text, so that each
+// bracket does not rescan the rest of the input.
+const maxNesting = 32
+
+// unclosed records the delimiters known to have no closer in the rest of
+// the text being rendered. Every later opener of the same kind would scan
+// to the end in vain, which makes long runs of openers quadratic.
+type unclosed map[string]bool
+
+// renderInline renders inline Markdown to HTML. All text is escaped.
+func renderInline(s string) string {
+	var b strings.Builder
+	inline(&b, s, true)
+	return b.String()
+}
+
+// inline renders s into b. Links are not rendered inside link text.
+func inline(b *strings.Builder, s string, links bool) {
+	seen := unclosed{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			if n := codeSpan(b, s[i:], seen); n > 0 {
+				i += n
+				continue
+			}
+			// An unmatched backtick run is literal text.
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			b.WriteString(s[i : i+run])
+			i += run
+			continue
+		case c == '!' && links && strings.HasPrefix(s[i+1:], "["):
+			if n := image(b, s[i:]); n > 0 {
+				i += n
+				continue
+			}
+		case c == '[' && links:
+			if n := link(b, s[i:]); n > 0 {
+				i += n
+				continue
+			}
+		case c == '<' && links:
+			if n := autolink(b, s[i:], seen); n > 0 {
+				i += n
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			if n := emphasis(b, s, i, links, seen); n > 0 {
+				i += n
+				continue
+			}
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(html.EscapeString(s[i : i+size]))
+		i += size
+	}
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// codeSpan renders a code span at the start of s and returns its length,
+// or 0 if the backtick run is unmatched.
+func codeSpan(b *strings.Builder, s string, seen unclosed) int {
+	run := len(s) - len(strings.TrimLeft(s, "`"))
+	fence := s[:run]
+	if seen[fence] {
+		return 0
+	}
+	for j := run; j < len(s); {
+		k := strings.Index(s[j:], fence)
+		if k < 0 {
+			break
+		}
+		k += j
+		end := k + run
+		// The closing run must be exactly as long as the opening one.
+		if end < len(s) && s[end] == '`' {
+			j = end + len(s[end:]) - len(strings.TrimLeft(s[end:], "`"))
+			continue
+		}
+		code := strings.ReplaceAll(s[run:k], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+			code = code[1 : len(code)-1]
+		}
+		b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+		return end
+	}
+	seen[fence] = true
+	return 0
+}
+
+// linkParts splits "[text](dest "title")" at the start of s.
+func linkParts(s string) (text, dest, title string, n int) {
+	depth := 0
+	close := -1
+	for i := 0; i < len(s) && close < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			// Brackets inside code spans do not count.
+			if m := strings.IndexByte(s[i+1:], '`'); m >= 0 {
+				i += m + 1
+			}
+		case '[':
+			depth++
+			if depth > maxNesting {
+				return "", "", "", 0
+			}
+		case ']':
+			depth--
+			if depth == 0 {
+				close = i
+			}
+		}
+	}
+	if close < 0 || close+1 >= len(s) || s[close+1] != '(' {
+		return "", "", "", 0
+	}
+	// Parentheses in the destination must be balanced.
+	end, parens := -1, 0
+	for i := close + 2; i < len(s) && end < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			parens++
+			if parens > maxNesting {
+				return "", "", "", 0
+			}
+		case ')':
+			if parens == 0 {
+				end = i
+			}
+			parens--
+		}
+	}
+	if end < 0 {
+		return "", "", "", 0
+	}
+	inner := strings.TrimSpace(s[close+2 : end])
+	dest, title, _ = strings.Cut(inner, " ")
+	title = strings.TrimSpace(title)
+	if len(title) >= 2 && (title[0] == '"' || title[0] == '\'') && title[len(title)-1] == title[0] {
+		title = title[1 : len(title)-1]
+	} else {
+		title = ""
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	return s[1:close], dest, title, end + 1
+}
+
+func link(b *strings.Builder, s string) int {
+	text, dest, title, n := linkParts(s)
+	if n == 0 {
+		return 0
+	}
+	href, ok := safeURL(dest, safeSchemes)
+	if !ok {
+		// Keep the text, drop the unsafe link.
+		inline(b, text, false)
+		return n
+	}
+	b.WriteString(`<a href="` + html.EscapeString(href) + `"`)
+	if title != "" {
+		b.WriteString(` title="` + html.EscapeString(title) + `"`)
+	}
+	if isExternal(href) {
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	b.WriteString(">")
+	inline(b, text, false)
+	b.WriteString("</a>")
+	return n
+}
+
+func image(b *strings.Builder, s string) int {
+	alt, dest, title, n := linkParts(s[1:])
+	if n == 0 {
+		return 0
+	}
+	src, ok := safeURL(dest, safeImageSchemes)
+	if !ok {
+		b.WriteString(html.EscapeString(alt))
+		return n + 1
+	}
+	var text strings.Builder
+	inline(&text, alt, false)
+	b.WriteString(`<img src="` + html.EscapeString(src) + `" alt="` + html.EscapeString(plainText(text.String())) + `"`)
+	if title != "" {
+		b.WriteString(` title="` + html.EscapeString(title) + `"`)
+	}
+	b.WriteString(` loading="lazy">`)
+	return n + 1
+}
+
+// autolink renders <https://...> and <user@example.com>.
+func autolink(b *strings.Builder, s string, seen unclosed) int {
+	if seen[">"] {
+		return 0
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		seen[">"] = true
+		return 0
+	}
+	target := s[1:end]
+	if target == "" || strings.ContainsAny(target, " <\n") {
+		return 0
+	}
+	href := target
+	if !strings.Contains(target, ":") && strings.Contains(target, "@") {
+		href = "mailto:" + target
+	}
+	href, ok := safeURL(href, safeSchemes)
+	if !ok || !strings.Contains(href, ":") {
+		return 0
+	}
+	b.WriteString(`<a href="` + html.EscapeString(href) + `"`)
+	if isExternal(href) {
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	b.WriteString(">" + html.EscapeString(target) + "</a>")
+	return end + 1
+}
+
+// safeURL reports whether raw is relative or uses one of schemes, and
+// returns it normalised.
+func safeURL(raw string, schemes map[string]bool) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" && !schemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	return u.String(), true
+}
+
+func isExternal(href string) bool {
+	return strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://") || strings.HasPrefix(href, "//")
+}
+
+// emphasis renders *em*, **strong**, _em_, __strong__ and ~~del~~ starting
+// at s[i] and returns the number of bytes consumed, or 0.
+func emphasis(b *strings.Builder, s string, i int, links bool, seen unclosed) int {
+	c := s[i]
+	run := len(s[i:]) - len(strings.TrimLeft(s[i:], string(c)))
+	if c == '~' && run != 2 {
+		return 0
+	}
+	if run > 2 {
+		run = 2
+	}
+	delim := s[i : i+run]
+	body := s[i+run:]
+	if body == "" || unicode.IsSpace(firstRune(body)) {
+		return 0
+	}
+	// Underscores inside words are literal, as in snake_case.
+	if c == '_' && i > 0 && isWordRune(lastRune(s[:i])) {
+		return 0
+	}
+	if seen[delim] {
+		return 0
+	}
+
+	for j := 0; j < len(body); j++ {
+		switch {
+		case body[j] == '\\':
+			j++
+			continue
+		case body[j] == '`':
+			// Delimiters inside code spans do not close emphasis.
+			if n := strings.Index(body[j+1:], "`"); n >= 0 {
+				j += n + 1
+			}
+			continue
+		case !strings.HasPrefix(body[j:], delim) || j == 0:
+			continue
+		}
+		if unicode.IsSpace(lastRune(body[:j])) {
+			continue
+		}
+		after := body[j+run:]
+		// A single delimiter must not be the start of a double one.
+		if run == 1 && strings.HasPrefix(after, string(c)) {
+			j++
+			continue
+		}
+		if c == '_' && after != "" && isWordRune(firstRune(after)) {
+			continue
+		}
+		tag := "em"
+		switch {
+		case c == '~':
+			tag = "del"
+		case run == 2:
+			tag = "strong"
+		}
+		b.WriteString("<" + tag + ">")
+		inline(b, body[:j], links)
+		b.WriteString("</" + tag + ">")
+		return run + j + run
+	}
+	seen[delim] = true
+	return 0
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
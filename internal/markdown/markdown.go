@@ -0,0 +1,388 @@
+// Package markdown renders post content to HTML that is safe to embed.
+//
+// It supports the common subset of CommonMark used in posts: ATX headings,
+// paragraphs, emphasis, strikethrough, inline code, fenced code blocks,
+// block quotes, lists, thematic breaks, links, images and autolinks. Raw
+// HTML is never passed through; it is escaped like any other text, so the
+// output only ever contains the tags this package emits itself.
+package markdown
+
+import (
+	"html"
+	"math"
+	"strings"
+
+	"github.com/ScriptVandal/backend-go/internal/slug"
+)
+
+// WordsPerMinute is the reading speed used for ReadingTime.
+const WordsPerMinute = 200
+
+// Heading is a table of contents entry.
+type Heading struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// Result is a rendered document.
+type Result struct {
+	HTML string
+	TOC  []Heading
+	// Words counts the words of the rendered text.
+	Words int
+}
+
+// Render converts Markdown source to sanitised HTML.
+func Render(src string) Result {
+	r := &renderer{ids: map[string]bool{}}
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.ReplaceAll(line, "\t", "    ")
+	}
+	r.blocks(lines, false)
+	return Result{HTML: r.out.String(), TOC: r.toc, Words: r.words}
+}
+
+// ReadingTime returns the reading time in whole minutes, at least one for
+// any non-empty text.
+func ReadingTime(words int) int {
+	if words == 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(words) / WordsPerMinute))
+}
+
+type renderer struct {
+	out   strings.Builder
+	toc   []Heading
+	ids   map[string]bool
+	words int
+}
+
+// blocks renders a sequence of block-level lines. In tight lists paragraphs
+// are rendered without their <p> wrapper.
+func (r *renderer) blocks(lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			i++
+		case fenceOf(line) != "":
+			i = r.fencedCode(lines, i)
+		case headingLevel(line) > 0:
+			r.heading(line)
+			i++
+		case isThematicBreak(line):
+			r.out.WriteString("<hr>\n")
+			i++
+		case isQuote(line):
+			i = r.quote(lines, i)
+		case listMarker(line) != nil:
+			i = r.list(lines, i)
+		default:
+			i = r.paragraph(lines, i, tight)
+		}
+	}
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// fenceOf returns the opening fence of a fenced code block, if line is one.
+func fenceOf(line string) string {
+	if indentOf(line) > 3 {
+		return ""
+	}
+	s := strings.TrimLeft(line, " ")
+	for _, c := range []string{"`", "~"} {
+		n := len(s) - len(strings.TrimLeft(s, c))
+		if n >= 3 {
+			if c == "`" && strings.Contains(s[n:], "`") {
+				return ""
+			}
+			return s[:n]
+		}
+	}
+	return ""
+}
+
+func (r *renderer) fencedCode(lines []string, i int) int {
+	fence := fenceOf(lines[i])
+	info := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(lines[i]), fence[:1]))
+	lang, _, _ := strings.Cut(info, " ")
+
+	var code []string
+	i++
+	for ; i < len(lines); i++ {
+		s := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(s, fence) && strings.Trim(s, fence[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	r.out.WriteString("<pre><code")
+	if lang = cleanLanguage(lang); lang != "" {
+		r.out.WriteString(` class="language-` + lang + `"`)
+	}
+	r.out.WriteString(">")
+	body := strings.Join(code, "\n")
+	if len(code) > 0 {
+		body += "\n"
+	}
+	r.out.WriteString(html.EscapeString(body))
+	r.out.WriteString("</code></pre>\n")
+	r.words += len(strings.Fields(body))
+	return i
+}
+
+// cleanLanguage keeps the characters that appear in language names, so the
+// info string cannot break out of the class attribute.
+func cleanLanguage(lang string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.ContainsRune("+-#_.", c):
+			return c
+		}
+		return -1
+	}, lang)
+}
+
+func headingLevel(line string) int {
+	if indentOf(line) > 3 {
+		return 0
+	}
+	s := strings.TrimLeft(line, " ")
+	n := len(s) - len(strings.TrimLeft(s, "#"))
+	if n < 1 || n > 6 || (len(s) > n && s[n] != ' ') {
+		return 0
+	}
+	return n
+}
+
+func (r *renderer) heading(line string) {
+	level := headingLevel(line)
+	text := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+	// An optional closing sequence of #s is not part of the heading.
+	if closed := strings.TrimRight(text, "#"); closed == "" || strings.HasSuffix(closed, " ") {
+		text = strings.TrimSpace(closed)
+	}
+
+	inner := renderInline(text)
+	plain := plainText(inner)
+	id := r.anchor(plain)
+	r.toc = append(r.toc, Heading{Level: level, ID: id, Text: plain})
+	r.words += len(strings.Fields(plain))
+
+	tag := string(rune('0' + level))
+	r.out.WriteString(`<h` + tag + ` id="` + id + `">` + inner + `</h` + tag + ">\n")
+}
+
+// anchor returns a unique heading ID derived from text.
+func (r *renderer) anchor(text string) string {
+	base := slug.Make(text)
+	if base == "" {
+		base = "section"
+	}
+	id := base
+	for n := 2; r.ids[id]; n++ {
+		id = slug.WithSuffix(base, n)
+	}
+	r.ids[id] = true
+	return id
+}
+
+func isThematicBreak(line string) bool {
+	if indentOf(line) > 3 {
+		return false
+	}
+	s := strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	if len(s) < 3 {
+		return false
+	}
+	return strings.Trim(s, s[:1]) == "" && strings.Contains("-*_", s[:1])
+}
+
+func isQuote(line string) bool {
+	return indentOf(line) <= 3 && strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+func (r *renderer) quote(lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines) && isQuote(lines[i]); i++ {
+		s := strings.TrimPrefix(strings.TrimLeft(lines[i], " "), ">")
+		inner = append(inner, strings.TrimPrefix(s, " "))
+	}
+	r.out.WriteString("<blockquote>\n")
+	r.blocks(inner, false)
+	r.out.WriteString("</blockquote>\n")
+	return i
+}
+
+type marker struct {
+	ordered bool
+	start   string
+	// width is the indentation of the item content.
+	width int
+	// bullet is the bullet character or the ordered delimiter.
+	bullet byte
+}
+
+func listMarker(line string) *marker {
+	indent := indentOf(line)
+	if indent > 3 {
+		return nil
+	}
+	s := line[indent:]
+	if len(s) >= 2 && strings.ContainsRune("-*+", rune(s[0])) && s[1] == ' ' {
+		if isThematicBreak(line) {
+			return nil
+		}
+		return &marker{bullet: s[0], width: indent + 2}
+	}
+	n := 0
+	for n < len(s) && n < 9 && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n > 0 && len(s) > n+1 && (s[n] == '.' || s[n] == ')') && s[n+1] == ' ' {
+		return &marker{ordered: true, start: strings.TrimLeft(s[:n], "0"), bullet: s[n], width: indent + n + 2}
+	}
+	return nil
+}
+
+func (r *renderer) list(lines []string, i int) int {
+	first := listMarker(lines[i])
+	var items [][]string
+	tight := true
+	for i < len(lines) {
+		m := listMarker(lines[i])
+		if m == nil || m.ordered != first.ordered || m.bullet != first.bullet {
+			break
+		}
+		item := []string{strings.TrimLeft(lines[i][m.width-1:], " ")}
+		i++
+		for i < len(lines) {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				// A blank line continues the item only if indented content follows.
+				if i+1 < len(lines) && indentOf(lines[i+1]) >= m.width && strings.TrimSpace(lines[i+1]) != "" {
+					tight = false
+					item = append(item, "")
+					i++
+					continue
+				}
+				break
+			}
+			if indentOf(line) >= m.width {
+				item = append(item, line[m.width:])
+				i++
+				continue
+			}
+			// Lazy continuation of the item's paragraph.
+			if listMarker(line) != nil || startsBlock(line) {
+				break
+			}
+			item = append(item, strings.TrimSpace(line))
+			i++
+		}
+		items = append(items, item)
+
+		// Blank lines between items make the list loose.
+		j := i
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			j++
+		}
+		if j > i && j < len(lines) {
+			if m := listMarker(lines[j]); m != nil && m.ordered == first.ordered && m.bullet == first.bullet {
+				tight = false
+				i = j
+			}
+		}
+	}
+
+	tag := "ul"
+	open := "<ul>"
+	if first.ordered {
+		tag = "ol"
+		open = "<ol>"
+		if first.start != "" && first.start != "1" {
+			open = `<ol start="` + first.start + `">`
+		}
+	}
+	r.out.WriteString(open + "\n")
+	for _, item := range items {
+		r.out.WriteString("<li>")
+		r.blocks(item, tight)
+		r.out.WriteString("</li>\n")
+	}
+	r.out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// startsBlock reports whether line interrupts a paragraph.
+func startsBlock(line string) bool {
+	return fenceOf(line) != "" || headingLevel(line) > 0 || isThematicBreak(line) || isQuote(line)
+}
+
+func (r *renderer) paragraph(lines []string, i int, tight bool) int {
+	var para []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || (len(para) > 0 && (startsBlock(line) || listMarker(line) != nil)) {
+			break
+		}
+		para = append(para, line)
+	}
+
+	var b strings.Builder
+	for n, line := range para {
+		text := strings.TrimLeft(line, " ")
+		hardBreak := false
+		if n < len(para)-1 {
+			switch {
+			case strings.HasSuffix(text, "  "):
+				hardBreak = true
+			case strings.HasSuffix(text, `\`) && !strings.HasSuffix(text, `\\`):
+				hardBreak = true
+				text = strings.TrimSuffix(text, `\`)
+			}
+		}
+		b.WriteString(strings.TrimRight(text, " "))
+		if hardBreak {
+			b.WriteString("\x00")
+		}
+		if n < len(para)-1 {
+			b.WriteString("\n")
+		}
+	}
+
+	inner := strings.ReplaceAll(renderInline(b.String()), "\x00", "<br>")
+	r.words += len(strings.Fields(plainText(inner)))
+	if tight {
+		r.out.WriteString(inner)
+		return i
+	}
+	r.out.WriteString("<p>" + inner + "</p>\n")
+	return i
+}
+
+// plainText strips tags from rendered inline HTML and unescapes entities.
+func plainText(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, c := range s {
+		switch {
+		case c == '<':
+			inTag = true
+		case c == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteRune(c)
+		}
+	}
+	return html.UnescapeString(b.String())
+}
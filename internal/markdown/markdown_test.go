@@ -0,0 +1,83 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"emphasis", "**b** *e* _e_ __s__ ~~d~~ `c`", "<p><strong>b</strong> <em>e</em> <em>e</em> <strong>s</strong> <del>d</del> <code>c</code></p>\n"},
+		{"snake case", "snake_case_name", "<p>snake_case_name</p>\n"},
+		{"relative link", "[a](/local)", `<p><a href="/local">a</a></p>` + "\n"},
+		{"external link", "[a](https://e.com)", `<p><a href="https://e.com" rel="nofollow noopener noreferrer">a</a></p>` + "\n"},
+		{"autolink", "<https://e.com>", `<p><a href="https://e.com" rel="nofollow noopener noreferrer">https://e.com</a></p>` + "\n"},
+
+		// Unsafe URLs lose the link but keep the text.
+		{"javascript link", "[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"mixed-case scheme", "[x](JaVaScRiPt:alert(1))", "<p>x</p>\n"},
+		{"leading space", "[x]( javascript:alert(1))", "<p>x</p>\n"},
+		{"vbscript link", "[x](vbscript:msgbox(1))", "<p>x</p>\n"},
+		{"javascript autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+		{"data image", "![x](data:image/svg+xml;base64,PHN2Zz4=)", "<p>x</p>\n"},
+		{"mixed-case data image", "![x](DATA:text/html,hi)", "<p>x</p>\n"},
+		{"javascript image", "![x](javascript:alert(1))", "<p>x</p>\n"},
+
+		// Raw HTML is escaped.
+		{"script tag", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"html in heading", "# <b>x</b>", `<h1 id="b-x-b">&lt;b&gt;x&lt;/b&gt;</h1>` + "\n"},
+		{"html in code", "`<img onerror=x>`", "<p><code>&lt;img onerror=x&gt;</code></p>\n"},
+
+		// Quotes cannot leave the attribute they are in.
+		{"quote in title", `[x](https://e.com "a\" onmouseover=\"b")`, `<p><a href="https://e.com" title="a\&#34; onmouseover=\&#34;b" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"quote in href", `[x](https://e.com" onmouseover="alert(1))`, `<p><a href="https://e.com&#34;" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"quote in alt", `![a" onerror="x](/i.png)`, `<p><img src="/i.png" alt="a&#34; onerror=&#34;x" loading="lazy"></p>` + "\n"},
+
+		// The info string is reduced to a plain language name.
+		{"language quote", "```js\" onclick=\"x\nfoo\n```", `<pre><code class="language-js">foo` + "\n</code></pre>\n"},
+		{"language tag", "```<script>\nfoo\n```", `<pre><code class="language-script">foo` + "\n</code></pre>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.src).HTML; got != tt.want {
+				t.Errorf("Render(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadingIDs(t *testing.T) {
+	r := Render("# Intro\n## Intro\n# Intro 2\n# !!!\n# ???")
+	want := []Heading{
+		{1, "intro", "Intro"},
+		{2, "intro-2", "Intro"},
+		{1, "intro-2-2", "Intro 2"},
+		{1, "section", "!!!"},
+		{1, "section-2", "???"},
+	}
+	if !reflect.DeepEqual(r.TOC, want) {
+		t.Errorf("TOC = %v, want %v", r.TOC, want)
+	}
+	for _, h := range want {
+		if strings.Count(r.HTML, `id="`+h.ID+`"`) != 1 {
+			t.Errorf("id %q does not appear exactly once in %q", h.ID, r.HTML)
+		}
+	}
+}
+
+// Runs of openers without closers used to rescan the rest of the input for
+// each opener, taking seconds on inputs of the maximum post size.
+func TestRenderUnclosedRunsInLinearTime(t *testing.T) {
+	for _, unit := range []string{"*a ", "_a ", "**a ", "~~a ", "[", "![", "[`", "[a](", "[](", "<a ", "*a _a "} {
+		src := strings.Repeat(unit, 100000/len(unit))
+		start := time.Now()
+		Render(src)
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("rendering 100 KB of %q took %v", unit, d)
+		}
+	}
+}
@@ -5,52 +5,79 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/ScriptVandal/backend-go/internal/apperr"
 	"github.com/ScriptVandal/backend-go/internal/services"
 )
 
 type contextKey string
 
-const UserIDKey contextKey = "userID"
+const (
+	UserIDKey contextKey = "userID"
+	RoleKey   contextKey = "role"
+)
 
-// Auth middleware protects routes based on HTTP method
-// GET requests are public, POST/PUT/DELETE require authentication
+// Auth middleware authenticates requests that carry a Bearer token and puts
+// the user ID and role into the request context. Requests without a token
+// pass through anonymously; routes that need a user are wrapped in RequireRole.
+// An invalid token is rejected on POST/PUT/DELETE and ignored on GET.
 func Auth(authService *services.AuthService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Allow GET requests without authentication
-			if r.Method == http.MethodGet || r.Method == http.MethodOptions {
-				next.ServeHTTP(w, r)
-				return
-			}
-
-			// Require authentication for POST/PUT/DELETE
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "missing authorization header", http.StatusUnauthorized)
+			if authHeader == "" || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
 				return
 			}
+			safe := r.Method == http.MethodGet
 
 			// Extract Bearer token
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, "invalid authorization header format", http.StatusUnauthorized)
+				if safe {
+					next.ServeHTTP(w, r)
+					return
+				}
+				apperr.Write(w, r, apperr.Unauthorized("invalid authorization header format"))
 				return
 			}
 
 			token := parts[1]
-			userID, err := authService.ValidateAccessToken(token)
+			userID, role, err := authService.ValidateAccessToken(token)
 			if err != nil {
-				http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+				if safe {
+					next.ServeHTTP(w, r)
+					return
+				}
+				apperr.Write(w, r, apperr.Unauthorized("invalid or expired token"))
 				return
 			}
 
-			// Add user ID to context
+			// Add user ID and role to context
 			ctx := context.WithValue(r.Context(), UserIDKey, userID)
+			ctx = context.WithValue(ctx, RoleKey, role)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// RequireRole only lets through authenticated users with one of the given
+// roles. It must run after Auth.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if GetUserID(r) == "" {
+				apperr.Write(w, r, apperr.Unauthorized("missing authorization header"))
+				return
+			}
+			if !HasRole(r, roles...) {
+				apperr.Write(w, r, apperr.Forbidden("insufficient permissions"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetUserID retrieves the user ID from the request context
 func GetUserID(r *http.Request) string {
 	userID, ok := r.Context().Value(UserIDKey).(string)
@@ -59,3 +86,23 @@ func GetUserID(r *http.Request) string {
 	}
 	return userID
 }
+
+// GetRole retrieves the user role from the request context
+func GetRole(r *http.Request) string {
+	role, ok := r.Context().Value(RoleKey).(string)
+	if !ok {
+		return ""
+	}
+	return role
+}
+
+// HasRole reports whether the authenticated user has one of the given roles
+func HasRole(r *http.Request, roles ...string) bool {
+	role := GetRole(r)
+	for _, allowed := range roles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
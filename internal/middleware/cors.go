@@ -22,8 +22,9 @@ func CORS(origins []string) func(http.Handler) http.Handler {
                 w.Header().Set("Access-Control-Allow-Origin", origins[0])
             }
 
-            w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-            w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+            w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+            w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, If-Match, If-None-Match")
+            w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag, Retry-After")
             w.Header().Set("Access-Control-Allow-Credentials", "true")
             
             if r.Method == http.MethodOptions {
@@ -10,6 +10,6 @@ func Logging(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         start := time.Now()
         next.ServeHTTP(w, r)
-        log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
+        log.Printf("%s %s %s %s", GetRequestID(r), r.Method, r.URL.Path, time.Since(start))
     })
 }
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIP takes the client address from header, which must be X-Real-IP or
+// X-Forwarded-For as set by the reverse proxy in front, and stores it in
+// r.RemoteAddr. Only the one header the proxy sets is read: a client can send
+// the other one itself. Of X-Forwarded-For the last entry is used, as the one
+// the proxy appended.
+func RealIP(header string) func(http.Handler) http.Handler {
+	header = http.CanonicalHeaderKey(header)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			values := r.Header.Values(header)
+			ip := ""
+			if len(values) > 0 {
+				entries := strings.Split(values[len(values)-1], ",")
+				ip = strings.TrimSpace(entries[len(entries)-1])
+			}
+			if net.ParseIP(ip) != nil {
+				r.RemoteAddr = net.JoinHostPort(ip, "0")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the IP address of the client that made r.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
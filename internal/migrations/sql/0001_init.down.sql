@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS contacts;
+DROP TABLE IF EXISTS skills;
+DROP TABLE IF EXISTS projects;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS users;
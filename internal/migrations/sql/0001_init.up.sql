@@ -1,4 +1,5 @@
--- init.sql: Database initialization script for portfolio backend
+-- 0001_init: baseline schema (formerly init.sql). Safe to run against a
+-- database that was created from init.sql.
 
 -- Users table for authentication
 CREATE TABLE IF NOT EXISTS users (
@@ -0,0 +1,8 @@
+-- Refresh token rotation: tokens issued from one login share a family, and a
+-- rotated token points at its replacement.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id TEXT;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS replaced_by TEXT;
+UPDATE refresh_tokens SET family_id = jti WHERE family_id IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
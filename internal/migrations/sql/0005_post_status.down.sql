@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_status_published_at;
+ALTER TABLE posts ALTER COLUMN published_at TYPE TEXT USING to_char(published_at, 'YYYY-MM-DD');
+ALTER TABLE posts DROP COLUMN IF EXISTS status;
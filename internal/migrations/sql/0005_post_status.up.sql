@@ -0,0 +1,12 @@
+-- Publishing workflow for posts: an explicit status and a real timestamp.
+-- Existing posts were all public, so they become published.
+ALTER TABLE posts ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published', 'archived'));
+ALTER TABLE posts ALTER COLUMN status SET DEFAULT 'draft';
+
+ALTER TABLE posts ALTER COLUMN published_at TYPE TIMESTAMPTZ USING (
+    CASE WHEN published_at ~ '^\d{4}-\d{2}-\d{2}' THEN published_at::timestamptz END
+);
+UPDATE posts SET published_at = created_at WHERE published_at IS NULL;
+
+CREATE INDEX idx_posts_status_published_at ON posts(status, published_at);
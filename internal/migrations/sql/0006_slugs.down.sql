@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS slug_redirects;
+ALTER TABLE projects DROP COLUMN IF EXISTS slug;
+ALTER TABLE posts DROP COLUMN IF EXISTS slug;
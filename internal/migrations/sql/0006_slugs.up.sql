@@ -0,0 +1,20 @@
+-- Human-readable URLs for posts and projects. Existing rows use their ID as
+-- slug until their title is next changed.
+ALTER TABLE posts ADD COLUMN slug TEXT;
+UPDATE posts SET slug = id WHERE slug IS NULL;
+ALTER TABLE posts ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE posts ADD CONSTRAINT posts_slug_key UNIQUE (slug);
+
+ALTER TABLE projects ADD COLUMN slug TEXT;
+UPDATE projects SET slug = id WHERE slug IS NULL;
+ALTER TABLE projects ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE projects ADD CONSTRAINT projects_slug_key UNIQUE (slug);
+
+-- Retired slugs keep resolving to the item that used to have them.
+CREATE TABLE slug_redirects (
+    entity_type TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (entity_type, slug)
+);
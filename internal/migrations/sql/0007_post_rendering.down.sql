@@ -0,0 +1,3 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS reading_time;
+ALTER TABLE posts DROP COLUMN IF EXISTS toc;
+ALTER TABLE posts DROP COLUMN IF EXISTS content_html;
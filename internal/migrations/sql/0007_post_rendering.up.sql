@@ -0,0 +1,5 @@
+-- Rendered Markdown is cached next to the source. Existing posts have an
+-- empty content_html and are rendered on read until they are next saved.
+ALTER TABLE posts ADD COLUMN content_html TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN toc JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE posts ADD COLUMN reading_time INTEGER NOT NULL DEFAULT 0;
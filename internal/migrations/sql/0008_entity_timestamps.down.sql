@@ -0,0 +1,27 @@
+ALTER TABLE posts
+    ALTER COLUMN created_at DROP NOT NULL, ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN updated_at DROP NOT NULL, ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE contacts
+    ALTER COLUMN created_at DROP NOT NULL, ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN updated_at DROP NOT NULL, ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE skills
+    ALTER COLUMN created_at DROP NOT NULL, ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN updated_at DROP NOT NULL, ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE projects
+    ALTER COLUMN created_at DROP NOT NULL, ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN updated_at DROP NOT NULL, ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP;
+
+-- Contact ids go back to a serial; ids that are not integers are renumbered.
+CREATE SEQUENCE IF NOT EXISTS contacts_id_seq OWNED BY contacts.id;
+UPDATE contacts SET id = nextval('contacts_id_seq')::text WHERE id !~ '^[0-9]+$';
+ALTER TABLE contacts ALTER COLUMN id TYPE INTEGER USING id::integer;
+SELECT setval('contacts_id_seq', COALESCE((SELECT MAX(id) FROM contacts), 0) + 1, false);
+ALTER TABLE contacts ALTER COLUMN id SET DEFAULT nextval('contacts_id_seq');
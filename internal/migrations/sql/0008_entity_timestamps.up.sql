@@ -0,0 +1,37 @@
+-- IDs are generated by the application (UUIDv7) for every entity, so
+-- contacts drop their serial id. Timestamps become timezone-aware and are
+-- always set.
+ALTER TABLE contacts ALTER COLUMN id DROP DEFAULT;
+ALTER TABLE contacts ALTER COLUMN id TYPE TEXT USING id::text;
+DROP SEQUENCE IF EXISTS contacts_id_seq;
+
+ALTER TABLE projects
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+ALTER TABLE skills
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+ALTER TABLE contacts
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+ALTER TABLE posts
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+
+UPDATE projects SET created_at = COALESCE(created_at, now()), updated_at = COALESCE(updated_at, created_at, now());
+UPDATE skills SET created_at = COALESCE(created_at, now()), updated_at = COALESCE(updated_at, created_at, now());
+UPDATE contacts SET created_at = COALESCE(created_at, now()), updated_at = COALESCE(updated_at, created_at, now());
+UPDATE posts SET created_at = COALESCE(created_at, now()), updated_at = COALESCE(updated_at, created_at, now());
+
+ALTER TABLE projects
+    ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT now(), ALTER COLUMN updated_at SET NOT NULL;
+ALTER TABLE skills
+    ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT now(), ALTER COLUMN updated_at SET NOT NULL;
+ALTER TABLE contacts
+    ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT now(), ALTER COLUMN updated_at SET NOT NULL;
+ALTER TABLE posts
+    ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN created_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT now(), ALTER COLUMN updated_at SET NOT NULL;
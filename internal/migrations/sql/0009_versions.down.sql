@@ -0,0 +1,4 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS version;
+ALTER TABLE contacts DROP COLUMN IF EXISTS version;
+ALTER TABLE skills DROP COLUMN IF EXISTS version;
+ALTER TABLE projects DROP COLUMN IF EXISTS version;
@@ -0,0 +1,6 @@
+-- Every write bumps version; clients send it back in If-Match to detect
+-- concurrent edits.
+ALTER TABLE projects ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE skills ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE contacts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE posts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,10 @@
+-- Items still in the trash are deleted for good.
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+DELETE FROM contacts WHERE deleted_at IS NOT NULL;
+DELETE FROM skills WHERE deleted_at IS NOT NULL;
+DELETE FROM projects WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE contacts DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE skills DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE projects DROP COLUMN IF EXISTS deleted_at;
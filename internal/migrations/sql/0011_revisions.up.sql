@@ -0,0 +1,11 @@
+-- Every saved version of a post or project is kept as a JSON snapshot so
+-- edits can be reviewed and rolled back.
+CREATE TABLE revisions (
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    author_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    snapshot JSONB NOT NULL,
+    PRIMARY KEY (entity_type, entity_id, version)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS skill_translations;
+DROP TABLE IF EXISTS project_translations;
+DROP TABLE IF EXISTS post_translations;
@@ -0,0 +1,28 @@
+-- Posts, projects and skills are stored in the default locale; these tables
+-- hold their translations into the other supported locales.
+CREATE TABLE post_translations (
+    post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    content_html TEXT NOT NULL DEFAULT '',
+    toc JSONB NOT NULL DEFAULT '[]',
+    reading_time INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (post_id, locale)
+);
+
+CREATE TABLE project_translations (
+    project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (project_id, locale)
+);
+
+CREATE TABLE skill_translations (
+    skill_id TEXT NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    name TEXT NOT NULL,
+    category TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (skill_id, locale)
+);
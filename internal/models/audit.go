@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit log actions. Content writes use create, update, delete, restore and
+// rollback; the rest are authentication events.
+const (
+	AuditCreate               = "create"
+	AuditUpdate               = "update"
+	AuditDelete               = "delete"
+	AuditRestore              = "restore"
+	AuditRollback             = "rollback"
+	AuditRegister             = "register"
+	AuditLogin                = "login"
+	AuditLoginFailed          = "login_failed"
+	AuditLogout               = "logout"
+	AuditRefresh              = "refresh"
+	AuditRoleChange           = "role_change"
+	AuditVerifyEmail          = "verify_email"
+	AuditPasswordResetRequest = "password_reset_request"
+	AuditPasswordReset        = "password_reset"
+	AuditLockout              = "lockout"
+)
+
+// Actor is who made a request, as far as the server can tell. UserID is
+// empty for anonymous requests.
+type Actor struct {
+	UserID    string
+	IP        string
+	UserAgent string
+}
+
+// AuditEntry records one write or authentication event. Before and After
+// hold the affected item as JSON, or null where there is none.
+type AuditEntry struct {
+	ID         string          `json:"id"`
+	At         time.Time       `json:"at"`
+	ActorID    string          `json:"actor_id,omitempty"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id,omitempty"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+}
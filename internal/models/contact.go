@@ -1,9 +1,16 @@
 package models
 
+import "time"
+
 type Contact struct {
-    ID       string `json:"id"`
-    Email    string `json:"email"`
-    Telegram string `json:"telegram"`
-    LinkedIn string `json:"linkedin"`
-    Github   string `json:"github"`
+    ID        string    `json:"id"`
+    Email     string    `json:"email" validate:"omitempty,email,max=254"`
+    Telegram  string    `json:"telegram" validate:"max=64"`
+    LinkedIn  string    `json:"linkedin" validate:"omitempty,url,max=2000"`
+    Github    string    `json:"github" validate:"omitempty,url,max=2000"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Version   int       `json:"version"`
+    // DeletedAt is set while the item is in the trash.
+    DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
@@ -0,0 +1,12 @@
+package models
+
+// Entity type names used wherever records of different kinds are mixed, such
+// as search results, slug redirects and the audit log.
+const (
+	EntityPost    = "post"
+	EntityProject = "project"
+	EntitySkill   = "skill"
+	EntityContact = "contact"
+	EntityUser    = "user"
+	EntityMessage = "message"
+)
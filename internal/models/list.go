@@ -0,0 +1,23 @@
+package models
+
+// ListQuery describes one page of a list request.
+type ListQuery struct {
+	// Limit is the page size; zero means the repository default.
+	Limit int
+	// Cursor is the opaque next_cursor value from the previous page.
+	Cursor string
+	// Sort is a field name, optionally prefixed with "-" for descending order.
+	Sort string
+	// Filters holds equality filters such as tag, category or level.
+	Filters map[string]string
+	// PublishedOnly limits posts to those visible to the public; other
+	// entities ignore it.
+	PublishedOnly bool
+}
+
+// Page is one page of list results.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// LocaleSet lists the locales an item can be read in.
+type LocaleSet struct {
+	Default string   `json:"default"`
+	Locales []string `json:"locales"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Outgoing mail statuses. Pending mail is retried until it is sent or has
+// failed too many times.
+const (
+	MailStatusPending = "pending"
+	MailStatusSent    = "sent"
+	MailStatusFailed  = "failed"
+)
+
+// OutgoingMail is a rendered email waiting in the mail queue.
+type OutgoingMail struct {
+	ID      string   `json:"id"`
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text"`
+	HTML    string   `json:"html,omitempty"`
+	Status  string   `json:"status"`
+	// Attempts counts the delivery attempts so far.
+	Attempts int `json:"attempts"`
+	// NextAttemptAt is when delivery is tried next.
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Message statuses. New messages are unread; reading one marks it read and
+// archiving moves it out of the inbox without deleting it.
+const (
+	MessageStatusNew      = "new"
+	MessageStatusRead     = "read"
+	MessageStatusArchived = "archived"
+)
+
+// Message is a contact-form submission from a visitor.
+type Message struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	Status  string `json:"status"`
+	// IP and UserAgent identify the sender for spam handling.
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MessageSubmission is the body of a contact-form post.
+type MessageSubmission struct {
+	Name    string `json:"name" validate:"required,max=100"`
+	Email   string `json:"email" validate:"required,email,max=254"`
+	Subject string `json:"subject" validate:"max=200"`
+	Body    string `json:"body" validate:"required,max=5000"`
+	// Website is a honeypot: the form hides it, so only bots fill it in.
+	Website string `json:"website"`
+	// FormToken is issued with the form and records when it was served.
+	FormToken string `json:"form_token"`
+}
+
+// MessageForm is what a client needs to render the contact form.
+type MessageForm struct {
+	Token string `json:"form_token"`
+	// MinDelaySeconds is how long the form must be open before it is sent.
+	MinDelaySeconds int `json:"min_delay_seconds"`
+}
@@ -1,9 +1,73 @@
 package models
 
+import "time"
+
+// Post workflow states. Only published posts, and scheduled posts whose
+// publish time has passed, are visible to the public.
+const (
+    PostStatusDraft     = "draft"
+    PostStatusScheduled = "scheduled"
+    PostStatusPublished = "published"
+    PostStatusArchived  = "archived"
+)
+
+// ValidPostStatus reports whether status is one of the known post states.
+func ValidPostStatus(status string) bool {
+    switch status {
+    case PostStatusDraft, PostStatusScheduled, PostStatusPublished, PostStatusArchived:
+        return true
+    }
+    return false
+}
+
+// TOCEntry is a heading of the rendered post, linkable by its ID.
+type TOCEntry struct {
+    Level int    `json:"level"`
+    ID    string `json:"id"`
+    Text  string `json:"text"`
+}
+
+// Post content is Markdown. ContentHTML, TOC and ReadingTime (in minutes)
+// are rendered from it on save and stored with the post.
 type Post struct {
-    ID          string   `json:"id"`
-    Title       string   `json:"title"`
-    Content     string   `json:"content"`
-    Tags        []string `json:"tags"`
-    PublishedAt string   `json:"published_at"`
+    ID          string     `json:"id"`
+    Title       string     `json:"title" validate:"required,max=200"`
+    Slug        string     `json:"slug" validate:"max=80"`
+    Content     string     `json:"content" validate:"max=100000"`
+    ContentHTML string     `json:"content_html"`
+    TOC         []TOCEntry `json:"toc"`
+    ReadingTime int        `json:"reading_time"`
+    Tags        []string   `json:"tags" validate:"max=20,dive,required,max=50"`
+    Status      string     `json:"status" validate:"omitempty,oneof=draft scheduled published archived"`
+    PublishedAt *time.Time `json:"published_at"`
+    CreatedAt   time.Time  `json:"created_at"`
+    UpdatedAt   time.Time  `json:"updated_at"`
+    Version     int        `json:"version"`
+    // DeletedAt is set while the item is in the trash.
+    DeletedAt *time.Time `json:"deleted_at,omitempty"`
+    // Locale is the locale the post is presented in. The fields above are
+    // stored in the default locale; Translations holds the others.
+    Locale       string                     `json:"locale,omitempty"`
+    Translations map[string]PostTranslation `json:"translations,omitempty" validate:"max=20,dive"`
+}
+
+// PostTranslation is a post in another locale. Its rendered fields are
+// derived from Content like those of the post.
+type PostTranslation struct {
+    Title       string     `json:"title" validate:"required,max=200"`
+    Content     string     `json:"content" validate:"max=100000"`
+    ContentHTML string     `json:"content_html"`
+    TOC         []TOCEntry `json:"toc"`
+    ReadingTime int        `json:"reading_time"`
+}
+
+// IsVisible reports whether the post can be shown to the public at now.
+func (p *Post) IsVisible(now time.Time) bool {
+    if p.DeletedAt != nil {
+        return false
+    }
+    if p.Status != PostStatusPublished && p.Status != PostStatusScheduled {
+        return false
+    }
+    return p.PublishedAt != nil && !p.PublishedAt.After(now)
 }
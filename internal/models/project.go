@@ -1,9 +1,27 @@
 package models
 
+import "time"
+
 type Project struct {
-    ID          string   `json:"id"`
-    Title       string   `json:"title"`
-    Description string   `json:"description"`
-    Tags        []string `json:"tags"`
-    URL         string   `json:"url"`
+    ID          string    `json:"id"`
+    Title       string    `json:"title" validate:"required,max=200"`
+    Slug        string    `json:"slug" validate:"max=80"`
+    Description string    `json:"description" validate:"max=5000"`
+    Tags        []string  `json:"tags" validate:"max=20,dive,required,max=50"`
+    URL         string    `json:"url" validate:"omitempty,url,max=2000"`
+    CreatedAt   time.Time `json:"created_at"`
+    UpdatedAt   time.Time `json:"updated_at"`
+    Version     int       `json:"version"`
+    // DeletedAt is set while the item is in the trash.
+    DeletedAt *time.Time `json:"deleted_at,omitempty"`
+    // Locale is the locale the project is presented in. The fields above
+    // are stored in the default locale; Translations holds the others.
+    Locale       string                        `json:"locale,omitempty"`
+    Translations map[string]ProjectTranslation `json:"translations,omitempty" validate:"max=20,dive"`
+}
+
+// ProjectTranslation is a project in another locale.
+type ProjectTranslation struct {
+    Title       string `json:"title" validate:"required,max=200"`
+    Description string `json:"description" validate:"max=5000"`
 }
@@ -3,9 +3,11 @@ package models
 import "time"
 
 type RefreshToken struct {
-	JTI       string     `json:"jti"`
-	UserID    string     `json:"user_id"`
-	ExpiresAt time.Time  `json:"expires_at"`
-	RevokedAt *time.Time `json:"revoked_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
+	JTI        string     `json:"jti"`
+	UserID     string     `json:"user_id"`
+	FamilyID   string     `json:"family_id"`
+	ReplacedBy *string    `json:"replaced_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
 }
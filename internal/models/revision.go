@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Revision is a snapshot of a post or project as saved at one version.
+type Revision struct {
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+	Version    int    `json:"version"`
+	// AuthorID is the user who saved the version, empty without auth.
+	AuthorID  string    `json:"author_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Snapshot is the item as JSON. It is left out of revision lists.
+	Snapshot json.RawMessage `json:"snapshot,omitempty"`
+}
+
+// RevisionDiff lists the top-level fields that differ between two revisions.
+type RevisionDiff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// FieldChange is one field of a RevisionDiff. A value missing from a
+// revision is null.
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
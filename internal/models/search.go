@@ -0,0 +1,13 @@
+package models
+
+// SearchResult is one post or project matching a search query. Snippet is
+// HTML-escaped text in which matches are wrapped in <mark> tags.
+type SearchResult struct {
+	Type    string   `json:"type"`
+	ID      string   `json:"id"`
+	Title   string   `json:"title"`
+	Slug    string   `json:"slug"`
+	Snippet string   `json:"snippet"`
+	Tags    []string `json:"tags"`
+	Rank    float64  `json:"rank"`
+}
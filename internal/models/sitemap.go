@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// SitemapEntry is a published post or project listed in the sitemap.
+type SitemapEntry struct {
+	Type      string     `json:"type"`
+	ID        string     `json:"id"`
+	Slug      string     `json:"slug"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
@@ -1,8 +1,25 @@
 package models
 
+import "time"
+
 type Skill struct {
-    ID       string `json:"id"`
-    Name     string `json:"name"`
-    Level    string `json:"level"`
-    Category string `json:"category"`
+    ID        string    `json:"id"`
+    Name      string    `json:"name" validate:"required,max=100"`
+    Level     string    `json:"level" validate:"required,oneof=beginner junior mid senior expert"`
+    Category  string    `json:"category" validate:"max=100"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Version   int       `json:"version"`
+    // DeletedAt is set while the item is in the trash.
+    DeletedAt *time.Time `json:"deleted_at,omitempty"`
+    // Locale is the locale the skill is presented in. The fields above are
+    // stored in the default locale; Translations holds the others.
+    Locale       string                      `json:"locale,omitempty"`
+    Translations map[string]SkillTranslation `json:"translations,omitempty" validate:"max=20,dive"`
+}
+
+// SkillTranslation is a skill in another locale.
+type SkillTranslation struct {
+    Name     string `json:"name" validate:"required,max=100"`
+    Category string `json:"category" validate:"max=100"`
 }
@@ -0,0 +1,8 @@
+package models
+
+// SlugRedirect keeps a retired slug pointing at the item that used to have it.
+type SlugRedirect struct {
+	EntityType string `json:"entity_type"`
+	Slug       string `json:"slug"`
+	TargetID   string `json:"target_id"`
+}
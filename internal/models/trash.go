@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// TrashItem is a soft-deleted item awaiting restore or purge.
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
@@ -2,11 +2,31 @@ package models
 
 import "time"
 
+// User roles, from most to least privileged. Admins and editors may change
+// content; viewers are read-only.
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// ValidRole reports whether role is one of the known user roles.
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleEditor, RoleViewer:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID           string    `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role"`
+	// EmailVerifiedAt is when the user proved they own Email, nil until then.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
 }
 
 type RegisterRequest struct {
@@ -19,12 +39,35 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// AuthResponse is returned on login and registration. A registration that
+// must verify its email before logging in gets no tokens and
+// EmailVerificationRequired set.
 type AuthResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	User         User   `json:"user"`
+	AccessToken               string `json:"access_token,omitempty"`
+	RefreshToken              string `json:"refresh_token,omitempty"`
+	User                      User   `json:"user"`
+	EmailVerificationRequired bool   `json:"email_verification_required,omitempty"`
 }
 
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// EmailRequest asks for a mail to be sent to Email: a new verification link
+// or a password reset link.
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// User token purposes.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenPasswordReset = "password_reset"
+)
+
+// UserToken is a single-use token mailed to a user to prove they own their
+// email address. Only its hash is stored; the token itself is only in the
+// mail.
+type UserToken struct {
+	Hash      string     `json:"-"`
+	UserID    string     `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
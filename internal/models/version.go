@@ -0,0 +1,16 @@
+package models
+
+import "slices"
+
+// IfMatch is the If-Match precondition of a write: the write goes ahead
+// only if the item is at one of Versions. The zero value, used when the
+// request had no If-Match or sent "*", allows any version.
+type IfMatch struct {
+	Enforced bool
+	Versions []int
+}
+
+// Allows reports whether an item at version may be written.
+func (m IfMatch) Allows(version int) bool {
+	return !m.Enforced || slices.Contains(m.Versions, version)
+}
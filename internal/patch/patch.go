@@ -0,0 +1,341 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON values.
+//
+// A malformed patch is reported as apperr bad_request. A well-formed patch
+// that does not fit the document, such as a path that does not exist or a
+// failed test operation, is reported as apperr conflict.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+)
+
+// Media types of the two patch formats.
+const (
+	MediaTypeMerge = "application/merge-patch+json"
+	MediaTypeJSON  = "application/json-patch+json"
+)
+
+// Merge applies the merge patch p to doc. Objects in p are merged into doc
+// recursively, null removes a member and any other value replaces it.
+func Merge(doc, p []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := decode(p)
+	if err != nil {
+		return nil, apperr.BadRequest("merge patch is not valid JSON")
+	}
+	return json.Marshal(merge(target, patch))
+}
+
+func merge(target, patch any) any {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	obj, ok := target.(map[string]any)
+	if !ok {
+		obj = map[string]any{}
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(obj, name)
+		} else {
+			obj[name] = merge(obj[name], value)
+		}
+	}
+	return obj
+}
+
+// operation is one step of a JSON Patch. Value is kept raw so that an
+// explicit null can be told apart from a missing value.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply applies the JSON Patch p, an array of operations, to doc. The
+// operations are applied in order and either all succeed or doc is left
+// unchanged.
+func Apply(doc, p []byte) ([]byte, error) {
+	var ops []operation
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ops); err != nil {
+		return nil, apperr.BadRequest("JSON patch must be an array of operations")
+	}
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		if target, err = op.apply(target); err != nil {
+			if e, ok := err.(*apperr.Error); ok {
+				e.Message = fmt.Sprintf("operation %d (%s): %s", i, op.Op, e.Message)
+			}
+			return nil, err
+		}
+	}
+	return json.Marshal(target)
+}
+
+func (op operation) apply(doc any) (any, error) {
+	if op.Path == nil {
+		return nil, apperr.BadRequest("path is required")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, apperr.BadRequest("value is required")
+		}
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, apperr.BadRequest("value is not valid JSON")
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			return replace(doc, path, value)
+		}
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, apperr.Conflict("test failed at " + *op.Path)
+		}
+		return doc, nil
+	case "remove":
+		return remove(doc, path)
+	case "move", "copy":
+		if op.From == nil {
+			return nil, apperr.BadRequest("from is required")
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			// Round-trip so the copy shares nothing with the original.
+			raw, _ := json.Marshal(value)
+			value, _ = decode(raw)
+			return add(doc, path, value)
+		}
+		if len(from) < len(path) && isPrefix(from, path) {
+			return nil, apperr.Conflict("cannot move a value into itself")
+		}
+		if doc, err = remove(doc, from); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	}
+	return nil, apperr.BadRequest(fmt.Sprintf("unknown op %q", op.Op))
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped tokens.
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, apperr.BadRequest(fmt.Sprintf("path %q must start with /", s))
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, missing(token)
+			}
+			doc = value
+		case []any:
+			i, err := index(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, missing(token)
+		}
+	}
+	return doc, nil
+}
+
+func add(doc any, path []string, value any) (any, error) {
+	return edit(doc, path, value, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			i := len(node)
+			if token != "-" {
+				var err error
+				if i, err = index(token, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			return append(node[:i], append([]any{value}, node[i:]...)...), nil
+		}
+		return nil, missing(token)
+	})
+}
+
+func replace(doc any, path []string, value any) (any, error) {
+	return edit(doc, path, value, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, missing(token)
+			}
+			node[token] = value
+			return node, nil
+		case []any:
+			i, err := index(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = value
+			return node, nil
+		}
+		return nil, missing(token)
+	})
+}
+
+func remove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, apperr.Conflict("cannot remove the whole document")
+	}
+	return edit(doc, path, nil, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			if _, ok := node[token]; !ok {
+				return nil, missing(token)
+			}
+			delete(node, token)
+			return node, nil
+		case []any:
+			i, err := index(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, missing(token)
+	})
+}
+
+// edit walks to the parent of the last token of path and replaces it with
+// the result of leaf. An empty path replaces the whole document with value.
+func edit(doc any, path []string, value any, leaf func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	if len(path) == 1 {
+		return leaf(doc, path[0])
+	}
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, missing(token)
+		}
+		child, err := edit(child, path[1:], value, leaf)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []any:
+		i, err := index(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := edit(node[i], path[1:], value, leaf)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+	return nil, missing(token)
+}
+
+// index parses an array index token no greater than max. RFC 6901 allows
+// only 0 or digits without a leading zero, so signs and spaces are rejected.
+func index(token string, max int) (int, error) {
+	if !isIndex(token) {
+		return 0, apperr.BadRequest(fmt.Sprintf("%q is not an array index", token))
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, apperr.Conflict(fmt.Sprintf("array index %s is out of range", token))
+	}
+	if i > max {
+		return 0, apperr.Conflict(fmt.Sprintf("array index %d is out of range", i))
+	}
+	return i, nil
+}
+
+func isIndex(token string) bool {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func missing(token string) error {
+	return apperr.Conflict(fmt.Sprintf("path member %q does not exist", token))
+}
+
+func decode(data []byte) (any, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,174 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+)
+
+const doc = `{"title":"t","tags":["a","b","c"],"meta":{"n":1,"z":null}}`
+
+// codeOf returns the apperr code of err, or "" if err is nil.
+func codeOf(t *testing.T, err error) apperr.Code {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var e *apperr.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("error %v is not an apperr.Error", err)
+	}
+	return e.Code
+}
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result is not JSON: %s", got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("bad expectation %s", want)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name, patch, want string
+		code              apperr.Code
+	}{
+		{"add member", `[{"op":"add","path":"/slug","value":"s"}]`, `{"title":"t","slug":"s","tags":["a","b","c"],"meta":{"n":1,"z":null}}`, ""},
+		{"add replaces member", `[{"op":"add","path":"/title","value":"u"}]`, `{"title":"u","tags":["a","b","c"],"meta":{"n":1,"z":null}}`, ""},
+		{"add inserts", `[{"op":"add","path":"/tags/1","value":"x"}]`, `{"title":"t","tags":["a","x","b","c"],"meta":{"n":1,"z":null}}`, ""},
+		{"add at end index", `[{"op":"add","path":"/tags/3","value":"x"}]`, `{"title":"t","tags":["a","b","c","x"],"meta":{"n":1,"z":null}}`, ""},
+		{"add dash appends", `[{"op":"add","path":"/tags/-","value":"x"}]`, `{"title":"t","tags":["a","b","c","x"],"meta":{"n":1,"z":null}}`, ""},
+		{"add past end", `[{"op":"add","path":"/tags/4","value":"x"}]`, "", apperr.CodeConflict},
+		{"add to missing parent", `[{"op":"add","path":"/nope/x","value":1}]`, "", apperr.CodeConflict},
+		{"add null", `[{"op":"add","path":"/slug","value":null}]`, `{"title":"t","slug":null,"tags":["a","b","c"],"meta":{"n":1,"z":null}}`, ""},
+		{"add without value", `[{"op":"add","path":"/slug"}]`, "", apperr.CodeBadRequest},
+		{"replace", `[{"op":"replace","path":"/tags/0","value":"z"}]`, `{"title":"t","tags":["z","b","c"],"meta":{"n":1,"z":null}}`, ""},
+		{"replace missing", `[{"op":"replace","path":"/slug","value":"s"}]`, "", apperr.CodeConflict},
+		{"replace dash", `[{"op":"replace","path":"/tags/-","value":"s"}]`, "", apperr.CodeBadRequest},
+		{"remove", `[{"op":"remove","path":"/tags/1"}]`, `{"title":"t","tags":["a","c"],"meta":{"n":1,"z":null}}`, ""},
+		{"remove member", `[{"op":"remove","path":"/meta/n"}]`, `{"title":"t","tags":["a","b","c"],"meta":{"z":null}}`, ""},
+		{"remove missing", `[{"op":"remove","path":"/tags/3"}]`, "", apperr.CodeConflict},
+		{"remove root", `[{"op":"remove","path":""}]`, "", apperr.CodeConflict},
+		{"move", `[{"op":"move","from":"/title","path":"/slug"}]`, `{"slug":"t","tags":["a","b","c"],"meta":{"n":1,"z":null}}`, ""},
+		{"move within array", `[{"op":"move","from":"/tags/0","path":"/tags/-"}]`, `{"title":"t","tags":["b","c","a"],"meta":{"n":1,"z":null}}`, ""},
+		{"move into itself", `[{"op":"move","from":"/meta","path":"/meta/inner"}]`, "", apperr.CodeConflict},
+		{"move onto itself", `[{"op":"move","from":"/meta","path":"/meta"}]`, doc, ""},
+		{"move to sibling prefix", `[{"op":"move","from":"/meta","path":"/metadata"}]`, `{"title":"t","tags":["a","b","c"],"metadata":{"n":1,"z":null}}`, ""},
+		{"move without from", `[{"op":"move","path":"/x"}]`, "", apperr.CodeBadRequest},
+		{"copy", `[{"op":"copy","from":"/meta","path":"/copy"},{"op":"replace","path":"/copy/n","value":2}]`, `{"title":"t","tags":["a","b","c"],"meta":{"n":1,"z":null},"copy":{"n":2,"z":null}}`, ""},
+		{"test string", `[{"op":"test","path":"/title","value":"t"}]`, doc, ""},
+		{"test number", `[{"op":"test","path":"/meta/n","value":1.0}]`, doc, ""},
+		{"test number differs", `[{"op":"test","path":"/meta/n","value":2}]`, "", apperr.CodeConflict},
+		{"test number against string", `[{"op":"test","path":"/meta/n","value":"1"}]`, "", apperr.CodeConflict},
+		{"test null", `[{"op":"test","path":"/meta/z","value":null}]`, doc, ""},
+		{"test null against missing", `[{"op":"test","path":"/meta/missing","value":null}]`, "", apperr.CodeConflict},
+		{"test array", `[{"op":"test","path":"/tags","value":["a","b","c"]}]`, doc, ""},
+		{"test without value", `[{"op":"test","path":"/title"}]`, "", apperr.CodeBadRequest},
+		{"escaped pointer", `[{"op":"add","path":"/a~1b~0c","value":1}]`, `{"title":"t","a/b~c":1,"tags":["a","b","c"],"meta":{"n":1,"z":null}}`, ""},
+		{"unknown op", `[{"op":"frob","path":"/title"}]`, "", apperr.CodeBadRequest},
+		{"unknown field", `[{"op":"add","path":"/x","value":1,"extra":true}]`, "", apperr.CodeBadRequest},
+		{"not an array", `{"op":"add","path":"/x","value":1}`, "", apperr.CodeBadRequest},
+		{"relative path", `[{"op":"add","path":"x","value":1}]`, "", apperr.CodeBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(doc), []byte(tt.patch))
+			if code := codeOf(t, err); code != tt.code {
+				t.Fatalf("error = %v, want code %q", err, tt.code)
+			}
+			if tt.code == "" {
+				assertJSON(t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRejectsNonCanonicalIndices(t *testing.T) {
+	for _, token := range []string{"+1", "-0", "-1", " 1", "1 ", "01", "00", "1e0", "0x1", "1.0", "１", ""} {
+		for _, op := range []string{
+			`{"op":"add","path":"/tags/` + token + `","value":"x"}`,
+			`{"op":"replace","path":"/tags/` + token + `","value":"x"}`,
+			`{"op":"remove","path":"/tags/` + token + `"}`,
+			`{"op":"test","path":"/tags/` + token + `","value":"b"}`,
+			`{"op":"copy","from":"/tags/` + token + `","path":"/x"}`,
+		} {
+			_, err := Apply([]byte(doc), []byte("["+op+"]"))
+			if code := codeOf(t, err); code != apperr.CodeBadRequest {
+				t.Errorf("%s: error = %v, want bad_request", op, err)
+			}
+		}
+	}
+}
+
+func TestApplyIndexOutOfRange(t *testing.T) {
+	for _, token := range []string{"3", "99999999999999999999999"} {
+		_, err := Apply([]byte(doc), []byte(`[{"op":"replace","path":"/tags/`+token+`","value":"x"}]`))
+		if code := codeOf(t, err); code != apperr.CodeConflict {
+			t.Errorf("index %s: error = %v, want conflict", token, err)
+		}
+	}
+}
+
+func TestApplyIsAllOrNothing(t *testing.T) {
+	original := []byte(doc)
+	p := `[
+		{"op":"replace","path":"/title","value":"changed"},
+		{"op":"remove","path":"/tags/0"},
+		{"op":"add","path":"/meta/x","value":1},
+		{"op":"test","path":"/title","value":"t"}
+	]`
+	got, err := Apply(original, []byte(p))
+	if codeOf(t, err) != apperr.CodeConflict {
+		t.Fatalf("error = %v, want conflict from the failed test", err)
+	}
+	if got != nil {
+		t.Errorf("a failed patch returned a document: %s", got)
+	}
+	if string(original) != doc {
+		t.Errorf("the document was modified: %s", original)
+	}
+	var e *apperr.Error
+	errors.As(err, &e)
+	if want := "operation 3 (test): test failed at /title"; e.Message != want {
+		t.Errorf("message = %q, want %q", e.Message, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name, patch, want string
+	}{
+		{"set member", `{"title":"u"}`, `{"title":"u","tags":["a","b","c"],"meta":{"n":1,"z":null}}`},
+		{"null removes", `{"title":null}`, `{"tags":["a","b","c"],"meta":{"n":1,"z":null}}`},
+		{"null removes nested", `{"meta":{"z":null}}`, `{"title":"t","tags":["a","b","c"],"meta":{"n":1}}`},
+		{"null for missing member", `{"nope":null}`, doc},
+		{"nested merge", `{"meta":{"m":2}}`, `{"title":"t","tags":["a","b","c"],"meta":{"n":1,"z":null,"m":2}}`},
+		{"arrays are replaced", `{"tags":["x"]}`, `{"title":"t","tags":["x"],"meta":{"n":1,"z":null}}`},
+		{"object replaces scalar", `{"title":{"a":1,"b":null}}`, `{"title":{"a":1},"tags":["a","b","c"],"meta":{"n":1,"z":null}}`},
+		{"non-object replaces document", `["x"]`, `["x"]`},
+		{"empty patch", `{}`, doc},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Merge([]byte(doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+
+	if _, err := Merge([]byte(doc), []byte(`{"title":`)); codeOf(t, err) != apperr.CodeBadRequest {
+		t.Errorf("invalid merge patch: error = %v, want bad_request", err)
+	}
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// backoffForget is how long a key's failures are remembered after the last
+// one.
+const backoffForget = 24 * time.Hour
+
+// Backoff counts consecutive failures per key, such as failed logins for an
+// account, and makes the key wait before its next attempt. The first free
+// failures cost nothing; each further failure makes the key wait base,
+// doubling every time, and failure number lockout locks the key for lockFor.
+// From then on every failure locks it again until a success resets it or it
+// has been quiet for a day.
+type Backoff struct {
+	free, lockout int
+	base, lockFor time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]*failures
+	lastSweep time.Time
+}
+
+type failures struct {
+	n     int
+	last  time.Time
+	until time.Time
+}
+
+// NewBackoff returns a Backoff with the given policy. A lockout of zero or
+// less turns it off: no key ever waits.
+func NewBackoff(free, lockout int, base, lockFor time.Duration) *Backoff {
+	return &Backoff{free: free, lockout: lockout, base: base, lockFor: lockFor, keys: map[string]*failures{}}
+}
+
+// Wait returns how long key has to wait at now before its next attempt, or
+// zero if it may try.
+func (b *Backoff) Wait(key string, now time.Time) time.Duration {
+	if b.lockout <= 0 {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if f := b.keys[key]; f != nil && f.until.After(now) {
+		return f.until.Sub(now)
+	}
+	return 0
+}
+
+// Fail records a failed attempt for key at now. It returns how long the key
+// now has to wait and whether this failure is the one that locked it.
+func (b *Backoff) Fail(key string, now time.Time) (wait time.Duration, locked bool) {
+	if b.lockout <= 0 {
+		return 0, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep(now)
+	f := b.keys[key]
+	if f == nil || now.Sub(f.last) >= backoffForget {
+		f = &failures{}
+		b.keys[key] = f
+	}
+	f.n++
+	f.last = now
+	switch {
+	case f.n >= b.lockout:
+		wait = b.lockFor
+	case f.n > b.free:
+		wait = b.base
+		for i := b.free + 1; i < f.n && wait < b.lockFor; i++ {
+			wait *= 2
+		}
+		wait = min(wait, b.lockFor)
+	}
+	f.until = now.Add(wait)
+	return wait, f.n == b.lockout
+}
+
+// Reset forgets the failures of key, as after a successful attempt.
+func (b *Backoff) Reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.keys, key)
+}
+
+// sweep drops keys that have been quiet for backoffForget, at most once an
+// hour.
+func (b *Backoff) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < time.Hour {
+		return
+	}
+	for key, f := range b.keys {
+		if now.Sub(f.last) >= backoffForget && !f.until.After(now) {
+			delete(b.keys, key)
+		}
+	}
+	b.lastSweep = now
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestBackoffEscalatesToLockout(t *testing.T) {
+	b := NewBackoff(2, 5, time.Second, 15*time.Minute)
+	tests := []struct {
+		wait   time.Duration
+		locked bool
+	}{
+		{0, false},
+		{0, false},
+		{time.Second, false},
+		{2 * time.Second, false},
+		{15 * time.Minute, true},
+		{15 * time.Minute, false},
+	}
+	now := start
+	for i, tt := range tests {
+		wait, locked := b.Fail("user", now)
+		if wait != tt.wait || locked != tt.locked {
+			t.Errorf("failure %d: Fail = %v, %v; want %v, %v", i+1, wait, locked, tt.wait, tt.locked)
+		}
+		if got := b.Wait("user", now); got != tt.wait {
+			t.Errorf("failure %d: Wait = %v, want %v", i+1, got, tt.wait)
+		}
+		now = now.Add(tt.wait)
+		if got := b.Wait("user", now); got != 0 {
+			t.Errorf("failure %d: still waiting %v once the wait is over", i+1, got)
+		}
+	}
+	if got := b.Wait("other", now); got != 0 {
+		t.Errorf("another key waits %v", got)
+	}
+}
+
+func TestBackoffWaitCountsDown(t *testing.T) {
+	b := NewBackoff(0, 3, time.Minute, time.Hour)
+	b.Fail("user", start)
+	b.Fail("user", start)
+	b.Fail("user", start)
+	for _, tt := range []struct {
+		after, want time.Duration
+	}{
+		{0, time.Hour},
+		{time.Second, time.Hour - time.Second},
+		{59 * time.Minute, time.Minute},
+		{time.Hour, 0},
+		{2 * time.Hour, 0},
+	} {
+		if got := b.Wait("user", start.Add(tt.after)); got != tt.want {
+			t.Errorf("Wait %v after the lockout = %v, want %v", tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffCapsWaitAtLockFor(t *testing.T) {
+	b := NewBackoff(0, 100, time.Minute, 10*time.Minute)
+	var wait time.Duration
+	for i := 0; i < 10; i++ {
+		wait, _ = b.Fail("user", start)
+	}
+	if wait != 10*time.Minute {
+		t.Errorf("wait after 10 failures = %v, want the 10m cap", wait)
+	}
+}
+
+func TestBackoffReset(t *testing.T) {
+	b := NewBackoff(1, 3, time.Minute, time.Hour)
+	for i := 0; i < 3; i++ {
+		b.Fail("user", start)
+	}
+	b.Reset("user")
+	if got := b.Wait("user", start); got != 0 {
+		t.Fatalf("Wait after Reset = %v", got)
+	}
+	// The count starts over, free failures included.
+	if wait, _ := b.Fail("user", start); wait != 0 {
+		t.Errorf("first failure after Reset waits %v", wait)
+	}
+	if wait, _ := b.Fail("user", start); wait != time.Minute {
+		t.Errorf("second failure after Reset waits %v, want 1m", wait)
+	}
+}
+
+func TestBackoffForgetsQuietKeys(t *testing.T) {
+	b := NewBackoff(1, 3, time.Minute, time.Hour)
+	b.Fail("user", start)
+	b.Fail("user", start)
+
+	// A failure just under a day later still counts.
+	if wait, locked := b.Fail("user", start.Add(backoffForget-time.Second)); !locked || wait != time.Hour {
+		t.Errorf("failure within a day: Fail = %v, %v; want locked for 1h", wait, locked)
+	}
+
+	last := start.Add(backoffForget - time.Second)
+	if wait, _ := b.Fail("user", last.Add(backoffForget)); wait != 0 {
+		t.Errorf("first failure after a quiet day waits %v", wait)
+	}
+
+	// Quiet keys are dropped from memory.
+	b.Fail("other", last.Add(3*backoffForget))
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.keys["user"]; ok || len(b.keys) != 1 {
+		t.Errorf("keys after a quiet day: %v", b.keys)
+	}
+}
+
+func TestBackoffDisabled(t *testing.T) {
+	b := NewBackoff(0, 0, time.Minute, time.Hour)
+	for i := 0; i < 10; i++ {
+		if wait, locked := b.Fail("user", start); wait != 0 || locked {
+			t.Fatalf("disabled backoff: Fail = %v, %v", wait, locked)
+		}
+	}
+	if got := b.Wait("user", start); got != 0 {
+		t.Errorf("disabled backoff: Wait = %v", got)
+	}
+}
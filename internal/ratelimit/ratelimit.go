@@ -0,0 +1,67 @@
+// Package ratelimit counts events per key, such as a client IP: a Limiter
+// caps events in fixed time windows, and a Backoff slows down and locks out
+// keys with repeated failures. Counters live in memory, so they reset on
+// restart and are not shared between instances.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to limit events per key in each window.
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	start time.Time
+	n     int
+}
+
+// New returns a Limiter allowing limit events per key per window. A limit
+// of zero or less allows everything.
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{limit: limit, window: window, buckets: map[string]*bucket{}}
+}
+
+// Allow records an event for key at now and reports whether it is within
+// the limit. If it is not, retryAfter is the time left until the key's window
+// resets.
+func (l *Limiter) Allow(key string, now time.Time) (ok bool, retryAfter time.Duration) {
+	if l.limit <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b := l.buckets[key]
+	if b == nil || now.Sub(b.start) >= l.window {
+		b = &bucket{start: now}
+		l.buckets[key] = b
+	}
+	if b.n >= l.limit {
+		return false, b.start.Add(l.window).Sub(now)
+	}
+	b.n++
+	return true, 0
+}
+
+// sweep drops expired buckets, at most once per window.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.start) >= l.window {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
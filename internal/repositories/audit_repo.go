@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// AuditRepository is an append-only log of writes and authentication events.
+// Entries are listed newest first and can be filtered by actor, action,
+// entity_type, entity_id, and by since and until, RFC 3339 times that bound
+// the entry time inclusively and exclusively.
+type AuditRepository interface {
+	Append(entry *models.AuditEntry) error
+	ListPage(q models.ListQuery) (*models.Page[models.AuditEntry], error)
+}
+
+type PGAuditRepository struct {
+	db *sql.DB
+}
+
+const auditColumns = `id, at, COALESCE(actor_id, ''), action, entity_type, COALESCE(entity_id, ''), before, after, COALESCE(ip, ''), COALESCE(user_agent, '')`
+
+var auditListSpec = pgListSpec{
+	table:   "audit_log",
+	columns: auditColumns,
+	idExpr:  "id",
+	sorts: map[string]string{
+		"at": pgTimeSortKey("at"),
+	},
+	defaultSort: "-at",
+	filters: map[string]string{
+		"actor":       "actor_id = ?",
+		"action":      "action = ?",
+		"entity_type": "entity_type = ?",
+		"entity_id":   "entity_id = ?",
+		"since":       "at >= ?",
+		"until":       "at < ?",
+	},
+	noTrash: true,
+}
+
+func NewPGAuditRepository(db *sql.DB) *PGAuditRepository {
+	return &PGAuditRepository{db: db}
+}
+
+func (r *PGAuditRepository) Append(entry *models.AuditEntry) error {
+	query := `INSERT INTO audit_log (id, at, actor_id, action, entity_type, entity_id, before, after, ip, user_agent)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), $7, $8, NULLIF($9, ''), NULLIF($10, ''))`
+	_, err := r.db.Exec(query, entry.ID, entry.At, entry.ActorID, entry.Action, entry.EntityType, entry.EntityID,
+		jsonbArg(entry.Before), jsonbArg(entry.After), entry.IP, entry.UserAgent)
+	return pgError(err)
+}
+
+func (r *PGAuditRepository) ListPage(q models.ListQuery) (*models.Page[models.AuditEntry], error) {
+	return pgListPage(r.db, auditListSpec, q, func(rows *sql.Rows, sortKey, idKey *string) (models.AuditEntry, error) {
+		var e models.AuditEntry
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.At, &e.ActorID, &e.Action, &e.EntityType, &e.EntityID, &before, &after, &e.IP, &e.UserAgent, sortKey, idKey)
+		e.Before, e.After = before, after
+		return e, err
+	})
+}
+
+// jsonbArg passes raw JSON as a JSONB parameter; nil becomes NULL.
+func jsonbArg(raw json.RawMessage) any {
+	if raw == nil {
+		return nil
+	}
+	return string(raw)
+}
+
+// JSONAuditRepository appends entries to a JSON Lines file, one entry per
+// line. The file is created on the first write and never rewritten.
+type JSONAuditRepository struct {
+	path     string
+	writable bool
+	mu       sync.Mutex
+}
+
+var jsonAuditListSpec = jsonListSpec[models.AuditEntry]{
+	id: func(e models.AuditEntry) string { return e.ID },
+	sorts: map[string]func(models.AuditEntry) string{
+		"at": func(e models.AuditEntry) string { return timeSortKey(&e.At) },
+	},
+	defaultSort: "-at",
+	filters: map[string]func(models.AuditEntry, string) bool{
+		"actor":       func(e models.AuditEntry, v string) bool { return e.ActorID == v },
+		"action":      func(e models.AuditEntry, v string) bool { return e.Action == v },
+		"entity_type": func(e models.AuditEntry, v string) bool { return e.EntityType == v },
+		"entity_id":   func(e models.AuditEntry, v string) bool { return e.EntityID == v },
+		"since": func(e models.AuditEntry, v string) bool {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			return err == nil && !e.At.Before(t)
+		},
+		"until": func(e models.AuditEntry, v string) bool {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			return err == nil && e.At.Before(t)
+		},
+	},
+}
+
+func NewJSONAuditRepository(path string) *JSONAuditRepository {
+	return &JSONAuditRepository{path: path}
+}
+
+func NewWritableJSONAuditRepository(path string) *JSONAuditRepository {
+	return &JSONAuditRepository{path: path, writable: true}
+}
+
+func (r *JSONAuditRepository) Append(entry *models.AuditEntry) error {
+	if !r.writable {
+		return ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return appendJSONLine(r.path, entry)
+}
+
+func (r *JSONAuditRepository) ListPage(q models.ListQuery) (*models.Page[models.AuditEntry], error) {
+	entries, err := readJSONLines[models.AuditEntry](r.path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonListPage(entries, jsonAuditListSpec, q)
+}
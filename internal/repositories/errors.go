@@ -1,5 +1,80 @@
 package repositories
 
-import "errors"
+import (
+	"database/sql"
+	"errors"
 
-var ErrReadOnly = errors.New("write not supported in JSON mode")
\ No newline at end of file
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrReadOnly  = apperr.ReadOnly("write operations not supported in JSON mode")
+	ErrNotFound  = apperr.NotFound("item not found")
+	ErrConflict  = apperr.Conflict("item with this id already exists")
+	ErrMissingID = apperr.Validation(apperr.Field("id", "is required"))
+	// ErrStale means the item's version changed since the caller read it.
+	ErrStale = apperr.New(apperr.CodePreconditionFailed, "item was changed by another request")
+)
+
+// pgError turns constraint violations into domain errors. Anything else is
+// returned unchanged and reported to clients as an internal error.
+func pgError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code.Name() {
+	case "unique_violation":
+		if pqErr.Constraint == "posts_slug_key" || pqErr.Constraint == "projects_slug_key" {
+			return &apperr.Error{Code: apperr.CodeConflict, Message: "slug is already in use", Err: err}
+		}
+		return &apperr.Error{Code: apperr.CodeConflict, Message: ErrConflict.Message, Err: err}
+	case "check_violation", "not_null_violation", "foreign_key_violation":
+		return &apperr.Error{Code: apperr.CodeValidation, Message: "request violates a data constraint", Err: err}
+	case "invalid_text_representation":
+		return &apperr.Error{Code: apperr.CodeBadRequest, Message: "malformed value", Err: err}
+	}
+	return err
+}
+
+// pgUpdated maps the error of an UPDATE ... RETURNING scan: no row means the
+// item does not exist.
+func pgUpdated(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return pgError(err)
+}
+
+// pgStale maps the error of a versioned UPDATE ... RETURNING that matched
+// no row: either the item is gone (or in the trash) or its version has
+// moved on.
+func pgStale(q pgQuerier, table, id string, err error) error {
+	if err != sql.ErrNoRows {
+		return pgError(err)
+	}
+	var exists bool
+	if err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = $1 AND `+pgLive+`)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrStale
+	}
+	return ErrNotFound
+}
+
+// pgDeleted maps the result of a versioned DELETE like pgStale.
+func pgDeleted(q pgQuerier, table, id string, res sql.Result, err error) error {
+	if err != nil {
+		return pgError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return pgStale(q, table, id, sql.ErrNoRows)
+	}
+	return nil
+}
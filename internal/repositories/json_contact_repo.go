@@ -1,63 +1,115 @@
 package repositories
 
 import (
-    "encoding/json"
-    "os"
+    "time"
 
     "github.com/ScriptVandal/backend-go/internal/models"
 )
 
 type ContactRepository interface {
     List() ([]models.Contact, error)
+    ListPage(q models.ListQuery) (*models.Page[models.Contact], error)
     GetByID(id string) (*models.Contact, error)
     Create(contact *models.Contact) error
     Update(contact *models.Contact) error
-    Delete(id string) error
+    Modify(id string, fn func(*models.Contact) error) (*models.Contact, error)
+    Delete(id string, version int) error
+    Restore(id string) (*models.Contact, error)
+    ListDeleted() ([]models.Contact, error)
+    Purge(before time.Time) (int, error)
 }
 
+var jsonContactListSpec = jsonListSpec[models.Contact]{
+    id: func(c models.Contact) string { return c.ID },
+    sorts: map[string]func(models.Contact) string{
+        "created_at": func(c models.Contact) string { return timeSortKey(&c.CreatedAt) },
+        "updated_at": func(c models.Contact) string { return timeSortKey(&c.UpdatedAt) },
+        "id":         func(c models.Contact) string { return c.ID },
+    },
+    defaultSort: "id",
+}
+
+// JSONContactRepository implements ContactRepository using local JSON file.
+// It is read-only unless created with NewWritableJSONContactRepository.
 type JSONContactRepository struct {
-    path string
+    store    *jsonStore[models.Contact]
+    writable bool
 }
 
 func NewJSONContactRepository(path string) *JSONContactRepository {
-    return &JSONContactRepository{path: path}
+    store := newJSONStore(path, func(item models.Contact) string { return item.ID })
+    store.versioned(func(item *models.Contact) *int { return &item.Version })
+    store.softDeleted(func(item *models.Contact) **time.Time { return &item.DeletedAt })
+    return &JSONContactRepository{store: store}
+}
+
+func NewWritableJSONContactRepository(path string) *JSONContactRepository {
+    r := NewJSONContactRepository(path)
+    r.writable = true
+    return r
 }
 
 func (r *JSONContactRepository) List() ([]models.Contact, error) {
-    f, err := os.Open(r.path)
+    return r.store.live()
+}
+
+func (r *JSONContactRepository) ListPage(q models.ListQuery) (*models.Page[models.Contact], error) {
+    items, err := r.store.live()
     if err != nil {
         return nil, err
     }
-    defer f.Close()
+    return jsonListPage(items, jsonContactListSpec, q)
+}
 
-    var items []models.Contact
-    if err := json.NewDecoder(f).Decode(&items); err != nil {
-        return nil, err
+func (r *JSONContactRepository) GetByID(id string) (*models.Contact, error) {
+    return r.store.get(id)
+}
+
+func (r *JSONContactRepository) Create(contact *models.Contact) error {
+    if !r.writable {
+        return ErrReadOnly
     }
-    return items, nil
+    return r.store.insert(*contact)
 }
 
-func (r *JSONContactRepository) GetByID(id string) (*models.Contact, error) {
-    items, err := r.List()
-    if err != nil {
-        return nil, err
+func (r *JSONContactRepository) Update(contact *models.Contact) error {
+    if !r.writable {
+        return ErrReadOnly
     }
-    for _, item := range items {
-        if item.ID == id {
-            return &item, nil
-        }
+    return r.store.replace(contact)
+}
+
+func (r *JSONContactRepository) Modify(id string, fn func(*models.Contact) error) (*models.Contact, error) {
+    if !r.writable {
+        return nil, ErrReadOnly
     }
-    return nil, nil
+    return r.store.modify(id, fn)
 }
 
-func (r *JSONContactRepository) Create(contact *models.Contact) error {
-    return ErrReadOnly
+// Delete moves the contact to the trash if it is still at version.
+func (r *JSONContactRepository) Delete(id string, version int) error {
+    if !r.writable {
+        return ErrReadOnly
+    }
+    return r.store.trash(id, version, time.Now().UTC().Truncate(time.Microsecond))
 }
 
-func (r *JSONContactRepository) Update(contact *models.Contact) error {
-    return ErrReadOnly
+func (r *JSONContactRepository) Restore(id string) (*models.Contact, error) {
+    if !r.writable {
+        return nil, ErrReadOnly
+    }
+    return r.store.restore(id)
 }
 
-func (r *JSONContactRepository) Delete(id string) error {
-    return ErrReadOnly
+func (r *JSONContactRepository) ListDeleted() ([]models.Contact, error) {
+    return r.store.deleted()
+}
+
+// Purge permanently deletes contacts moved to the trash before before.
+// Read-only repositories have nothing to purge.
+func (r *JSONContactRepository) Purge(before time.Time) (int, error) {
+    if !r.writable {
+        return 0, nil
+    }
+    return r.store.purge(before, nil)
 }
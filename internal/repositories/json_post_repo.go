@@ -1,64 +1,185 @@
 package repositories
 
 import (
-    "encoding/json"
-    "os"
+    "slices"
+    "time"
 
     "github.com/ScriptVandal/backend-go/internal/models"
 )
 
 type PostRepository interface {
     List() ([]models.Post, error)
+    ListPage(q models.ListQuery) (*models.Page[models.Post], error)
     GetByID(id string) (*models.Post, error)
+    GetBySlug(slug string) (*models.Post, error)
+    SlugTaken(slug, exceptID string) (bool, error)
     Create(post *models.Post) error
     Update(post *models.Post) error
-    Delete(id string) error
+    Modify(id string, fn func(*models.Post) error) (*models.Post, error)
+    Delete(id string, version int) error
+    Restore(id string) (*models.Post, error)
+    ListDeleted() ([]models.Post, error)
+    Purge(before time.Time) (int, error)
+    PublishDue(now time.Time) (int, error)
+}
+
+var jsonPostListSpec = jsonListSpec[models.Post]{
+    id: func(p models.Post) string { return p.ID },
+    sorts: map[string]func(models.Post) string{
+        "published_at": func(p models.Post) string { return timeSortKey(p.PublishedAt) },
+        "title":        func(p models.Post) string { return p.Title },
+        "created_at":   func(p models.Post) string { return timeSortKey(&p.CreatedAt) },
+        "updated_at":   func(p models.Post) string { return timeSortKey(&p.UpdatedAt) },
+        "id":           func(p models.Post) string { return p.ID },
+    },
+    defaultSort: "-published_at",
+    filters: map[string]func(models.Post, string) bool{
+        "tag":    func(p models.Post, v string) bool { return slices.Contains(p.Tags, v) },
+        "status": func(p models.Post, v string) bool { return p.Status == v },
+    },
+    published: func(p models.Post) bool { return p.IsVisible(time.Now()) },
 }
 
 // JSONPostRepository implements PostRepository using local JSON file.
+// It is read-only unless created with NewWritableJSONPostRepository.
 type JSONPostRepository struct {
-    path string
+    store    *jsonStore[models.Post]
+    history  *jsonHistory
+    writable bool
 }
 
 func NewJSONPostRepository(path string) *JSONPostRepository {
-    return &JSONPostRepository{path: path}
+    store := newJSONStore(path, func(item models.Post) string { return item.ID })
+    store.versioned(func(item *models.Post) *int { return &item.Version })
+    store.softDeleted(func(item *models.Post) **time.Time { return &item.DeletedAt })
+    return &JSONPostRepository{store: store}
+}
+
+// NewWritableJSONPostRepository returns a repository that can be changed.
+// Purging a post also deletes its revisions and slug redirects from
+// revisions and redirects.
+func NewWritableJSONPostRepository(path string, revisions *JSONRevisionRepository, redirects *JSONSlugRedirectRepository) *JSONPostRepository {
+    r := NewJSONPostRepository(path)
+    r.history = &jsonHistory{revisions: revisions, redirects: redirects}
+    r.writable = true
+    return r
 }
 
 func (r *JSONPostRepository) List() ([]models.Post, error) {
-    f, err := os.Open(r.path)
-    if err != nil {
-        return nil, err
-    }
-    defer f.Close()
+    return r.store.live()
+}
 
-    var items []models.Post
-    if err := json.NewDecoder(f).Decode(&items); err != nil {
+func (r *JSONPostRepository) ListPage(q models.ListQuery) (*models.Page[models.Post], error) {
+    items, err := r.store.live()
+    if err != nil {
         return nil, err
     }
-    return items, nil
+    return jsonListPage(items, jsonPostListSpec, q)
 }
 
 func (r *JSONPostRepository) GetByID(id string) (*models.Post, error) {
-    items, err := r.List()
+    return r.store.get(id)
+}
+
+func (r *JSONPostRepository) GetBySlug(slug string) (*models.Post, error) {
+    items, err := r.store.live()
     if err != nil {
         return nil, err
     }
     for _, item := range items {
-        if item.ID == id {
+        if item.Slug == slug {
             return &item, nil
         }
     }
     return nil, nil
 }
 
+// SlugTaken reports whether a post other than exceptID uses slug.
+func (r *JSONPostRepository) SlugTaken(slug, exceptID string) (bool, error) {
+    items, err := r.store.load()
+    if err != nil {
+        return false, err
+    }
+    for _, item := range items {
+        if item.Slug == slug && item.ID != exceptID {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
 func (r *JSONPostRepository) Create(post *models.Post) error {
-    return ErrReadOnly
+    if !r.writable {
+        return ErrReadOnly
+    }
+    return r.store.insert(*post)
 }
 
 func (r *JSONPostRepository) Update(post *models.Post) error {
-    return ErrReadOnly
+    if !r.writable {
+        return ErrReadOnly
+    }
+    return r.store.replace(post)
+}
+
+func (r *JSONPostRepository) Modify(id string, fn func(*models.Post) error) (*models.Post, error) {
+    if !r.writable {
+        return nil, ErrReadOnly
+    }
+    return r.store.modify(id, fn)
+}
+
+// Delete moves the post to the trash if it is still at version.
+func (r *JSONPostRepository) Delete(id string, version int) error {
+    if !r.writable {
+        return ErrReadOnly
+    }
+    return r.store.trash(id, version, time.Now().UTC().Truncate(time.Microsecond))
+}
+
+func (r *JSONPostRepository) Restore(id string) (*models.Post, error) {
+    if !r.writable {
+        return nil, ErrReadOnly
+    }
+    return r.store.restore(id)
+}
+
+func (r *JSONPostRepository) ListDeleted() ([]models.Post, error) {
+    return r.store.deleted()
 }
 
-func (r *JSONPostRepository) Delete(id string) error {
-    return ErrReadOnly
+// Purge permanently deletes posts moved to the trash before before.
+// Read-only repositories have nothing to purge.
+func (r *JSONPostRepository) Purge(before time.Time) (int, error) {
+    if !r.writable {
+        return 0, nil
+    }
+    return r.store.purge(before, func(ids []string) error {
+        return r.history.forget(models.EntityPost, ids)
+    })
+}
+
+// PublishDue flips scheduled posts whose publish time has passed to published.
+// Read-only repositories report nothing to do; visibility is computed from the
+// publish time either way.
+func (r *JSONPostRepository) PublishDue(now time.Time) (int, error) {
+    if !r.writable {
+        return 0, nil
+    }
+    n := 0
+    err := r.store.update(func(items []models.Post) ([]models.Post, error) {
+        for i := range items {
+            if items[i].Status == models.PostStatusScheduled && items[i].PublishedAt != nil && !items[i].PublishedAt.After(now) {
+                items[i].Status = models.PostStatusPublished
+                items[i].UpdatedAt = now.UTC()
+                items[i].Version++
+                n++
+            }
+        }
+        if n == 0 {
+            return nil, errNoChange
+        }
+        return items, nil
+    })
+    return n, err
 }
@@ -1,63 +1,156 @@
 package repositories
 
 import (
-    "encoding/json"
-    "os"
+    "slices"
+    "time"
 
     "github.com/ScriptVandal/backend-go/internal/models"
 )
 
 type ProjectRepository interface {
     List() ([]models.Project, error)
+    ListPage(q models.ListQuery) (*models.Page[models.Project], error)
     GetByID(id string) (*models.Project, error)
+    GetBySlug(slug string) (*models.Project, error)
+    SlugTaken(slug, exceptID string) (bool, error)
     Create(project *models.Project) error
     Update(project *models.Project) error
-    Delete(id string) error
+    Modify(id string, fn func(*models.Project) error) (*models.Project, error)
+    Delete(id string, version int) error
+    Restore(id string) (*models.Project, error)
+    ListDeleted() ([]models.Project, error)
+    Purge(before time.Time) (int, error)
 }
 
+var jsonProjectListSpec = jsonListSpec[models.Project]{
+    id: func(p models.Project) string { return p.ID },
+    sorts: map[string]func(models.Project) string{
+        "title":      func(p models.Project) string { return p.Title },
+        "created_at": func(p models.Project) string { return timeSortKey(&p.CreatedAt) },
+        "updated_at": func(p models.Project) string { return timeSortKey(&p.UpdatedAt) },
+        "id":         func(p models.Project) string { return p.ID },
+    },
+    defaultSort: "title",
+    filters: map[string]func(models.Project, string) bool{
+        "tag": func(p models.Project, v string) bool { return slices.Contains(p.Tags, v) },
+    },
+}
+
+// JSONProjectRepository implements ProjectRepository using local JSON file.
+// It is read-only unless created with NewWritableJSONProjectRepository.
 type JSONProjectRepository struct {
-    path string
+    store    *jsonStore[models.Project]
+    history  *jsonHistory
+    writable bool
 }
 
 func NewJSONProjectRepository(path string) *JSONProjectRepository {
-    return &JSONProjectRepository{path: path}
+    store := newJSONStore(path, func(item models.Project) string { return item.ID })
+    store.versioned(func(item *models.Project) *int { return &item.Version })
+    store.softDeleted(func(item *models.Project) **time.Time { return &item.DeletedAt })
+    return &JSONProjectRepository{store: store}
+}
+
+// NewWritableJSONProjectRepository returns a repository that can be changed.
+// Purging a project also deletes its revisions and slug redirects from
+// revisions and redirects.
+func NewWritableJSONProjectRepository(path string, revisions *JSONRevisionRepository, redirects *JSONSlugRedirectRepository) *JSONProjectRepository {
+    r := NewJSONProjectRepository(path)
+    r.history = &jsonHistory{revisions: revisions, redirects: redirects}
+    r.writable = true
+    return r
 }
 
 func (r *JSONProjectRepository) List() ([]models.Project, error) {
-    f, err := os.Open(r.path)
-    if err != nil {
-        return nil, err
-    }
-    defer f.Close()
+    return r.store.live()
+}
 
-    var items []models.Project
-    if err := json.NewDecoder(f).Decode(&items); err != nil {
+func (r *JSONProjectRepository) ListPage(q models.ListQuery) (*models.Page[models.Project], error) {
+    items, err := r.store.live()
+    if err != nil {
         return nil, err
     }
-    return items, nil
+    return jsonListPage(items, jsonProjectListSpec, q)
 }
 
 func (r *JSONProjectRepository) GetByID(id string) (*models.Project, error) {
-    items, err := r.List()
+    return r.store.get(id)
+}
+
+func (r *JSONProjectRepository) GetBySlug(slug string) (*models.Project, error) {
+    items, err := r.store.live()
     if err != nil {
         return nil, err
     }
     for _, item := range items {
-        if item.ID == id {
+        if item.Slug == slug {
             return &item, nil
         }
     }
     return nil, nil
 }
 
+// SlugTaken reports whether a project other than exceptID uses slug.
+func (r *JSONProjectRepository) SlugTaken(slug, exceptID string) (bool, error) {
+    items, err := r.store.load()
+    if err != nil {
+        return false, err
+    }
+    for _, item := range items {
+        if item.Slug == slug && item.ID != exceptID {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
 func (r *JSONProjectRepository) Create(project *models.Project) error {
-    return ErrReadOnly
+    if !r.writable {
+        return ErrReadOnly
+    }
+    return r.store.insert(*project)
 }
 
 func (r *JSONProjectRepository) Update(project *models.Project) error {
-    return ErrReadOnly
+    if !r.writable {
+        return ErrReadOnly
+    }
+    return r.store.replace(project)
+}
+
+func (r *JSONProjectRepository) Modify(id string, fn func(*models.Project) error) (*models.Project, error) {
+    if !r.writable {
+        return nil, ErrReadOnly
+    }
+    return r.store.modify(id, fn)
+}
+
+// Delete moves the project to the trash if it is still at version.
+func (r *JSONProjectRepository) Delete(id string, version int) error {
+    if !r.writable {
+        return ErrReadOnly
+    }
+    return r.store.trash(id, version, time.Now().UTC().Truncate(time.Microsecond))
+}
+
+func (r *JSONProjectRepository) Restore(id string) (*models.Project, error) {
+    if !r.writable {
+        return nil, ErrReadOnly
+    }
+    return r.store.restore(id)
+}
+
+func (r *JSONProjectRepository) ListDeleted() ([]models.Project, error) {
+    return r.store.deleted()
 }
 
-func (r *JSONProjectRepository) Delete(id string) error {
-    return ErrReadOnly
+// Purge permanently deletes projects moved to the trash before before.
+// Read-only repositories have nothing to purge.
+func (r *JSONProjectRepository) Purge(before time.Time) (int, error) {
+    if !r.writable {
+        return 0, nil
+    }
+    return r.store.purge(before, func(ids []string) error {
+        return r.history.forget(models.EntityProject, ids)
+    })
 }
@@ -0,0 +1,248 @@
+package repositories
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// Field weights mirror the A/B/C weights of the Postgres search vectors.
+const (
+	weightTitle = 1.0
+	weightTags  = 0.4
+	weightBody  = 0.1
+)
+
+// JSONSearchRepository implements SearchRepository with an in-process
+// inverted index over the posts and projects JSON files. The index is rebuilt
+// whenever either file changes.
+type JSONSearchRepository struct {
+	posts    *jsonStore[models.Post]
+	projects *jsonStore[models.Project]
+
+	mu       sync.Mutex
+	index    *searchIndex
+	postsGen uint64
+	projGen  uint64
+}
+
+func NewJSONSearchRepository(postsPath, projectsPath string) *JSONSearchRepository {
+	return &JSONSearchRepository{
+		posts:    newJSONStore(postsPath, func(p models.Post) string { return p.ID }),
+		projects: newJSONStore(projectsPath, func(p models.Project) string { return p.ID }),
+	}
+}
+
+func (r *JSONSearchRepository) Search(query string, limit int) ([]models.SearchResult, error) {
+	idx, err := r.currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	return idx.search(query, limit, time.Now()), nil
+}
+
+// currentIndex returns the index, rebuilding it if a source file changed.
+func (r *JSONSearchRepository) currentIndex() (*searchIndex, error) {
+	posts, postsGen, err := r.posts.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	projects, projGen, err := r.projects.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.index == nil || postsGen != r.postsGen || projGen != r.projGen {
+		idx := newSearchIndex()
+		for _, p := range posts {
+			// Drafts, archived and trashed posts are never searchable;
+			// scheduled ones are indexed but hidden until their publish time.
+			if p.DeletedAt != nil || p.Status != models.PostStatusPublished && p.Status != models.PostStatusScheduled {
+				continue
+			}
+			if p.PublishedAt == nil {
+				continue
+			}
+			idx.add(models.SearchResult{Type: models.EntityPost, ID: p.ID, Title: p.Title, Slug: p.Slug, Tags: p.Tags}, p.Content, p.PublishedAt)
+		}
+		for _, p := range projects {
+			if p.DeletedAt != nil {
+				continue
+			}
+			idx.add(models.SearchResult{Type: models.EntityProject, ID: p.ID, Title: p.Title, Slug: p.Slug, Tags: p.Tags}, p.Description, nil)
+		}
+		r.index, r.postsGen, r.projGen = idx, postsGen, projGen
+	}
+	return r.index, nil
+}
+
+type searchDoc struct {
+	result models.SearchResult
+	body   string
+	// visibleFrom hides the document before the given time, if set.
+	visibleFrom *time.Time
+}
+
+type searchIndex struct {
+	docs []searchDoc
+	// terms maps a token to per-document weights.
+	terms map[string]map[int]float64
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{terms: map[string]map[int]float64{}}
+}
+
+func (idx *searchIndex) add(result models.SearchResult, body string, visibleFrom *time.Time) {
+	doc := len(idx.docs)
+	idx.docs = append(idx.docs, searchDoc{result: result, body: body, visibleFrom: visibleFrom})
+
+	addField := func(text string, weight float64) {
+		for _, tok := range tokenize(text) {
+			postings := idx.terms[tok.term]
+			if postings == nil {
+				postings = map[int]float64{}
+				idx.terms[tok.term] = postings
+			}
+			postings[doc] += weight
+		}
+	}
+	addField(result.Title, weightTitle)
+	addField(strings.Join(result.Tags, " "), weightTags)
+	addField(body, weightBody)
+}
+
+// search returns documents visible at now that contain every query term,
+// best match first.
+func (idx *searchIndex) search(query string, limit int, now time.Time) []models.SearchResult {
+	var terms []string
+	seen := map[string]bool{}
+	for _, tok := range tokenize(query) {
+		if !seen[tok.term] {
+			seen[tok.term] = true
+			terms = append(terms, tok.term)
+		}
+	}
+	if len(terms) == 0 {
+		return []models.SearchResult{}
+	}
+
+	scores := map[int]float64{}
+	for doc, w := range idx.terms[terms[0]] {
+		scores[doc] = w
+	}
+	for _, term := range terms[1:] {
+		postings := idx.terms[term]
+		for doc := range scores {
+			w, ok := postings[doc]
+			if !ok {
+				delete(scores, doc)
+				continue
+			}
+			scores[doc] += w
+		}
+	}
+
+	docs := make([]int, 0, len(scores))
+	for doc := range scores {
+		if from := idx.docs[doc].visibleFrom; from != nil && from.After(now) {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if scores[docs[i]] != scores[docs[j]] {
+			return scores[docs[i]] > scores[docs[j]]
+		}
+		return idx.docs[docs[i]].result.ID < idx.docs[docs[j]].result.ID
+	})
+	if len(docs) > limit {
+		docs = docs[:limit]
+	}
+
+	results := make([]models.SearchResult, 0, len(docs))
+	for _, doc := range docs {
+		res := idx.docs[doc].result
+		res.Rank = scores[doc]
+		res.Snippet = highlight(snippet(idx.docs[doc].body, seen))
+		results = append(results, res)
+	}
+	return results
+}
+
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lower-cased words made of letters and digits,
+// remembering their byte offsets in text.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		}
+		if !word && start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+const (
+	snippetBefore = 10
+	snippetWords  = 35
+)
+
+// snippet cuts a window of words around the first match in body and wraps
+// matching words in the markStart/markStop sentinels.
+func snippet(body string, terms map[string]bool) string {
+	tokens := tokenize(body)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	first := 0
+	for i, tok := range tokens {
+		if terms[tok.term] {
+			first = i
+			break
+		}
+	}
+	from := max(first-snippetBefore, 0)
+	to := min(from+snippetWords, len(tokens))
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("… ")
+	}
+	pos := tokens[from].start
+	for _, tok := range tokens[from:to] {
+		b.WriteString(body[pos:tok.start])
+		if terms[tok.term] {
+			b.WriteString(markStart + body[tok.start:tok.end] + markStop)
+		} else {
+			b.WriteString(body[tok.start:tok.end])
+		}
+		pos = tok.end
+	}
+	if to < len(tokens) {
+		b.WriteString(" …")
+	} else {
+		b.WriteString(strings.TrimRightFunc(body[pos:], unicode.IsSpace))
+	}
+	return b.String()
+}
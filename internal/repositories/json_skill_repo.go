@@ -1,63 +1,122 @@
 package repositories
 
 import (
-    "encoding/json"
-    "os"
+    "time"
 
     "github.com/ScriptVandal/backend-go/internal/models"
 )
 
 type SkillRepository interface {
     List() ([]models.Skill, error)
+    ListPage(q models.ListQuery) (*models.Page[models.Skill], error)
     GetByID(id string) (*models.Skill, error)
     Create(skill *models.Skill) error
     Update(skill *models.Skill) error
-    Delete(id string) error
+    Modify(id string, fn func(*models.Skill) error) (*models.Skill, error)
+    Delete(id string, version int) error
+    Restore(id string) (*models.Skill, error)
+    ListDeleted() ([]models.Skill, error)
+    Purge(before time.Time) (int, error)
 }
 
+var jsonSkillListSpec = jsonListSpec[models.Skill]{
+    id: func(s models.Skill) string { return s.ID },
+    sorts: map[string]func(models.Skill) string{
+        "name":       func(s models.Skill) string { return s.Name },
+        "level":      func(s models.Skill) string { return s.Level },
+        "category":   func(s models.Skill) string { return s.Category },
+        "created_at": func(s models.Skill) string { return timeSortKey(&s.CreatedAt) },
+        "updated_at": func(s models.Skill) string { return timeSortKey(&s.UpdatedAt) },
+        "id":         func(s models.Skill) string { return s.ID },
+    },
+    defaultSort: "name",
+    filters: map[string]func(models.Skill, string) bool{
+        "category": func(s models.Skill, v string) bool { return s.Category == v },
+        "level":    func(s models.Skill, v string) bool { return s.Level == v },
+    },
+}
+
+// JSONSkillRepository implements SkillRepository using local JSON file.
+// It is read-only unless created with NewWritableJSONSkillRepository.
 type JSONSkillRepository struct {
-    path string
+    store    *jsonStore[models.Skill]
+    writable bool
 }
 
 func NewJSONSkillRepository(path string) *JSONSkillRepository {
-    return &JSONSkillRepository{path: path}
+    store := newJSONStore(path, func(item models.Skill) string { return item.ID })
+    store.versioned(func(item *models.Skill) *int { return &item.Version })
+    store.softDeleted(func(item *models.Skill) **time.Time { return &item.DeletedAt })
+    return &JSONSkillRepository{store: store}
+}
+
+func NewWritableJSONSkillRepository(path string) *JSONSkillRepository {
+    r := NewJSONSkillRepository(path)
+    r.writable = true
+    return r
 }
 
 func (r *JSONSkillRepository) List() ([]models.Skill, error) {
-    f, err := os.Open(r.path)
+    return r.store.live()
+}
+
+func (r *JSONSkillRepository) ListPage(q models.ListQuery) (*models.Page[models.Skill], error) {
+    items, err := r.store.live()
     if err != nil {
         return nil, err
     }
-    defer f.Close()
+    return jsonListPage(items, jsonSkillListSpec, q)
+}
 
-    var items []models.Skill
-    if err := json.NewDecoder(f).Decode(&items); err != nil {
-        return nil, err
+func (r *JSONSkillRepository) GetByID(id string) (*models.Skill, error) {
+    return r.store.get(id)
+}
+
+func (r *JSONSkillRepository) Create(skill *models.Skill) error {
+    if !r.writable {
+        return ErrReadOnly
     }
-    return items, nil
+    return r.store.insert(*skill)
 }
 
-func (r *JSONSkillRepository) GetByID(id string) (*models.Skill, error) {
-    items, err := r.List()
-    if err != nil {
-        return nil, err
+func (r *JSONSkillRepository) Update(skill *models.Skill) error {
+    if !r.writable {
+        return ErrReadOnly
     }
-    for _, item := range items {
-        if item.ID == id {
-            return &item, nil
-        }
+    return r.store.replace(skill)
+}
+
+func (r *JSONSkillRepository) Modify(id string, fn func(*models.Skill) error) (*models.Skill, error) {
+    if !r.writable {
+        return nil, ErrReadOnly
     }
-    return nil, nil
+    return r.store.modify(id, fn)
 }
 
-func (r *JSONSkillRepository) Create(skill *models.Skill) error {
-    return ErrReadOnly
+// Delete moves the skill to the trash if it is still at version.
+func (r *JSONSkillRepository) Delete(id string, version int) error {
+    if !r.writable {
+        return ErrReadOnly
+    }
+    return r.store.trash(id, version, time.Now().UTC().Truncate(time.Microsecond))
 }
 
-func (r *JSONSkillRepository) Update(skill *models.Skill) error {
-    return ErrReadOnly
+func (r *JSONSkillRepository) Restore(id string) (*models.Skill, error) {
+    if !r.writable {
+        return nil, ErrReadOnly
+    }
+    return r.store.restore(id)
 }
 
-func (r *JSONSkillRepository) Delete(id string) error {
-    return ErrReadOnly
+func (r *JSONSkillRepository) ListDeleted() ([]models.Skill, error) {
+    return r.store.deleted()
+}
+
+// Purge permanently deletes skills moved to the trash before before.
+// Read-only repositories have nothing to purge.
+func (r *JSONSkillRepository) Purge(before time.Time) (int, error) {
+    if !r.writable {
+        return 0, nil
+    }
+    return r.store.purge(before, nil)
 }
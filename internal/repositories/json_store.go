@@ -0,0 +1,395 @@
+package repositories
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// hashCheckInterval is how long a cached file is trusted on the strength of
+// its modification time and size alone. Editors and tools such as cp -p or
+// rsync can change a file and keep both, so after this interval, and before
+// every write, the cache is checked against a hash of the file's content.
+const hashCheckInterval = time.Second
+
+// jsonStore caches the decoded contents of a JSON array file. The cache is
+// reloaded when the file's modification time, size or content changes; see
+// hashCheckInterval. Writes are serialised and go to a temporary file that is
+// renamed over the original, so readers never see a partially written file.
+type jsonStore[T any] struct {
+	path string
+	idOf func(T) string
+	// missingOK treats a missing file as empty; it is created on first write.
+	missingOK bool
+	// versionOf, if set, points at an item's version. Writes then check it
+	// against the stored item and bump it.
+	versionOf func(*T) *int
+	// deletedOf, if set, points at an item's deletion time. Items that have
+	// one are in the trash: reads and writes other than restore skip them.
+	deletedOf func(*T) **time.Time
+
+	writeMu sync.Mutex
+
+	mu      sync.RWMutex
+	items   []T
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+	// checked is when sum was last compared with the file.
+	checked time.Time
+	loaded  bool
+	// generation changes whenever the cached items are replaced.
+	generation uint64
+}
+
+func newJSONStore[T any](path string, idOf func(T) string) *jsonStore[T] {
+	return &jsonStore[T]{path: path, idOf: idOf}
+}
+
+// versioned makes writes check and bump the version that versionOf points at.
+func (s *jsonStore[T]) versioned(versionOf func(*T) *int) *jsonStore[T] {
+	s.versionOf = versionOf
+	return s
+}
+
+// softDeleted makes the store keep deleted items in the trash, marked with
+// the time deletedOf points at.
+func (s *jsonStore[T]) softDeleted(deletedOf func(*T) **time.Time) *jsonStore[T] {
+	s.deletedOf = deletedOf
+	return s
+}
+
+// inTrash reports whether item has been soft-deleted.
+func (s *jsonStore[T]) inTrash(item *T) bool {
+	return s.deletedOf != nil && *s.deletedOf(item) != nil
+}
+
+// live returns the items that are not in the trash.
+func (s *jsonStore[T]) live() ([]T, error) {
+	return s.filter(false)
+}
+
+// deleted returns the items in the trash.
+func (s *jsonStore[T]) deleted() ([]T, error) {
+	return s.filter(true)
+}
+
+func (s *jsonStore[T]) filter(trashed bool) ([]T, error) {
+	items, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	kept := items[:0]
+	for i := range items {
+		if s.inTrash(&items[i]) == trashed {
+			kept = append(kept, items[i])
+		}
+	}
+	return kept, nil
+}
+
+// load returns a copy of the items currently in the file.
+func (s *jsonStore[T]) load() ([]T, error) {
+	items, _, err := s.snapshot()
+	return items, err
+}
+
+// snapshot is like load but also returns the cache generation, which callers
+// can use to tell whether the file changed since their last snapshot.
+func (s *jsonStore[T]) snapshot() ([]T, uint64, error) {
+	return s.read(false)
+}
+
+// read returns a copy of the items and the cache generation. With verify
+// set, or once hashCheckInterval has passed, a cache whose file looks
+// unchanged is still checked against the content hash.
+func (s *jsonStore[T]) read(verify bool) ([]T, uint64, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		if s.missingOK && errors.Is(err, fs.ErrNotExist) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	if s.loaded && info.ModTime().Equal(s.modTime) && info.Size() == s.size && !verify && time.Since(s.checked) < hashCheckInterval {
+		items, gen := append([]T(nil), s.items...), s.generation
+		s.mu.RUnlock()
+		return items, gen, nil
+	}
+	s.mu.RUnlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	sum := sha256.Sum256(data)
+
+	s.mu.Lock()
+	if s.loaded && sum == s.sum {
+		s.modTime, s.size, s.checked = info.ModTime(), info.Size(), time.Now()
+		items, gen := append([]T(nil), s.items...), s.generation
+		s.mu.Unlock()
+		return items, gen, nil
+	}
+	s.mu.Unlock()
+
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.Lock()
+	s.set(items, info, sum)
+	gen := s.generation
+	s.mu.Unlock()
+
+	return append([]T(nil), items...), gen, nil
+}
+
+// set replaces the cached items; s.mu must be held.
+func (s *jsonStore[T]) set(items []T, info os.FileInfo, sum [sha256.Size]byte) {
+	s.items, s.modTime, s.size, s.sum, s.loaded = items, info.ModTime(), info.Size(), sum, true
+	s.checked = time.Now()
+	s.generation++
+}
+
+// get returns the live item with the given id, or nil if there is none.
+func (s *jsonStore[T]) get(id string) (*T, error) {
+	items, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if s.idOf(item) == id && !s.inTrash(&item) {
+			return &item, nil
+		}
+	}
+	return nil, nil
+}
+
+// errNoChange may be returned by an update function to skip the write.
+var errNoChange = errors.New("no change")
+
+// update applies fn to the current items and persists the result.
+func (s *jsonStore[T]) update(fn func(items []T) ([]T, error)) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	// The write replaces the whole file, so it must start from what is
+	// really there.
+	items, _, err := s.read(true)
+	if err != nil {
+		return err
+	}
+	items, err = fn(items)
+	if err == errNoChange {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if items == nil {
+		items = []T{}
+	}
+
+	info, sum, err := writeFileAtomic(s.path, items)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.set(items, info, sum)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *jsonStore[T]) insert(item T) error {
+	id := s.idOf(item)
+	if id == "" {
+		return ErrMissingID
+	}
+	return s.update(func(items []T) ([]T, error) {
+		for _, existing := range items {
+			if s.idOf(existing) == id {
+				return nil, ErrConflict
+			}
+		}
+		return append(items, item), nil
+	})
+}
+
+// replace stores item over the item with the same id. For a versioned
+// store, item must be at the stored version, which is then bumped in item.
+func (s *jsonStore[T]) replace(item *T) error {
+	id := s.idOf(*item)
+	return s.update(func(items []T) ([]T, error) {
+		for i := range items {
+			if s.idOf(items[i]) == id && !s.inTrash(&items[i]) {
+				if s.versionOf != nil {
+					if *s.versionOf(item) != *s.versionOf(&items[i]) {
+						return nil, ErrStale
+					}
+					*s.versionOf(item)++
+				}
+				items[i] = *item
+				return items, nil
+			}
+		}
+		return nil, ErrNotFound
+	})
+}
+
+// modify applies fn to the item with id and stores the result. The file
+// stays locked for writing in between, so concurrent changes are not lost.
+func (s *jsonStore[T]) modify(id string, fn func(*T) error) (*T, error) {
+	var out *T
+	err := s.update(func(items []T) ([]T, error) {
+		for i := range items {
+			if s.idOf(items[i]) == id && !s.inTrash(&items[i]) {
+				item := items[i]
+				if err := fn(&item); err != nil {
+					return nil, err
+				}
+				if s.versionOf != nil {
+					*s.versionOf(&item) = *s.versionOf(&items[i]) + 1
+				}
+				items[i], out = item, &item
+				return items, nil
+			}
+		}
+		return nil, ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// trash moves the live item with id to the trash, marked with at. For a
+// versioned store, the item must still be at version.
+func (s *jsonStore[T]) trash(id string, version int, at time.Time) error {
+	return s.update(func(items []T) ([]T, error) {
+		for i := range items {
+			if s.idOf(items[i]) == id && !s.inTrash(&items[i]) {
+				if s.versionOf != nil {
+					if *s.versionOf(&items[i]) != version {
+						return nil, ErrStale
+					}
+					*s.versionOf(&items[i])++
+				}
+				*s.deletedOf(&items[i]) = &at
+				return items, nil
+			}
+		}
+		return nil, ErrNotFound
+	})
+}
+
+// restore takes the item with id out of the trash and returns it.
+func (s *jsonStore[T]) restore(id string) (*T, error) {
+	var out *T
+	err := s.update(func(items []T) ([]T, error) {
+		for i := range items {
+			if s.idOf(items[i]) == id && s.inTrash(&items[i]) {
+				*s.deletedOf(&items[i]) = nil
+				if s.versionOf != nil {
+					*s.versionOf(&items[i])++
+				}
+				item := items[i]
+				out = &item
+				return items, nil
+			}
+		}
+		return nil, ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// purge permanently deletes items moved to the trash before before. forget,
+// if not nil, is called with their IDs before the file is written; an error
+// from it leaves the file unchanged.
+func (s *jsonStore[T]) purge(before time.Time, forget func(ids []string) error) (int, error) {
+	var ids []string
+	err := s.update(func(items []T) ([]T, error) {
+		kept := items[:0]
+		for i := range items {
+			if s.inTrash(&items[i]) && (*s.deletedOf(&items[i])).Before(before) {
+				ids = append(ids, s.idOf(items[i]))
+				continue
+			}
+			kept = append(kept, items[i])
+		}
+		if len(ids) == 0 {
+			return nil, errNoChange
+		}
+		if forget != nil {
+			if err := forget(ids); err != nil {
+				return nil, err
+			}
+		}
+		return kept, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// writeFileAtomic encodes v as indented JSON into a temporary file next to
+// path and renames it into place. It returns the file's info and the hash of
+// what was written.
+func writeFileAtomic(path string, v any) (os.FileInfo, [sha256.Size]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, [sha256.Size]byte{}, err
+	}
+	info, err := replaceFile(path, func(w io.Writer) error {
+		_, err := w.Write(buf.Bytes())
+		return err
+	})
+	return info, sha256.Sum256(buf.Bytes()), err
+}
+
+// replaceFile writes a temporary file next to path with write and renames
+// it into place.
+func replaceFile(path string, write func(w io.Writer) error) (os.FileInfo, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	return os.Stat(path)
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type storeItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// editKeepingStat rewrites path with data, which must be as long as the
+// old content, and puts its modification time back, as cp -p would.
+func editKeepingStat(t *testing.T, path, data string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) != info.Size() {
+		t.Fatalf("edit changes the size from %d to %d", info.Size(), len(data))
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestStore(t *testing.T, data string) (*jsonStore[storeItem], string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "items.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newJSONStore(path, func(i storeItem) string { return i.ID })
+	if _, err := s.load(); err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func TestJSONStoreNoticesEditsThatKeepModTimeAndSize(t *testing.T) {
+	s, path := newTestStore(t, `[{"id":"1","name":"old"}]`)
+	editKeepingStat(t, path, `[{"id":"1","name":"new"}]`)
+
+	// Once the cache is due for a check, reads see the edit.
+	s.mu.Lock()
+	s.checked = time.Now().Add(-hashCheckInterval)
+	s.mu.Unlock()
+	items, err := s.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "new" {
+		t.Errorf("items after the edit = %+v", items)
+	}
+}
+
+func TestJSONStoreWriteKeepsEditsThatKeepModTimeAndSize(t *testing.T) {
+	s, path := newTestStore(t, `[{"id":"1","name":"old"}]`)
+	editKeepingStat(t, path, `[{"id":"1","name":"new"}]`)
+
+	// A write right after the edit, while reads may still be served from
+	// the cache, must not put the old content back.
+	if err := s.insert(storeItem{ID: "2", Name: "added"}); err != nil {
+		t.Fatal(err)
+	}
+	item, err := s.get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item == nil || item.Name != "new" {
+		t.Errorf("item 1 after the write = %+v, want the edited name", item)
+	}
+	data, _ := os.ReadFile(path)
+	if want := `"name": "new"`; !strings.Contains(string(data), want) {
+		t.Errorf("file after the write lost the edit:\n%s", data)
+	}
+}
+
+func TestJSONStoreKeepsCacheWhenContentIsUnchanged(t *testing.T) {
+	s, path := newTestStore(t, `[{"id":"1","name":"old"}]`)
+	_, gen, err := s.snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Touching the file or rechecking it does not count as a change.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.checked = time.Time{}
+	s.mu.Unlock()
+	if _, again, err := s.snapshot(); err != nil || again != gen {
+		t.Errorf("generation changed from %d to %d (err %v) without a content change", gen, again, err)
+	}
+}
@@ -0,0 +1,297 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// ErrInvalidQuery is returned for unknown sort fields and malformed cursors.
+var ErrInvalidQuery = apperr.New(apperr.CodeValidation, "invalid list query")
+
+func invalidQuery(field, message string) error {
+	return &apperr.Error{
+		Code:    apperr.CodeValidation,
+		Message: ErrInvalidQuery.Message,
+		Fields:  []apperr.FieldError{apperr.Field(field, message)},
+		Err:     ErrInvalidQuery,
+	}
+}
+
+// listCursor is the keyset position after the last item of a page: the sort
+// value and the ID that breaks ties.
+type listCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses a cursor and checks it was issued for the same sort.
+func decodeCursor(s, sortName string) (*listCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, invalidQuery("cursor", "is malformed")
+	}
+	var c listCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, invalidQuery("cursor", "is malformed")
+	}
+	if c.Sort != sortName {
+		return nil, invalidQuery("cursor", "does not match sort")
+	}
+	return &c, nil
+}
+
+// parseSort splits "-title" into its field and direction, falling back to
+// def and rejecting fields not in allowed.
+func parseSort[V any](sortParam, def string, allowed map[string]V) (string, bool, error) {
+	if sortParam == "" {
+		sortParam = def
+	}
+	field, desc := strings.CutPrefix(sortParam, "-")
+	if _, ok := allowed[field]; !ok {
+		return "", false, invalidQuery("sort", fmt.Sprintf("cannot sort by %q", field))
+	}
+	return field, desc, nil
+}
+
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return limit
+}
+
+// pgListSpec describes how a table is paginated in SQL.
+type pgListSpec struct {
+	table   string
+	columns string
+	// idExpr is the tie-breaking key as text.
+	idExpr string
+	// sorts maps public sort names to SQL expressions of type text.
+	sorts       map[string]string
+	defaultSort string
+	// filters maps filter names to conditions with a single ? placeholder.
+	filters map[string]string
+	// published is the condition applied for ListQuery.PublishedOnly.
+	published string
+	// noTrash is set for tables without soft delete.
+	noTrash bool
+}
+
+// pgListPage runs a keyset-paginated query. scan reads the spec's columns
+// followed by the row's sort key and ID key.
+func pgListPage[T any](db *sql.DB, spec pgListSpec, q models.ListQuery, scan func(rows *sql.Rows, sortKey, idKey *string) (T, error)) (*models.Page[T], error) {
+	sortName, desc, err := parseSort(q.Sort, spec.defaultSort, spec.sorts)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	sortExpr := spec.sorts[sortName]
+
+	// Trashed rows are never listed.
+	var where []string
+	if !spec.noTrash {
+		where = append(where, pgLive)
+	}
+	var args []any
+	bind := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, strings.Replace(cond, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	// Filters are applied in a fixed order so the SQL text is stable.
+	names := make([]string, 0, len(q.Filters))
+	for name := range q.Filters {
+		if _, ok := spec.filters[name]; ok && q.Filters[name] != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		bind(spec.filters[name], q.Filters[name])
+	}
+	if q.PublishedOnly && spec.published != "" {
+		where = append(where, spec.published)
+	}
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM " + spec.table + whereClause(where)
+	if err := db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	dir, cmp := "ASC", ">"
+	if desc {
+		dir, cmp = "DESC", "<"
+	}
+	if after != nil {
+		args = append(args, after.Value, after.ID)
+		where = append(where, fmt.Sprintf("(%s, %s) %s ($%d, $%d)", sortExpr, spec.idExpr, cmp, len(args)-1, len(args)))
+	}
+
+	limit := pageLimit(q.Limit)
+	query := fmt.Sprintf("SELECT %s, %s, %s FROM %s%s ORDER BY %s %s, %s %s LIMIT %d",
+		spec.columns, sortExpr, spec.idExpr, spec.table, whereClause(where),
+		sortExpr, dir, spec.idExpr, dir, limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.Page[T]{Items: []T{}, Total: total}
+	var last listCursor
+	for rows.Next() {
+		var sortKey, idKey string
+		item, err := scan(rows, &sortKey, &idKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Items) == limit {
+			page.NextCursor = encodeCursor(last)
+			break
+		}
+		page.Items = append(page.Items, item)
+		last = listCursor{Sort: q.Sort, Value: sortKey, ID: idKey}
+	}
+	return page, rows.Err()
+}
+
+// timeSortKey formats t the same way as the pgTimeSortKey SQL expression, so
+// that JSON and Postgres cursors compare alike.
+func timeSortKey(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000000")
+}
+
+// pgTimeSortKey returns a text sort expression for a timestamp column.
+func pgTimeSortKey(column string) string {
+	return `COALESCE(to_char(` + column + ` AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US'), '')`
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// jsonListSpec describes how items loaded from a JSON file are paginated.
+type jsonListSpec[T any] struct {
+	id          func(T) string
+	sorts       map[string]func(T) string
+	defaultSort string
+	filters     map[string]func(item T, value string) bool
+	// published reports whether an item passes ListQuery.PublishedOnly.
+	published func(item T) bool
+}
+
+// jsonListPage emulates pgListPage in memory.
+func jsonListPage[T any](items []T, spec jsonListSpec[T], q models.ListQuery) (*models.Page[T], error) {
+	sortName, desc, err := parseSort(q.Sort, spec.defaultSort, spec.sorts)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	key := spec.sorts[sortName]
+
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if q.PublishedOnly && spec.published != nil && !spec.published(item) {
+			continue
+		}
+		if matchesFilters(item, spec.filters, q.Filters) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	less := func(a, b T) bool {
+		ka, kb := key(a), key(b)
+		if ka != kb {
+			return ka < kb
+		}
+		return spec.id(a) < spec.id(b)
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if desc {
+			return less(filtered[j], filtered[i])
+		}
+		return less(filtered[i], filtered[j])
+	})
+
+	page := &models.Page[T]{Items: []T{}, Total: len(filtered)}
+	start := 0
+	if after != nil {
+		// Skip everything up to and including the cursor position.
+		for start < len(filtered) {
+			k, id := key(filtered[start]), spec.id(filtered[start])
+			beyond := k > after.Value || (k == after.Value && id > after.ID)
+			if desc {
+				beyond = k < after.Value || (k == after.Value && id < after.ID)
+			}
+			if beyond {
+				break
+			}
+			start++
+		}
+	}
+
+	limit := pageLimit(q.Limit)
+	end := start + limit
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+	page.Items = append(page.Items, filtered[start:end]...)
+	if end < len(filtered) {
+		last := filtered[end-1]
+		page.NextCursor = encodeCursor(listCursor{Sort: q.Sort, Value: key(last), ID: spec.id(last)})
+	}
+	return page, nil
+}
+
+func matchesFilters[T any](item T, filters map[string]func(T, string) bool, values map[string]string) bool {
+	for name, value := range values {
+		match, ok := filters[name]
+		if !ok || value == "" {
+			continue
+		}
+		if !match(item, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+type listItem struct {
+	ID, Name string
+}
+
+var testListSpec = jsonListSpec[listItem]{
+	id: func(i listItem) string { return i.ID },
+	sorts: map[string]func(listItem) string{
+		"name": func(i listItem) string { return i.Name },
+		"id":   func(i listItem) string { return i.ID },
+	},
+	defaultSort: "id",
+}
+
+var testListItems = []listItem{{"1", "d"}, {"2", "b"}, {"3", "b"}, {"4", "a"}, {"5", "c"}}
+
+func listIDs(t *testing.T, q models.ListQuery) []string {
+	t.Helper()
+	var ids []string
+	for {
+		page, err := jsonListPage(testListItems, testListSpec, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page.Items {
+			ids = append(ids, item.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+func TestListPagesWithCursors(t *testing.T) {
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"", []string{"1", "2", "3", "4", "5"}},
+		{"name", []string{"4", "2", "3", "5", "1"}},
+		{"-name", []string{"1", "5", "3", "2", "4"}},
+	}
+	for _, tt := range tests {
+		if got := listIDs(t, models.ListQuery{Sort: tt.sort, Limit: 2}); !slices.Equal(got, tt.want) {
+			t.Errorf("sort %q: got %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+}
+
+func TestListRejectsCursorOfAnotherSort(t *testing.T) {
+	page, err := jsonListPage(testListItems, testListSpec, models.ListQuery{Sort: "name", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := encodeCursor(listCursor{Sort: "name", Value: "b", ID: "2"})
+
+	tests := []struct {
+		name, sort, cursor string
+	}{
+		{"reversed", "-name", page.NextCursor},
+		{"other field", "id", page.NextCursor},
+		{"default sort", "", page.NextCursor},
+		{"forged for another sort", "-id", forged},
+		{"not base64", "name", "%%%"},
+		{"not JSON", "name", "bm90IGpzb24"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jsonListPage(testListItems, testListSpec, models.ListQuery{Sort: tt.sort, Cursor: tt.cursor})
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("error = %v, want invalid query", err)
+			}
+			var e *apperr.Error
+			if !errors.As(err, &e) || len(e.Fields) != 1 || e.Fields[0].Field != "cursor" {
+				t.Errorf("error does not point at the cursor: %#v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// MailQueueRepository stores outgoing mail until it is delivered.
+type MailQueueRepository interface {
+	Enqueue(m *models.OutgoingMail) error
+	// Claim returns up to limit pending mails due at now, oldest first, and
+	// pushes their next attempt back by lease so that no other worker picks
+	// them up in the meantime.
+	Claim(now time.Time, lease time.Duration, limit int) ([]models.OutgoingMail, error)
+	// Save stores the outcome of a delivery attempt, including the bodies,
+	// which are cleared once the mail is done with.
+	Save(m *models.OutgoingMail) error
+	// Prune deletes mail that was sent or failed before.
+	Prune(before time.Time) (int, error)
+}
+
+type PGMailQueueRepository struct {
+	db *sql.DB
+}
+
+const mailQueueColumns = `id, recipients, subject, text_body, html_body, status, attempts, next_attempt_at, COALESCE(last_error, ''), created_at, sent_at`
+
+func NewPGMailQueueRepository(db *sql.DB) *PGMailQueueRepository {
+	return &PGMailQueueRepository{db: db}
+}
+
+func (r *PGMailQueueRepository) Enqueue(m *models.OutgoingMail) error {
+	query := `INSERT INTO mail_queue (id, recipients, subject, text_body, html_body, status, attempts, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := r.db.Exec(query, m.ID, pq.Array(m.To), m.Subject, m.Text, m.HTML, m.Status, m.Attempts, m.NextAttemptAt, m.CreatedAt)
+	return pgError(err)
+}
+
+func (r *PGMailQueueRepository) Claim(now time.Time, lease time.Duration, limit int) ([]models.OutgoingMail, error) {
+	// SKIP LOCKED lets several instances share the queue without sending
+	// the same mail twice.
+	query := `UPDATE mail_queue SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM mail_queue
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + mailQueueColumns
+	rows, err := r.db.Query(query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mails []models.OutgoingMail
+	for rows.Next() {
+		var m models.OutgoingMail
+		if err := rows.Scan(&m.ID, pq.Array(&m.To), &m.Subject, &m.Text, &m.HTML, &m.Status, &m.Attempts, &m.NextAttemptAt, &m.LastError, &m.CreatedAt, &m.SentAt); err != nil {
+			return nil, err
+		}
+		mails = append(mails, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(mails, func(i, j int) bool { return mails[i].CreatedAt.Before(mails[j].CreatedAt) })
+	return mails, nil
+}
+
+func (r *PGMailQueueRepository) Save(m *models.OutgoingMail) error {
+	query := `UPDATE mail_queue SET status = $2, attempts = $3, next_attempt_at = $4, last_error = NULLIF($5, ''), sent_at = $6,
+			text_body = $7, html_body = $8
+		WHERE id = $1`
+	res, err := r.db.Exec(query, m.ID, m.Status, m.Attempts, m.NextAttemptAt, m.LastError, m.SentAt, m.Text, m.HTML)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PGMailQueueRepository) Prune(before time.Time) (int, error) {
+	res, err := r.db.Exec(`DELETE FROM mail_queue WHERE status <> 'pending' AND created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// JSONMailQueueRepository keeps the mail queue in a JSON file, which is
+// created on the first write.
+type JSONMailQueueRepository struct {
+	store    *jsonStore[models.OutgoingMail]
+	writable bool
+}
+
+func NewJSONMailQueueRepository(path string) *JSONMailQueueRepository {
+	store := newJSONStore(path, func(m models.OutgoingMail) string { return m.ID })
+	store.missingOK = true
+	return &JSONMailQueueRepository{store: store}
+}
+
+func NewWritableJSONMailQueueRepository(path string) *JSONMailQueueRepository {
+	r := NewJSONMailQueueRepository(path)
+	r.writable = true
+	return r
+}
+
+func (r *JSONMailQueueRepository) Enqueue(m *models.OutgoingMail) error {
+	if !r.writable {
+		return ErrReadOnly
+	}
+	return r.store.insert(*m)
+}
+
+func (r *JSONMailQueueRepository) Claim(now time.Time, lease time.Duration, limit int) ([]models.OutgoingMail, error) {
+	if !r.writable {
+		return nil, nil
+	}
+	var claimed []models.OutgoingMail
+	err := r.store.update(func(items []models.OutgoingMail) ([]models.OutgoingMail, error) {
+		for i := range items {
+			if len(claimed) == limit {
+				break
+			}
+			if items[i].Status == models.MailStatusPending && !items[i].NextAttemptAt.After(now) {
+				items[i].NextAttemptAt = now.Add(lease)
+				claimed = append(claimed, items[i])
+			}
+		}
+		if len(claimed) == 0 {
+			return nil, errNoChange
+		}
+		return items, nil
+	})
+	return claimed, err
+}
+
+func (r *JSONMailQueueRepository) Save(m *models.OutgoingMail) error {
+	if !r.writable {
+		return ErrReadOnly
+	}
+	return r.store.replace(m)
+}
+
+func (r *JSONMailQueueRepository) Prune(before time.Time) (int, error) {
+	if !r.writable {
+		return 0, nil
+	}
+	pruned := 0
+	err := r.store.update(func(items []models.OutgoingMail) ([]models.OutgoingMail, error) {
+		kept := items[:0]
+		for _, m := range items {
+			if m.Status != models.MailStatusPending && m.CreatedAt.Before(before) {
+				pruned++
+				continue
+			}
+			kept = append(kept, m)
+		}
+		if pruned == 0 {
+			return nil, errNoChange
+		}
+		return kept, nil
+	})
+	return pruned, err
+}
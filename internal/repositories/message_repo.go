@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// MessageRepository stores contact-form messages. Lists can be filtered by
+// status and email and are sorted by created_at, newest first by default.
+type MessageRepository interface {
+	Create(m *models.Message) error
+	GetByID(id string) (*models.Message, error)
+	ListPage(q models.ListQuery) (*models.Page[models.Message], error)
+	SetStatus(id, status string, at time.Time) (*models.Message, error)
+	Delete(id string) error
+}
+
+type PGMessageRepository struct {
+	db *sql.DB
+}
+
+const messageColumns = `id, name, email, subject, body, status, COALESCE(ip, ''), COALESCE(user_agent, ''), created_at, updated_at`
+
+var messageListSpec = pgListSpec{
+	table:   "messages",
+	columns: messageColumns,
+	idExpr:  "id",
+	sorts: map[string]string{
+		"created_at": pgTimeSortKey("created_at"),
+	},
+	defaultSort: "-created_at",
+	filters: map[string]string{
+		"status": "status = ?",
+		"email":  "email = ?",
+	},
+	noTrash: true,
+}
+
+func NewPGMessageRepository(db *sql.DB) *PGMessageRepository {
+	return &PGMessageRepository{db: db}
+}
+
+func (r *PGMessageRepository) Create(m *models.Message) error {
+	query := `INSERT INTO messages (id, name, email, subject, body, status, ip, user_agent, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10)`
+	_, err := r.db.Exec(query, m.ID, m.Name, m.Email, m.Subject, m.Body, m.Status, m.IP, m.UserAgent, m.CreatedAt, m.UpdatedAt)
+	return pgError(err)
+}
+
+func (r *PGMessageRepository) GetByID(id string) (*models.Message, error) {
+	m, err := scanMessage(r.db.QueryRow(`SELECT `+messageColumns+` FROM messages WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+func (r *PGMessageRepository) ListPage(q models.ListQuery) (*models.Page[models.Message], error) {
+	return pgListPage(r.db, messageListSpec, q, func(rows *sql.Rows, sortKey, idKey *string) (models.Message, error) {
+		m, err := scanMessage(rows, sortKey, idKey)
+		if err != nil {
+			return models.Message{}, err
+		}
+		return *m, nil
+	})
+}
+
+func (r *PGMessageRepository) SetStatus(id, status string, at time.Time) (*models.Message, error) {
+	m, err := scanMessage(r.db.QueryRow(`UPDATE messages SET status = $2, updated_at = $3 WHERE id = $1 RETURNING `+messageColumns, id, status, at))
+	if err != nil {
+		return nil, pgUpdated(err)
+	}
+	return m, nil
+}
+
+func (r *PGMessageRepository) Delete(id string) error {
+	res, err := r.db.Exec(`DELETE FROM messages WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanMessage reads messageColumns, followed by any extra destinations.
+func scanMessage(row interface{ Scan(dest ...any) error }, extra ...any) (*models.Message, error) {
+	var m models.Message
+	dest := append([]any{&m.ID, &m.Name, &m.Email, &m.Subject, &m.Body, &m.Status, &m.IP, &m.UserAgent, &m.CreatedAt, &m.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// JSONMessageRepository keeps messages in a JSON Lines file. New messages
+// are appended; status changes and deletes rewrite the file.
+type JSONMessageRepository struct {
+	path     string
+	writable bool
+	mu       sync.Mutex
+}
+
+var jsonMessageListSpec = jsonListSpec[models.Message]{
+	id: func(m models.Message) string { return m.ID },
+	sorts: map[string]func(models.Message) string{
+		"created_at": func(m models.Message) string { return timeSortKey(&m.CreatedAt) },
+	},
+	defaultSort: "-created_at",
+	filters: map[string]func(models.Message, string) bool{
+		"status": func(m models.Message, v string) bool { return m.Status == v },
+		"email":  func(m models.Message, v string) bool { return m.Email == v },
+	},
+}
+
+func NewJSONMessageRepository(path string) *JSONMessageRepository {
+	return &JSONMessageRepository{path: path}
+}
+
+func NewWritableJSONMessageRepository(path string) *JSONMessageRepository {
+	return &JSONMessageRepository{path: path, writable: true}
+}
+
+func (r *JSONMessageRepository) Create(m *models.Message) error {
+	if !r.writable {
+		return ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return appendJSONLine(r.path, m)
+}
+
+func (r *JSONMessageRepository) GetByID(id string) (*models.Message, error) {
+	messages, err := readJSONLines[models.Message](r.path)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		if messages[i].ID == id {
+			return &messages[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *JSONMessageRepository) ListPage(q models.ListQuery) (*models.Page[models.Message], error) {
+	messages, err := readJSONLines[models.Message](r.path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonListPage(messages, jsonMessageListSpec, q)
+}
+
+func (r *JSONMessageRepository) SetStatus(id, status string, at time.Time) (*models.Message, error) {
+	var updated *models.Message
+	err := r.rewrite(func(messages []models.Message) ([]models.Message, error) {
+		for i := range messages {
+			if messages[i].ID == id {
+				messages[i].Status = status
+				messages[i].UpdatedAt = at
+				m := messages[i]
+				updated = &m
+				return messages, nil
+			}
+		}
+		return nil, ErrNotFound
+	})
+	return updated, err
+}
+
+func (r *JSONMessageRepository) Delete(id string) error {
+	return r.rewrite(func(messages []models.Message) ([]models.Message, error) {
+		for i := range messages {
+			if messages[i].ID == id {
+				return append(messages[:i], messages[i+1:]...), nil
+			}
+		}
+		return nil, ErrNotFound
+	})
+}
+
+// rewrite replaces the file with the messages fn returns.
+func (r *JSONMessageRepository) rewrite(fn func([]models.Message) ([]models.Message, error)) error {
+	if !r.writable {
+		return ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages, err := readJSONLines[models.Message](r.path)
+	if err != nil {
+		return err
+	}
+	if messages, err = fn(messages); err != nil {
+		return err
+	}
+	return writeJSONLines(r.path, messages)
+}
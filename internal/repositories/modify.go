@@ -0,0 +1,58 @@
+package repositories
+
+import "database/sql"
+
+// pgQuerier is satisfied by both *sql.DB and *sql.Tx.
+type pgQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// pgTx runs fn in a transaction, committing if it returns nil.
+func pgTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pgScanner reads a row of an entity table, followed by any extra
+// destinations.
+type pgScanner[T any] func(row interface{ Scan(dest ...any) error }, extra ...any) (*T, error)
+
+// pgModify loads the live row with id under a row lock, lets fn change it and
+// saves it with save, all in one transaction. An error from fn rolls the
+// transaction back and is returned as is.
+func pgModify[T any](
+	db *sql.DB, table, columns, id string,
+	scan pgScanner[T],
+	save func(q pgQuerier, item *T) error,
+	fn func(*T) error,
+) (*T, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	item, err := scan(tx.QueryRow(`SELECT `+columns+` FROM `+table+` WHERE id = $1 AND `+pgLive+` FOR UPDATE`, id))
+	if err != nil {
+		return nil, pgUpdated(err)
+	}
+	if err := fn(item); err != nil {
+		return nil, err
+	}
+	if err := save(tx, item); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
     "database/sql"
+    "time"
 
     "github.com/ScriptVandal/backend-go/internal/models"
 )
@@ -10,12 +11,27 @@ type PGContactRepository struct {
     db *sql.DB
 }
 
+// contactColumns are the columns read by scanContact, in order.
+const contactColumns = "id, email, telegram, linkedin, github, created_at, updated_at, version, deleted_at"
+
+var contactListSpec = pgListSpec{
+    table:   "contacts",
+    columns: contactColumns,
+    idExpr:  "id",
+    sorts: map[string]string{
+        "created_at": pgTimeSortKey("created_at"),
+        "updated_at": pgTimeSortKey("updated_at"),
+        "id":         "id",
+    },
+    defaultSort: "id",
+}
+
 func NewPGContactRepository(db *sql.DB) *PGContactRepository {
     return &PGContactRepository{db: db}
 }
 
 func (r *PGContactRepository) List() ([]models.Contact, error) {
-    rows, err := r.db.Query(`SELECT id, email, telegram, linkedin, github FROM contacts`)
+    rows, err := r.db.Query(`SELECT ` + contactColumns + ` FROM contacts WHERE ` + pgLive)
     if err != nil {
         return nil, err
     }
@@ -23,41 +39,82 @@ func (r *PGContactRepository) List() ([]models.Contact, error) {
 
     var items []models.Contact
     for rows.Next() {
-        var c models.Contact
-        if err := rows.Scan(&c.ID, &c.Email, &c.Telegram, &c.LinkedIn, &c.Github); err != nil {
+        c, err := scanContact(rows)
+        if err != nil {
             return nil, err
         }
-        items = append(items, c)
+        items = append(items, *c)
     }
     return items, rows.Err()
 }
 
+func (r *PGContactRepository) ListPage(q models.ListQuery) (*models.Page[models.Contact], error) {
+    return pgListPage(r.db, contactListSpec, q, func(rows *sql.Rows, sortKey, idKey *string) (models.Contact, error) {
+        c, err := scanContact(rows, sortKey, idKey)
+        if err != nil {
+            return models.Contact{}, err
+        }
+        return *c, nil
+    })
+}
+
 func (r *PGContactRepository) GetByID(id string) (*models.Contact, error) {
-    var c models.Contact
-    err := r.db.QueryRow(`SELECT id, email, telegram, linkedin, github FROM contacts WHERE id = $1`, id).
-        Scan(&c.ID, &c.Email, &c.Telegram, &c.LinkedIn, &c.Github)
+    c, err := scanContact(r.db.QueryRow(`SELECT `+contactColumns+` FROM contacts WHERE id = $1 AND `+pgLive, id))
     if err == sql.ErrNoRows {
         return nil, nil
     }
-    if err != nil {
-        return nil, err
-    }
-    return &c, nil
+    return c, err
 }
 
 func (r *PGContactRepository) Create(contact *models.Contact) error {
-    query := `INSERT INTO contacts (email, telegram, linkedin, github) VALUES ($1, $2, $3, $4)`
-    _, err := r.db.Exec(query, contact.Email, contact.Telegram, contact.LinkedIn, contact.Github)
-    return err
+    query := `INSERT INTO contacts (id, email, telegram, linkedin, github, created_at, updated_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+    _, err := r.db.Exec(query, contact.ID, contact.Email, contact.Telegram, contact.LinkedIn, contact.Github, contact.CreatedAt, contact.UpdatedAt, contact.Version)
+    return pgError(err)
 }
 
 func (r *PGContactRepository) Update(contact *models.Contact) error {
-    query := `UPDATE contacts SET email = $1, telegram = $2, linkedin = $3, github = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $5`
-    _, err := r.db.Exec(query, contact.Email, contact.Telegram, contact.LinkedIn, contact.Github, contact.ID)
-    return err
+    return updateContact(r.db, contact)
+}
+
+// Modify applies fn to the stored contact and saves the result in one transaction.
+func (r *PGContactRepository) Modify(id string, fn func(*models.Contact) error) (*models.Contact, error) {
+    return pgModify(r.db, "contacts", contactColumns, id, scanContact, updateContact, fn)
+}
+
+// Delete moves the contact to the trash if it is still at version.
+func (r *PGContactRepository) Delete(id string, version int) error {
+    return pgTrash(r.db, "contacts", id, version)
 }
 
-func (r *PGContactRepository) Delete(id string) error {
-    _, err := r.db.Exec(`DELETE FROM contacts WHERE id = $1`, id)
-    return err
-}
\ No newline at end of file
+func (r *PGContactRepository) Restore(id string) (*models.Contact, error) {
+    return pgRestore(r.db, "contacts", contactColumns, id, scanContact)
+}
+
+func (r *PGContactRepository) ListDeleted() ([]models.Contact, error) {
+    return pgListDeleted(r.db, "contacts", contactColumns, scanContact)
+}
+
+func (r *PGContactRepository) Purge(before time.Time) (int, error) {
+    return pgPurge(r.db, "contacts", models.EntityContact, before)
+}
+
+// updateContact saves contact and reads it back as stored.
+func updateContact(q pgQuerier, contact *models.Contact) error {
+    query := `UPDATE contacts SET email = $2, telegram = $3, linkedin = $4, github = $5, updated_at = $6, version = version + 1 WHERE id = $1 AND version = $7 AND deleted_at IS NULL RETURNING ` + contactColumns
+    stored, err := scanContact(q.QueryRow(query, contact.ID, contact.Email, contact.Telegram, contact.LinkedIn, contact.Github, contact.UpdatedAt, contact.Version))
+    if err != nil {
+        return pgStale(q, "contacts", contact.ID, err)
+    }
+    *contact = *stored
+    return nil
+}
+
+// scanContact reads contactColumns, followed by any extra destinations.
+func scanContact(row interface{ Scan(dest ...any) error }, extra ...any) (*models.Contact, error) {
+    var c models.Contact
+    dest := append([]any{&c.ID, &c.Email, &c.Telegram, &c.LinkedIn, &c.Github, &c.CreatedAt, &c.UpdatedAt, &c.Version, &c.DeletedAt}, extra...)
+    if err := row.Scan(dest...); err != nil {
+        return nil, err
+    }
+    return &c, nil
+}
@@ -2,6 +2,8 @@ package repositories
 
 import (
     "database/sql"
+    "encoding/json"
+    "time"
 
     "github.com/ScriptVandal/backend-go/internal/models"
     "github.com/lib/pq"
@@ -11,12 +13,37 @@ type PGPostRepository struct {
     db *sql.DB
 }
 
+// pgPostVisible matches posts that models.Post.IsVisible accepts.
+const pgPostVisible = `deleted_at IS NULL AND status IN ('published', 'scheduled') AND published_at <= now()`
+
+// postColumns are the columns read by scanPost, in order.
+var postColumns = "id, title, slug, content, content_html, toc, reading_time, tags, status, published_at, created_at, updated_at, version, deleted_at, " + pgTranslations("post_translations", "post_id", "posts")
+
+var postListSpec = pgListSpec{
+    table:   "posts",
+    columns: postColumns,
+    idExpr:  "id",
+    sorts: map[string]string{
+        "published_at": pgTimeSortKey("published_at"),
+        "title":        "title",
+        "created_at":   pgTimeSortKey("created_at"),
+        "updated_at":   pgTimeSortKey("updated_at"),
+        "id":           "id",
+    },
+    defaultSort: "-published_at",
+    filters: map[string]string{
+        "tag":    "? = ANY(tags)",
+        "status": "status = ?",
+    },
+    published: pgPostVisible,
+}
+
 func NewPGPostRepository(db *sql.DB) *PGPostRepository {
     return &PGPostRepository{db: db}
 }
 
 func (r *PGPostRepository) List() ([]models.Post, error) {
-    rows, err := r.db.Query(`SELECT id, title, content, tags, published_at FROM posts`)
+    rows, err := r.db.Query(`SELECT ` + postColumns + ` FROM posts WHERE ` + pgLive)
     if err != nil {
         return nil, err
     }
@@ -24,45 +51,157 @@ func (r *PGPostRepository) List() ([]models.Post, error) {
 
     var items []models.Post
     for rows.Next() {
-        var p models.Post
-        var tags []string
-        if err := rows.Scan(&p.ID, &p.Title, &p.Content, pq.Array(&tags), &p.PublishedAt); err != nil {
+        p, err := scanPost(rows)
+        if err != nil {
             return nil, err
         }
-        p.Tags = tags
-        items = append(items, p)
+        items = append(items, *p)
     }
     return items, rows.Err()
 }
 
+func (r *PGPostRepository) ListPage(q models.ListQuery) (*models.Page[models.Post], error) {
+    return pgListPage(r.db, postListSpec, q, func(rows *sql.Rows, sortKey, idKey *string) (models.Post, error) {
+        p, err := scanPost(rows, sortKey, idKey)
+        if err != nil {
+            return models.Post{}, err
+        }
+        return *p, nil
+    })
+}
+
 func (r *PGPostRepository) GetByID(id string) (*models.Post, error) {
-    var p models.Post
-    var tags []string
-    err := r.db.QueryRow(`SELECT id, title, content, tags, published_at FROM posts WHERE id = $1`, id).
-        Scan(&p.ID, &p.Title, &p.Content, pq.Array(&tags), &p.PublishedAt)
+    p, err := scanPost(r.db.QueryRow(`SELECT `+postColumns+` FROM posts WHERE id = $1 AND `+pgLive, id))
     if err == sql.ErrNoRows {
         return nil, nil
     }
+    return p, err
+}
+
+func (r *PGPostRepository) GetBySlug(slug string) (*models.Post, error) {
+    p, err := scanPost(r.db.QueryRow(`SELECT `+postColumns+` FROM posts WHERE slug = $1 AND `+pgLive, slug))
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    return p, err
+}
+
+// SlugTaken reports whether a post other than exceptID uses slug.
+func (r *PGPostRepository) SlugTaken(slug, exceptID string) (bool, error) {
+    var taken bool
+    err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM posts WHERE slug = $1 AND id <> $2)`, slug, exceptID).Scan(&taken)
+    return taken, err
+}
+
+func (r *PGPostRepository) Create(post *models.Post) error {
+    toc, err := json.Marshal(tocOrEmpty(post.TOC))
     if err != nil {
+        return err
+    }
+    query := `INSERT INTO posts (id, title, slug, content, content_html, toc, reading_time, tags, status, published_at, created_at, updated_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+    return pgTx(r.db, func(tx *sql.Tx) error {
+        _, err := tx.Exec(query, post.ID, post.Title, post.Slug, post.Content, post.ContentHTML, toc, post.ReadingTime, pq.Array(post.Tags), post.Status, post.PublishedAt, post.CreatedAt, post.UpdatedAt, post.Version)
+        if err != nil {
+            return pgError(err)
+        }
+        return savePostTranslations(tx, post)
+    })
+}
+
+func (r *PGPostRepository) Update(post *models.Post) error {
+    return pgTx(r.db, func(tx *sql.Tx) error {
+        return updatePost(tx, post)
+    })
+}
+
+// Modify applies fn to the stored post and saves the result in one transaction.
+func (r *PGPostRepository) Modify(id string, fn func(*models.Post) error) (*models.Post, error) {
+    return pgModify(r.db, "posts", postColumns, id, scanPost, updatePost, fn)
+}
+
+// Delete moves the post to the trash if it is still at version.
+func (r *PGPostRepository) Delete(id string, version int) error {
+    return pgTrash(r.db, "posts", id, version)
+}
+
+func (r *PGPostRepository) Restore(id string) (*models.Post, error) {
+    return pgRestore(r.db, "posts", postColumns, id, scanPost)
+}
+
+func (r *PGPostRepository) ListDeleted() ([]models.Post, error) {
+    return pgListDeleted(r.db, "posts", postColumns, scanPost)
+}
+
+func (r *PGPostRepository) Purge(before time.Time) (int, error) {
+    return pgPurge(r.db, "posts", models.EntityPost, before)
+}
+
+// updatePost saves post and reads it back as stored.
+func updatePost(q pgQuerier, post *models.Post) error {
+    toc, err := json.Marshal(tocOrEmpty(post.TOC))
+    if err != nil {
+        return err
+    }
+    query := `UPDATE posts SET title = $2, slug = $3, content = $4, content_html = $5, toc = $6, reading_time = $7, tags = $8, status = $9, published_at = $10, updated_at = $11, version = version + 1 WHERE id = $1 AND version = $12 AND deleted_at IS NULL RETURNING ` + postColumns
+    stored, err := scanPost(q.QueryRow(query, post.ID, post.Title, post.Slug, post.Content, post.ContentHTML, toc, post.ReadingTime, pq.Array(post.Tags), post.Status, post.PublishedAt, post.UpdatedAt, post.Version))
+    if err != nil {
+        return pgStale(q, "posts", post.ID, err)
+    }
+    if err := savePostTranslations(q, post); err != nil {
+        return err
+    }
+    stored.Translations = post.Translations
+    *post = *stored
+    return nil
+}
+
+// savePostTranslations replaces the stored translations of post with its own.
+func savePostTranslations(q pgQuerier, post *models.Post) error {
+    rows := make(map[string][]any, len(post.Translations))
+    for locale, t := range post.Translations {
+        toc, err := json.Marshal(tocOrEmpty(t.TOC))
+        if err != nil {
+            return err
+        }
+        rows[locale] = []any{t.Title, t.Content, t.ContentHTML, toc, t.ReadingTime}
+    }
+    return pgSaveTranslations(q, "post_translations", "post_id", post.ID, []string{"title", "content", "content_html", "toc", "reading_time"}, rows)
+}
+
+// scanPost reads postColumns, followed by any extra destinations.
+func scanPost(row interface{ Scan(dest ...any) error }, extra ...any) (*models.Post, error) {
+    var p models.Post
+    var tags []string
+    var toc, translations []byte
+    dest := append([]any{&p.ID, &p.Title, &p.Slug, &p.Content, &p.ContentHTML, &toc, &p.ReadingTime, pq.Array(&tags), &p.Status, &p.PublishedAt, &p.CreatedAt, &p.UpdatedAt, &p.Version, &p.DeletedAt, &translations}, extra...)
+    if err := row.Scan(dest...); err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(toc, &p.TOC); err != nil {
+        return nil, err
+    }
+    var err error
+    if p.Translations, err = unmarshalTranslations[models.PostTranslation](translations); err != nil {
         return nil, err
     }
     p.Tags = tags
     return &p, nil
 }
 
-func (r *PGPostRepository) Create(post *models.Post) error {
-    query := `INSERT INTO posts (id, title, content, tags, published_at) VALUES ($1, $2, $3, $4, $5)`
-    _, err := r.db.Exec(query, post.ID, post.Title, post.Content, pq.Array(post.Tags), post.PublishedAt)
-    return err
+// tocOrEmpty keeps a missing table of contents from being stored as null.
+func tocOrEmpty(toc []models.TOCEntry) []models.TOCEntry {
+    if toc == nil {
+        return []models.TOCEntry{}
+    }
+    return toc
 }
 
-func (r *PGPostRepository) Update(post *models.Post) error {
-    query := `UPDATE posts SET title = $2, content = $3, tags = $4, published_at = $5, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
-    _, err := r.db.Exec(query, post.ID, post.Title, post.Content, pq.Array(post.Tags), post.PublishedAt)
-    return err
+// PublishDue flips scheduled posts whose publish time has passed to published.
+func (r *PGPostRepository) PublishDue(now time.Time) (int, error) {
+    res, err := r.db.Exec(`UPDATE posts SET status = 'published', updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE status = 'scheduled' AND published_at <= $1 AND deleted_at IS NULL`, now)
+    if err != nil {
+        return 0, err
+    }
+    n, err := res.RowsAffected()
+    return int(n), err
 }
-
-func (r *PGPostRepository) Delete(id string) error {
-    _, err := r.db.Exec(`DELETE FROM posts WHERE id = $1`, id)
-    return err
-}
\ No newline at end of file
@@ -2,6 +2,7 @@ package repositories
 
 import (
     "database/sql"
+    "time"
 
     "github.com/ScriptVandal/backend-go/internal/models"
     "github.com/lib/pq"
@@ -11,12 +12,31 @@ type PGProjectRepository struct {
     db *sql.DB
 }
 
+// projectColumns are the columns read by scanProject, in order.
+var projectColumns = "id, title, slug, description, tags, url, created_at, updated_at, version, deleted_at, " + pgTranslations("project_translations", "project_id", "projects")
+
+var projectListSpec = pgListSpec{
+    table:   "projects",
+    columns: projectColumns,
+    idExpr:  "id",
+    sorts: map[string]string{
+        "title":      "title",
+        "created_at": pgTimeSortKey("created_at"),
+        "updated_at": pgTimeSortKey("updated_at"),
+        "id":         "id",
+    },
+    defaultSort: "title",
+    filters: map[string]string{
+        "tag": "? = ANY(tags)",
+    },
+}
+
 func NewPGProjectRepository(db *sql.DB) *PGProjectRepository {
     return &PGProjectRepository{db: db}
 }
 
 func (r *PGProjectRepository) List() ([]models.Project, error) {
-    rows, err := r.db.Query(`SELECT id, title, description, tags, url FROM projects`)
+    rows, err := r.db.Query(`SELECT ` + projectColumns + ` FROM projects WHERE ` + pgLive)
     if err != nil {
         return nil, err
     }
@@ -24,45 +44,124 @@ func (r *PGProjectRepository) List() ([]models.Project, error) {
 
     var items []models.Project
     for rows.Next() {
-        var p models.Project
-        var tags []string
-        if err := rows.Scan(&p.ID, &p.Title, &p.Description, pq.Array(&tags), &p.URL); err != nil {
+        p, err := scanProject(rows)
+        if err != nil {
             return nil, err
         }
-        p.Tags = tags
-        items = append(items, p)
+        items = append(items, *p)
     }
     return items, rows.Err()
 }
 
+func (r *PGProjectRepository) ListPage(q models.ListQuery) (*models.Page[models.Project], error) {
+    return pgListPage(r.db, projectListSpec, q, func(rows *sql.Rows, sortKey, idKey *string) (models.Project, error) {
+        p, err := scanProject(rows, sortKey, idKey)
+        if err != nil {
+            return models.Project{}, err
+        }
+        return *p, nil
+    })
+}
+
 func (r *PGProjectRepository) GetByID(id string) (*models.Project, error) {
-    var p models.Project
-    var tags []string
-    err := r.db.QueryRow(`SELECT id, title, description, tags, url FROM projects WHERE id = $1`, id).
-        Scan(&p.ID, &p.Title, &p.Description, pq.Array(&tags), &p.URL)
+    p, err := scanProject(r.db.QueryRow(`SELECT `+projectColumns+` FROM projects WHERE id = $1 AND `+pgLive, id))
     if err == sql.ErrNoRows {
         return nil, nil
     }
-    if err != nil {
-        return nil, err
+    return p, err
+}
+
+func (r *PGProjectRepository) GetBySlug(slug string) (*models.Project, error) {
+    p, err := scanProject(r.db.QueryRow(`SELECT `+projectColumns+` FROM projects WHERE slug = $1 AND `+pgLive, slug))
+    if err == sql.ErrNoRows {
+        return nil, nil
     }
-    p.Tags = tags
-    return &p, nil
+    return p, err
+}
+
+// SlugTaken reports whether a project other than exceptID uses slug.
+func (r *PGProjectRepository) SlugTaken(slug, exceptID string) (bool, error) {
+    var taken bool
+    err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM projects WHERE slug = $1 AND id <> $2)`, slug, exceptID).Scan(&taken)
+    return taken, err
 }
 
 func (r *PGProjectRepository) Create(project *models.Project) error {
-    query := `INSERT INTO projects (id, title, description, tags, url) VALUES ($1, $2, $3, $4, $5)`
-    _, err := r.db.Exec(query, project.ID, project.Title, project.Description, pq.Array(project.Tags), project.URL)
-    return err
+    query := `INSERT INTO projects (id, title, slug, description, tags, url, created_at, updated_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+    return pgTx(r.db, func(tx *sql.Tx) error {
+        _, err := tx.Exec(query, project.ID, project.Title, project.Slug, project.Description, pq.Array(project.Tags), project.URL, project.CreatedAt, project.UpdatedAt, project.Version)
+        if err != nil {
+            return pgError(err)
+        }
+        return saveProjectTranslations(tx, project)
+    })
 }
 
 func (r *PGProjectRepository) Update(project *models.Project) error {
-    query := `UPDATE projects SET title = $2, description = $3, tags = $4, url = $5, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
-    _, err := r.db.Exec(query, project.ID, project.Title, project.Description, pq.Array(project.Tags), project.URL)
-    return err
+    return pgTx(r.db, func(tx *sql.Tx) error {
+        return updateProject(tx, project)
+    })
+}
+
+// Modify applies fn to the stored project and saves the result in one transaction.
+func (r *PGProjectRepository) Modify(id string, fn func(*models.Project) error) (*models.Project, error) {
+    return pgModify(r.db, "projects", projectColumns, id, scanProject, updateProject, fn)
+}
+
+// Delete moves the project to the trash if it is still at version.
+func (r *PGProjectRepository) Delete(id string, version int) error {
+    return pgTrash(r.db, "projects", id, version)
 }
 
-func (r *PGProjectRepository) Delete(id string) error {
-    _, err := r.db.Exec(`DELETE FROM projects WHERE id = $1`, id)
-    return err
+func (r *PGProjectRepository) Restore(id string) (*models.Project, error) {
+    return pgRestore(r.db, "projects", projectColumns, id, scanProject)
+}
+
+func (r *PGProjectRepository) ListDeleted() ([]models.Project, error) {
+    return pgListDeleted(r.db, "projects", projectColumns, scanProject)
+}
+
+func (r *PGProjectRepository) Purge(before time.Time) (int, error) {
+    return pgPurge(r.db, "projects", models.EntityProject, before)
+}
+
+// updateProject saves project and reads it back as stored.
+func updateProject(q pgQuerier, project *models.Project) error {
+    query := `UPDATE projects SET title = $2, slug = $3, description = $4, tags = $5, url = $6, updated_at = $7, version = version + 1 WHERE id = $1 AND version = $8 AND deleted_at IS NULL RETURNING ` + projectColumns
+    stored, err := scanProject(q.QueryRow(query, project.ID, project.Title, project.Slug, project.Description, pq.Array(project.Tags), project.URL, project.UpdatedAt, project.Version))
+    if err != nil {
+        return pgStale(q, "projects", project.ID, err)
+    }
+    if err := saveProjectTranslations(q, project); err != nil {
+        return err
+    }
+    stored.Translations = project.Translations
+    *project = *stored
+    return nil
+}
+
+// saveProjectTranslations replaces the stored translations of project with its own.
+func saveProjectTranslations(q pgQuerier, project *models.Project) error {
+    rows := make(map[string][]any, len(project.Translations))
+    for locale, t := range project.Translations {
+        rows[locale] = []any{t.Title, t.Description}
+    }
+    return pgSaveTranslations(q, "project_translations", "project_id", project.ID, []string{"title", "description"}, rows)
+}
+
+// scanProject reads projectColumns, followed by any extra destinations.
+func scanProject(row interface{ Scan(dest ...any) error }, extra ...any) (*models.Project, error) {
+    var p models.Project
+    var tags []string
+    var translations []byte
+    dest := append([]any{&p.ID, &p.Title, &p.Slug, &p.Description, pq.Array(&tags), &p.URL, &p.CreatedAt, &p.UpdatedAt, &p.Version, &p.DeletedAt, &translations}, extra...)
+    if err := row.Scan(dest...); err != nil {
+        return nil, err
+    }
+    var err error
+    if p.Translations, err = unmarshalTranslations[models.ProjectTranslation](translations); err != nil {
+        return nil, err
+    }
+    p.Tags = tags
+    return &p, nil
 }
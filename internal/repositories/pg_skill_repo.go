@@ -2,6 +2,7 @@ package repositories
 
 import (
     "database/sql"
+    "time"
 
     "github.com/ScriptVandal/backend-go/internal/models"
 )
@@ -10,12 +11,34 @@ type PGSkillRepository struct {
     db *sql.DB
 }
 
+// skillColumns are the columns read by scanSkill, in order.
+var skillColumns = "id, name, level, category, created_at, updated_at, version, deleted_at, " + pgTranslations("skill_translations", "skill_id", "skills")
+
+var skillListSpec = pgListSpec{
+    table:   "skills",
+    columns: skillColumns,
+    idExpr:  "id",
+    sorts: map[string]string{
+        "name":       "name",
+        "level":      `COALESCE(level, '')`,
+        "category":   `COALESCE(category, '')`,
+        "created_at": pgTimeSortKey("created_at"),
+        "updated_at": pgTimeSortKey("updated_at"),
+        "id":         "id",
+    },
+    defaultSort: "name",
+    filters: map[string]string{
+        "category": "category = ?",
+        "level":    "level = ?",
+    },
+}
+
 func NewPGSkillRepository(db *sql.DB) *PGSkillRepository {
     return &PGSkillRepository{db: db}
 }
 
 func (r *PGSkillRepository) List() ([]models.Skill, error) {
-    rows, err := r.db.Query(`SELECT id, name, level, category FROM skills`)
+    rows, err := r.db.Query(`SELECT ` + skillColumns + ` FROM skills WHERE ` + pgLive)
     if err != nil {
         return nil, err
     }
@@ -23,41 +46,107 @@ func (r *PGSkillRepository) List() ([]models.Skill, error) {
 
     var items []models.Skill
     for rows.Next() {
-        var s models.Skill
-        if err := rows.Scan(&s.ID, &s.Name, &s.Level, &s.Category); err != nil {
+        s, err := scanSkill(rows)
+        if err != nil {
             return nil, err
         }
-        items = append(items, s)
+        items = append(items, *s)
     }
     return items, rows.Err()
 }
 
+func (r *PGSkillRepository) ListPage(q models.ListQuery) (*models.Page[models.Skill], error) {
+    return pgListPage(r.db, skillListSpec, q, func(rows *sql.Rows, sortKey, idKey *string) (models.Skill, error) {
+        s, err := scanSkill(rows, sortKey, idKey)
+        if err != nil {
+            return models.Skill{}, err
+        }
+        return *s, nil
+    })
+}
+
 func (r *PGSkillRepository) GetByID(id string) (*models.Skill, error) {
-    var s models.Skill
-    err := r.db.QueryRow(`SELECT id, name, level, category FROM skills WHERE id = $1`, id).
-        Scan(&s.ID, &s.Name, &s.Level, &s.Category)
+    s, err := scanSkill(r.db.QueryRow(`SELECT `+skillColumns+` FROM skills WHERE id = $1 AND `+pgLive, id))
     if err == sql.ErrNoRows {
         return nil, nil
     }
-    if err != nil {
-        return nil, err
-    }
-    return &s, nil
+    return s, err
 }
 
 func (r *PGSkillRepository) Create(skill *models.Skill) error {
-    query := `INSERT INTO skills (id, name, level, category) VALUES ($1, $2, $3, $4)`
-    _, err := r.db.Exec(query, skill.ID, skill.Name, skill.Level, skill.Category)
-    return err
+    query := `INSERT INTO skills (id, name, level, category, created_at, updated_at, version) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+    return pgTx(r.db, func(tx *sql.Tx) error {
+        _, err := tx.Exec(query, skill.ID, skill.Name, skill.Level, skill.Category, skill.CreatedAt, skill.UpdatedAt, skill.Version)
+        if err != nil {
+            return pgError(err)
+        }
+        return saveSkillTranslations(tx, skill)
+    })
 }
 
 func (r *PGSkillRepository) Update(skill *models.Skill) error {
-    query := `UPDATE skills SET name = $2, level = $3, category = $4, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
-    _, err := r.db.Exec(query, skill.ID, skill.Name, skill.Level, skill.Category)
-    return err
+    return pgTx(r.db, func(tx *sql.Tx) error {
+        return updateSkill(tx, skill)
+    })
 }
 
-func (r *PGSkillRepository) Delete(id string) error {
-    _, err := r.db.Exec(`DELETE FROM skills WHERE id = $1`, id)
-    return err
-}
\ No newline at end of file
+// Modify applies fn to the stored skill and saves the result in one transaction.
+func (r *PGSkillRepository) Modify(id string, fn func(*models.Skill) error) (*models.Skill, error) {
+    return pgModify(r.db, "skills", skillColumns, id, scanSkill, updateSkill, fn)
+}
+
+// Delete moves the skill to the trash if it is still at version.
+func (r *PGSkillRepository) Delete(id string, version int) error {
+    return pgTrash(r.db, "skills", id, version)
+}
+
+func (r *PGSkillRepository) Restore(id string) (*models.Skill, error) {
+    return pgRestore(r.db, "skills", skillColumns, id, scanSkill)
+}
+
+func (r *PGSkillRepository) ListDeleted() ([]models.Skill, error) {
+    return pgListDeleted(r.db, "skills", skillColumns, scanSkill)
+}
+
+func (r *PGSkillRepository) Purge(before time.Time) (int, error) {
+    return pgPurge(r.db, "skills", models.EntitySkill, before)
+}
+
+// updateSkill saves skill and reads it back as stored.
+func updateSkill(q pgQuerier, skill *models.Skill) error {
+    query := `UPDATE skills SET name = $2, level = $3, category = $4, updated_at = $5, version = version + 1 WHERE id = $1 AND version = $6 AND deleted_at IS NULL RETURNING ` + skillColumns
+    stored, err := scanSkill(q.QueryRow(query, skill.ID, skill.Name, skill.Level, skill.Category, skill.UpdatedAt, skill.Version))
+    if err != nil {
+        return pgStale(q, "skills", skill.ID, err)
+    }
+    if err := saveSkillTranslations(q, skill); err != nil {
+        return err
+    }
+    stored.Translations = skill.Translations
+    *skill = *stored
+    return nil
+}
+
+// saveSkillTranslations replaces the stored translations of skill with its own.
+func saveSkillTranslations(q pgQuerier, skill *models.Skill) error {
+    rows := make(map[string][]any, len(skill.Translations))
+    for locale, t := range skill.Translations {
+        rows[locale] = []any{t.Name, t.Category}
+    }
+    return pgSaveTranslations(q, "skill_translations", "skill_id", skill.ID, []string{"name", "category"}, rows)
+}
+
+// scanSkill reads skillColumns, followed by any extra destinations.
+func scanSkill(row interface{ Scan(dest ...any) error }, extra ...any) (*models.Skill, error) {
+    var s models.Skill
+    var translations []byte
+    dest := append([]any{&s.ID, &s.Name, &s.Level, &s.Category, &s.CreatedAt, &s.UpdatedAt, &s.Version, &s.DeletedAt, &translations}, extra...)
+    if err := row.Scan(dest...); err != nil {
+        return nil, err
+    }
+    var err error
+    if s.Translations, err = unmarshalTranslations[models.SkillTranslation](translations); err != nil {
+        return nil, err
+    }
+    return &s, nil
+}
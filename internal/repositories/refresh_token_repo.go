@@ -2,15 +2,27 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/ScriptVandal/backend-go/internal/models"
 )
 
+// ErrTokenAlreadyRotated is returned by Rotate when the token being replaced
+// has already been revoked or replaced by a concurrent request.
+var ErrTokenAlreadyRotated = errors.New("refresh token already rotated")
+
 type RefreshTokenRepository interface {
 	Create(token *models.RefreshToken) error
 	GetByJTI(jti string) (*models.RefreshToken, error)
 	Revoke(jti string) error
+	// Rotate revokes oldJTI, records next as its replacement and stores next,
+	// all in one transaction.
+	Rotate(oldJTI string, next *models.RefreshToken) error
+	RevokeFamily(familyID string) error
+	// RevokeAllForUser revokes every refresh token of a user, logging them
+	// out everywhere.
+	RevokeAllForUser(userID string) error
 	DeleteExpired() error
 }
 
@@ -23,15 +35,15 @@ func NewPGRefreshTokenRepository(db *sql.DB) *PGRefreshTokenRepository {
 }
 
 func (r *PGRefreshTokenRepository) Create(token *models.RefreshToken) error {
-	query := `INSERT INTO refresh_tokens (jti, user_id, expires_at, created_at) VALUES ($1, $2, $3, $4)`
-	_, err := r.db.Exec(query, token.JTI, token.UserID, token.ExpiresAt, token.CreatedAt)
+	query := `INSERT INTO refresh_tokens (jti, user_id, family_id, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Exec(query, token.JTI, token.UserID, token.FamilyID, token.ExpiresAt, token.CreatedAt)
 	return err
 }
 
 func (r *PGRefreshTokenRepository) GetByJTI(jti string) (*models.RefreshToken, error) {
-	query := `SELECT jti, user_id, expires_at, revoked_at, created_at FROM refresh_tokens WHERE jti = $1`
+	query := `SELECT jti, user_id, family_id, replaced_by, expires_at, revoked_at, created_at FROM refresh_tokens WHERE jti = $1`
 	var token models.RefreshToken
-	err := r.db.QueryRow(query, jti).Scan(&token.JTI, &token.UserID, &token.ExpiresAt, &token.RevokedAt, &token.CreatedAt)
+	err := r.db.QueryRow(query, jti).Scan(&token.JTI, &token.UserID, &token.FamilyID, &token.ReplacedBy, &token.ExpiresAt, &token.RevokedAt, &token.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -42,11 +54,53 @@ func (r *PGRefreshTokenRepository) GetByJTI(jti string) (*models.RefreshToken, e
 }
 
 func (r *PGRefreshTokenRepository) Revoke(jti string) error {
-	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE jti = $2`
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE jti = $2 AND revoked_at IS NULL`
 	_, err := r.db.Exec(query, time.Now(), jti)
 	return err
 }
 
+func (r *PGRefreshTokenRepository) Rotate(oldJTI string, next *models.RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only an unrevoked token may be rotated; losing this race means the
+	// same refresh token was presented twice.
+	res, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = $1, replaced_by = $2 WHERE jti = $3 AND revoked_at IS NULL`,
+		time.Now(), next.JTI, oldJTI)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTokenAlreadyRotated
+	}
+
+	_, err = tx.Exec(`INSERT INTO refresh_tokens (jti, user_id, family_id, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		next.JTI, next.UserID, next.FamilyID, next.ExpiresAt, next.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PGRefreshTokenRepository) RevokeFamily(familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, time.Now(), familyID)
+	return err
+}
+
+func (r *PGRefreshTokenRepository) RevokeAllForUser(userID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, time.Now(), userID)
+	return err
+}
+
 func (r *PGRefreshTokenRepository) DeleteExpired() error {
 	query := `DELETE FROM refresh_tokens WHERE expires_at < $1`
 	_, err := r.db.Exec(query, time.Now())
@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"database/sql"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// RevisionRepository keeps the saved versions of posts and projects.
+type RevisionRepository interface {
+	Add(rev *models.Revision) error
+	// List returns the revisions of an item without their snapshots, newest
+	// first.
+	List(entityType, entityID string) ([]models.Revision, error)
+	// Get returns one revision, or nil if it does not exist.
+	Get(entityType, entityID string, version int) (*models.Revision, error)
+}
+
+type PGRevisionRepository struct {
+	db *sql.DB
+}
+
+func NewPGRevisionRepository(db *sql.DB) *PGRevisionRepository {
+	return &PGRevisionRepository{db: db}
+}
+
+func (r *PGRevisionRepository) Add(rev *models.Revision) error {
+	query := `INSERT INTO revisions (entity_type, entity_id, version, author_id, created_at, snapshot)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		ON CONFLICT (entity_type, entity_id, version) DO NOTHING`
+	_, err := r.db.Exec(query, rev.EntityType, rev.EntityID, rev.Version, rev.AuthorID, rev.CreatedAt, string(rev.Snapshot))
+	return pgError(err)
+}
+
+func (r *PGRevisionRepository) List(entityType, entityID string) ([]models.Revision, error) {
+	rows, err := r.db.Query(`SELECT version, COALESCE(author_id, ''), created_at FROM revisions
+		WHERE entity_type = $1 AND entity_id = $2 ORDER BY version DESC`, entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revs := []models.Revision{}
+	for rows.Next() {
+		rev := models.Revision{EntityType: entityType, EntityID: entityID}
+		if err := rows.Scan(&rev.Version, &rev.AuthorID, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+	return revs, rows.Err()
+}
+
+func (r *PGRevisionRepository) Get(entityType, entityID string, version int) (*models.Revision, error) {
+	rev := models.Revision{EntityType: entityType, EntityID: entityID, Version: version}
+	var snapshot []byte
+	err := r.db.QueryRow(`SELECT COALESCE(author_id, ''), created_at, snapshot FROM revisions
+		WHERE entity_type = $1 AND entity_id = $2 AND version = $3`, entityType, entityID, version).
+		Scan(&rev.AuthorID, &rev.CreatedAt, &snapshot)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rev.Snapshot = snapshot
+	return &rev, nil
+}
+
+// JSONRevisionRepository stores revisions in a JSON file, which is created on
+// the first save.
+type JSONRevisionRepository struct {
+	store    *jsonStore[models.Revision]
+	writable bool
+}
+
+func NewJSONRevisionRepository(path string) *JSONRevisionRepository {
+	store := newJSONStore(path, func(r models.Revision) string {
+		return revisionKey(r.EntityType, r.EntityID, r.Version)
+	})
+	store.missingOK = true
+	return &JSONRevisionRepository{store: store}
+}
+
+func NewWritableJSONRevisionRepository(path string) *JSONRevisionRepository {
+	r := NewJSONRevisionRepository(path)
+	r.writable = true
+	return r
+}
+
+func revisionKey(entityType, entityID string, version int) string {
+	return entityType + "/" + entityID + "/" + strconv.Itoa(version)
+}
+
+func (r *JSONRevisionRepository) Add(rev *models.Revision) error {
+	if !r.writable {
+		return ErrReadOnly
+	}
+	err := r.store.insert(*rev)
+	if err == ErrConflict {
+		return nil
+	}
+	return err
+}
+
+func (r *JSONRevisionRepository) List(entityType, entityID string) ([]models.Revision, error) {
+	items, err := r.store.load()
+	if err != nil {
+		return nil, err
+	}
+	revs := []models.Revision{}
+	for _, rev := range items {
+		if rev.EntityType == entityType && rev.EntityID == entityID {
+			rev.Snapshot = nil
+			revs = append(revs, rev)
+		}
+	}
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Version > revs[j].Version })
+	return revs, nil
+}
+
+func (r *JSONRevisionRepository) Get(entityType, entityID string, version int) (*models.Revision, error) {
+	return r.store.get(revisionKey(entityType, entityID, version))
+}
+
+// deleteFor removes every revision of the items of entityType with ids.
+func (r *JSONRevisionRepository) deleteFor(entityType string, ids []string) error {
+	return r.store.update(func(items []models.Revision) ([]models.Revision, error) {
+		kept := items[:0]
+		for _, rev := range items {
+			if rev.EntityType != entityType || !slices.Contains(ids, rev.EntityID) {
+				kept = append(kept, rev)
+			}
+		}
+		if len(kept) == len(items) {
+			return nil, errNoChange
+		}
+		return kept, nil
+	})
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"database/sql"
+	"html"
+	"strings"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/lib/pq"
+)
+
+type SearchRepository interface {
+	Search(query string, limit int) ([]models.SearchResult, error)
+}
+
+// Snippets are produced with these control characters around matches and
+// turned into <mark> tags only after the surrounding text is HTML-escaped.
+const (
+	markStart = "\x02"
+	markStop  = "\x03"
+)
+
+// highlight escapes a snippet and replaces match sentinels with <mark> tags.
+func highlight(snippet string) string {
+	s := html.EscapeString(snippet)
+	s = strings.ReplaceAll(s, markStart, "<mark>")
+	return strings.ReplaceAll(s, markStop, "</mark>")
+}
+
+type PGSearchRepository struct {
+	db *sql.DB
+}
+
+func NewPGSearchRepository(db *sql.DB) *PGSearchRepository {
+	return &PGSearchRepository{db: db}
+}
+
+const pgSearchQuery = `
+WITH q AS (
+    SELECT websearch_to_tsquery('simple', $1) AS query,
+           'StartSel="' || chr(2) || '", StopSel="' || chr(3) || '", MaxWords=35, MinWords=15, MaxFragments=2' AS opts
+)
+SELECT 'post', id, title, slug, tags,
+       ts_headline('simple', coalesce(content, ''), q.query, q.opts),
+       ts_rank(search_vector, q.query)
+FROM posts, q
+WHERE search_vector @@ q.query AND ` + pgPostVisible + `
+UNION ALL
+SELECT 'project', id, title, slug, tags,
+       ts_headline('simple', coalesce(description, ''), q.query, q.opts),
+       ts_rank(search_vector, q.query)
+FROM projects, q
+WHERE search_vector @@ q.query AND ` + pgLive + `
+ORDER BY 7 DESC, 2
+LIMIT $2`
+
+func (r *PGSearchRepository) Search(query string, limit int) ([]models.SearchResult, error) {
+	rows, err := r.db.Query(pgSearchQuery, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var res models.SearchResult
+		var tags []string
+		if err := rows.Scan(&res.Type, &res.ID, &res.Title, &res.Slug, pq.Array(&tags), &res.Snippet, &res.Rank); err != nil {
+			return nil, err
+		}
+		res.Tags = tags
+		res.Snippet = highlight(res.Snippet)
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// SitemapRepository lists the published posts and projects that belong in
+// the sitemap, ordered by type and ID.
+type SitemapRepository interface {
+	Entries() ([]models.SitemapEntry, error)
+}
+
+type PGSitemapRepository struct {
+	db *sql.DB
+}
+
+func NewPGSitemapRepository(db *sql.DB) *PGSitemapRepository {
+	return &PGSitemapRepository{db: db}
+}
+
+func (r *PGSitemapRepository) Entries() ([]models.SitemapEntry, error) {
+	rows, err := r.db.Query(`
+SELECT 'post', id, slug, updated_at FROM posts WHERE ` + pgPostVisible + `
+UNION ALL
+SELECT 'project', id, slug, updated_at FROM projects WHERE ` + pgLive + `
+ORDER BY 1, 2`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.SitemapEntry{}
+	for rows.Next() {
+		var e models.SitemapEntry
+		if err := rows.Scan(&e.Type, &e.ID, &e.Slug, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// JSONSitemapRepository implements SitemapRepository over the posts and
+// projects JSON files. They have no modification times, so posts use their
+// publish time and projects have no lastmod.
+type JSONSitemapRepository struct {
+	posts    *jsonStore[models.Post]
+	projects *jsonStore[models.Project]
+}
+
+func NewJSONSitemapRepository(postsPath, projectsPath string) *JSONSitemapRepository {
+	return &JSONSitemapRepository{
+		posts:    newJSONStore(postsPath, func(p models.Post) string { return p.ID }),
+		projects: newJSONStore(projectsPath, func(p models.Project) string { return p.ID }),
+	}
+}
+
+func (r *JSONSitemapRepository) Entries() ([]models.SitemapEntry, error) {
+	posts, err := r.posts.load()
+	if err != nil {
+		return nil, err
+	}
+	projects, err := r.projects.load()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := []models.SitemapEntry{}
+	for _, p := range posts {
+		if p.IsVisible(now) {
+			entries = append(entries, models.SitemapEntry{Type: models.EntityPost, ID: p.ID, Slug: p.Slug, UpdatedAt: lastModified(p.UpdatedAt, p.PublishedAt)})
+		}
+	}
+	for _, p := range projects {
+		if p.DeletedAt != nil {
+			continue
+		}
+		entries = append(entries, models.SitemapEntry{Type: models.EntityProject, ID: p.ID, Slug: p.Slug, UpdatedAt: lastModified(p.UpdatedAt, nil)})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type < entries[j].Type
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// lastModified returns updated, or fallback for items saved before
+// timestamps were recorded.
+func lastModified(updated time.Time, fallback *time.Time) *time.Time {
+	if updated.IsZero() {
+		return fallback
+	}
+	return &updated
+}
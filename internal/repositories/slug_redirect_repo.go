@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"database/sql"
+	"slices"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// SlugRedirectRepository remembers retired slugs so that old URLs keep
+// resolving after an item is renamed.
+type SlugRedirectRepository interface {
+	Add(redirect *models.SlugRedirect) error
+	// Resolve returns the ID of the item that used to have slug, or "".
+	Resolve(entityType, slug string) (string, error)
+}
+
+type PGSlugRedirectRepository struct {
+	db *sql.DB
+}
+
+func NewPGSlugRedirectRepository(db *sql.DB) *PGSlugRedirectRepository {
+	return &PGSlugRedirectRepository{db: db}
+}
+
+func (r *PGSlugRedirectRepository) Add(redirect *models.SlugRedirect) error {
+	query := `INSERT INTO slug_redirects (entity_type, slug, target_id) VALUES ($1, $2, $3)
+		ON CONFLICT (entity_type, slug) DO UPDATE SET target_id = EXCLUDED.target_id, created_at = CURRENT_TIMESTAMP`
+	_, err := r.db.Exec(query, redirect.EntityType, redirect.Slug, redirect.TargetID)
+	return err
+}
+
+func (r *PGSlugRedirectRepository) Resolve(entityType, slug string) (string, error) {
+	var targetID string
+	err := r.db.QueryRow(`SELECT target_id FROM slug_redirects WHERE entity_type = $1 AND slug = $2`, entityType, slug).Scan(&targetID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return targetID, err
+}
+
+// JSONSlugRedirectRepository stores redirects in a JSON file, which is
+// created on the first rename.
+type JSONSlugRedirectRepository struct {
+	store    *jsonStore[models.SlugRedirect]
+	writable bool
+}
+
+func NewJSONSlugRedirectRepository(path string) *JSONSlugRedirectRepository {
+	store := newJSONStore(path, func(r models.SlugRedirect) string { return r.EntityType + "/" + r.Slug })
+	store.missingOK = true
+	return &JSONSlugRedirectRepository{store: store}
+}
+
+func NewWritableJSONSlugRedirectRepository(path string) *JSONSlugRedirectRepository {
+	r := NewJSONSlugRedirectRepository(path)
+	r.writable = true
+	return r
+}
+
+func (r *JSONSlugRedirectRepository) Add(redirect *models.SlugRedirect) error {
+	if !r.writable {
+		return ErrReadOnly
+	}
+	key := r.store.idOf(*redirect)
+	return r.store.update(func(items []models.SlugRedirect) ([]models.SlugRedirect, error) {
+		for i := range items {
+			if r.store.idOf(items[i]) == key {
+				items[i] = *redirect
+				return items, nil
+			}
+		}
+		return append(items, *redirect), nil
+	})
+}
+
+func (r *JSONSlugRedirectRepository) Resolve(entityType, slug string) (string, error) {
+	item, err := r.store.get(entityType + "/" + slug)
+	if err != nil || item == nil {
+		return "", err
+	}
+	return item.TargetID, nil
+}
+
+// deleteFor removes the redirects to the items of entityType with ids.
+func (r *JSONSlugRedirectRepository) deleteFor(entityType string, ids []string) error {
+	return r.store.update(func(items []models.SlugRedirect) ([]models.SlugRedirect, error) {
+		kept := items[:0]
+		for _, redirect := range items {
+			if redirect.EntityType != entityType || !slices.Contains(ids, redirect.TargetID) {
+				kept = append(kept, redirect)
+			}
+		}
+		if len(kept) == len(items) {
+			return nil, errNoChange
+		}
+		return kept, nil
+	})
+}
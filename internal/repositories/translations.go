@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pgTranslations is a column expression that aggregates the rows of a
+// translation table into one JSON object keyed by locale, each value holding
+// the row's columns other than owner and locale.
+func pgTranslations(table, owner, ownerTable string) string {
+	return fmt.Sprintf(`COALESCE((SELECT jsonb_object_agg(t.locale, to_jsonb(t) - '%[2]s' - 'locale') FROM %[1]s t WHERE t.%[2]s = %[3]s.id), '{}'::jsonb)`, table, owner, ownerTable)
+}
+
+// unmarshalTranslations decodes a pgTranslations column. No translations
+// decode to nil.
+func unmarshalTranslations[T any](raw []byte) (map[string]T, error) {
+	var m map[string]T
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// pgSaveTranslations replaces the translations of item id in table with
+// rows, which map a locale to the values of columns.
+func pgSaveTranslations(q pgQuerier, table, owner, id string, columns []string, rows map[string][]any) error {
+	if _, err := q.Exec(`DELETE FROM `+table+` WHERE `+owner+` = $1`, id); err != nil {
+		return err
+	}
+	placeholders := make([]string, len(columns)+2)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s, locale, %s) VALUES (%s)",
+		table, owner, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+
+	locales := make([]string, 0, len(rows))
+	for locale := range rows {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	for _, locale := range locales {
+		args := append([]any{id, locale}, rows[locale]...)
+		if _, err := q.Exec(query, args...); err != nil {
+			return pgError(err)
+		}
+	}
+	return nil
+}
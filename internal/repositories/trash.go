@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+)
+
+// pgLive matches rows that are not in the trash.
+const pgLive = "deleted_at IS NULL"
+
+// pgTrash moves the row with id to the trash if it is still at version.
+func pgTrash(db *sql.DB, table, id string, version int) error {
+	res, err := db.Exec(`UPDATE `+table+` SET deleted_at = now(), version = version + 1 WHERE id = $1 AND version = $2 AND `+pgLive, id, version)
+	return pgDeleted(db, table, id, res, err)
+}
+
+// pgRestore takes the row with id out of the trash and returns it.
+func pgRestore[T any](db *sql.DB, table, columns, id string, scan pgScanner[T]) (*T, error) {
+	item, err := scan(db.QueryRow(`UPDATE `+table+` SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND deleted_at IS NOT NULL RETURNING `+columns, id))
+	if err != nil {
+		return nil, pgUpdated(err)
+	}
+	return item, nil
+}
+
+// pgListDeleted returns the rows in the trash, most recently deleted first.
+func pgListDeleted[T any](db *sql.DB, table, columns string, scan pgScanner[T]) ([]T, error) {
+	rows, err := db.Query(`SELECT ` + columns + ` FROM ` + table + ` WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// pgPurge permanently deletes rows that were moved to the trash before
+// before, together with their revisions and the slug redirects pointing at
+// them, and returns how many rows there were. Everything goes in one
+// statement, so a purged item never leaves its history behind.
+func pgPurge(db *sql.DB, table, entityType string, before time.Time) (int, error) {
+	query := `WITH purged AS (
+			DELETE FROM ` + table + ` WHERE deleted_at < $1 RETURNING id
+		), revisions AS (
+			DELETE FROM revisions WHERE entity_type = $2 AND entity_id IN (SELECT id FROM purged)
+		), redirects AS (
+			DELETE FROM slug_redirects WHERE entity_type = $2 AND target_id IN (SELECT id FROM purged)
+		)
+		SELECT count(*) FROM purged`
+	var n int
+	if err := db.QueryRow(query, before, entityType).Scan(&n); err != nil {
+		return 0, pgError(err)
+	}
+	return n, nil
+}
+
+// jsonHistory removes the revisions and slug redirects of items purged from
+// a JSON repository. It is nil for repositories without history.
+type jsonHistory struct {
+	revisions *JSONRevisionRepository
+	redirects *JSONSlugRedirectRepository
+}
+
+// forget deletes the history of the items with ids. The files cannot be
+// changed together, so the history goes before the items: if the items fail
+// to be written they stay in the trash and the next purge tries again.
+func (h *jsonHistory) forget(entityType string, ids []string) error {
+	if h == nil {
+		return nil
+	}
+	if err := h.revisions.deleteFor(entityType, ids); err != nil {
+		return err
+	}
+	return h.redirects.deleteFor(entityType, ids)
+}
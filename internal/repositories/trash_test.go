@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+func TestJSONPurgeDeletesHistory(t *testing.T) {
+	dir := t.TempDir()
+	revisions := NewWritableJSONRevisionRepository(filepath.Join(dir, "revisions.json"))
+	redirects := NewWritableJSONSlugRedirectRepository(filepath.Join(dir, "slug_redirects.json"))
+	postsPath := filepath.Join(dir, "posts.json")
+	if err := os.WriteFile(postsPath, []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	posts := NewWritableJSONPostRepository(postsPath, revisions, redirects)
+
+	for _, id := range []string{"gone", "kept"} {
+		if err := posts.Create(&models.Post{ID: id, Title: id, Slug: id}); err != nil {
+			t.Fatal(err)
+		}
+		for v := 1; v <= 2; v++ {
+			if err := revisions.Add(&models.Revision{EntityType: models.EntityPost, EntityID: id, Version: v, Snapshot: []byte(`{}`)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := redirects.Add(&models.SlugRedirect{EntityType: models.EntityPost, Slug: "old-" + id, TargetID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A project with the same ID keeps its history.
+	if err := revisions.Add(&models.Revision{EntityType: models.EntityProject, EntityID: "gone", Version: 1, Snapshot: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	gone, err := posts.GetByID("gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := posts.Delete("gone", gone.Version); err != nil {
+		t.Fatal(err)
+	}
+	n, err := posts.Purge(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("purged %d posts, want 1", n)
+	}
+
+	if revs, _ := revisions.List(models.EntityPost, "gone"); len(revs) != 0 {
+		t.Errorf("purged post still has %d revisions", len(revs))
+	}
+	if id, _ := redirects.Resolve(models.EntityPost, "old-gone"); id != "" {
+		t.Errorf("old slug of the purged post still redirects to %q", id)
+	}
+	if revs, _ := revisions.List(models.EntityPost, "kept"); len(revs) != 2 {
+		t.Errorf("post in use has %d revisions, want 2", len(revs))
+	}
+	if id, _ := redirects.Resolve(models.EntityPost, "old-kept"); id != "kept" {
+		t.Errorf("old slug of the post in use redirects to %q", id)
+	}
+	if revs, _ := revisions.List(models.EntityProject, "gone"); len(revs) != 1 {
+		t.Errorf("project with the purged ID has %d revisions, want 1", len(revs))
+	}
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/ScriptVandal/backend-go/internal/models"
 )
@@ -10,6 +11,10 @@ type UserRepository interface {
 	Create(user *models.User) error
 	GetByEmail(email string) (*models.User, error)
 	GetByID(id string) (*models.User, error)
+	UpdateRole(id, role string) error
+	CountByRole(role string) (int, error)
+	MarkEmailVerified(id string, at time.Time) error
+	UpdatePassword(id, passwordHash string) error
 }
 
 type PGUserRepository struct {
@@ -21,15 +26,15 @@ func NewPGUserRepository(db *sql.DB) *PGUserRepository {
 }
 
 func (r *PGUserRepository) Create(user *models.User) error {
-	query := `INSERT INTO users (id, email, password_hash, created_at) VALUES ($1, $2, $3, $4)`
-	_, err := r.db.Exec(query, user.ID, user.Email, user.PasswordHash, user.CreatedAt)
+	query := `INSERT INTO users (id, email, password_hash, role, email_verified_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.Exec(query, user.ID, user.Email, user.PasswordHash, user.Role, user.EmailVerifiedAt, user.CreatedAt)
 	return err
 }
 
 func (r *PGUserRepository) GetByEmail(email string) (*models.User, error) {
-	query := `SELECT id, email, password_hash, created_at FROM users WHERE email = $1`
+	query := `SELECT id, email, password_hash, role, email_verified_at, created_at FROM users WHERE email = $1`
 	var user models.User
-	err := r.db.QueryRow(query, email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt)
+	err := r.db.QueryRow(query, email).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.EmailVerifiedAt, &user.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -40,9 +45,9 @@ func (r *PGUserRepository) GetByEmail(email string) (*models.User, error) {
 }
 
 func (r *PGUserRepository) GetByID(id string) (*models.User, error) {
-	query := `SELECT id, email, password_hash, created_at FROM users WHERE id = $1`
+	query := `SELECT id, email, password_hash, role, email_verified_at, created_at FROM users WHERE id = $1`
 	var user models.User
-	err := r.db.QueryRow(query, id).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt)
+	err := r.db.QueryRow(query, id).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.EmailVerifiedAt, &user.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -51,3 +56,26 @@ func (r *PGUserRepository) GetByID(id string) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+func (r *PGUserRepository) UpdateRole(id, role string) error {
+	_, err := r.db.Exec(`UPDATE users SET role = $1 WHERE id = $2`, role, id)
+	return err
+}
+
+func (r *PGUserRepository) CountByRole(role string) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM users WHERE role = $1`, role).Scan(&n)
+	return n, err
+}
+
+// MarkEmailVerified records when the user verified their email. An earlier
+// verification is kept.
+func (r *PGUserRepository) MarkEmailVerified(id string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE users SET email_verified_at = COALESCE(email_verified_at, $1) WHERE id = $2`, at, id)
+	return err
+}
+
+func (r *PGUserRepository) UpdatePassword(id, passwordHash string) error {
+	_, err := r.db.Exec(`UPDATE users SET password_hash = $1 WHERE id = $2`, passwordHash, id)
+	return err
+}
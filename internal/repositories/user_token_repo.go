@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// UserTokenRepository stores the hashes of email verification and password
+// reset tokens.
+type UserTokenRepository interface {
+	Create(token *models.UserToken) error
+	// Consume marks the unused, unexpired token with hash and purpose as used
+	// at now and returns it, or nil if there is no such token. A token can
+	// only be consumed once, even by concurrent requests.
+	Consume(hash, purpose string, now time.Time) (*models.UserToken, error)
+	// Invalidate uses up every outstanding token of a user for purpose.
+	Invalidate(userID, purpose string, now time.Time) error
+}
+
+type PGUserTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPGUserTokenRepository(db *sql.DB) *PGUserTokenRepository {
+	return &PGUserTokenRepository{db: db}
+}
+
+func (r *PGUserTokenRepository) Create(token *models.UserToken) error {
+	query := `INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Exec(query, token.Hash, token.UserID, token.Purpose, token.ExpiresAt, token.CreatedAt)
+	return pgError(err)
+}
+
+func (r *PGUserTokenRepository) Consume(hash, purpose string, now time.Time) (*models.UserToken, error) {
+	query := `UPDATE user_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING token_hash, user_id, purpose, expires_at, used_at, created_at`
+	var t models.UserToken
+	err := r.db.QueryRow(query, hash, purpose, now).Scan(&t.Hash, &t.UserID, &t.Purpose, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *PGUserTokenRepository) Invalidate(userID, purpose string, now time.Time) error {
+	query := `UPDATE user_tokens SET used_at = $3 WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+	_, err := r.db.Exec(query, userID, purpose, now)
+	return err
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/uuid"
+)
+
+// maxUserAgentLen caps the user agent kept in the audit log.
+const maxUserAgentLen = 512
+
+type AuditRepo interface {
+	Append(entry *models.AuditEntry) error
+	ListPage(q models.ListQuery) (*models.Page[models.AuditEntry], error)
+}
+
+// AuditService records writes and authentication events and lets admins
+// read them back.
+type AuditService struct {
+	repo AuditRepo
+}
+
+func NewAuditService(repo AuditRepo) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record appends an entry for action by actor. before and after are the item
+// on either side of the change; pass nil where there is none. The change
+// itself has already happened, so a failure is logged rather than returned.
+func (s *AuditService) Record(actor models.Actor, action, entityType, entityID string, before, after any) {
+	entry := &models.AuditEntry{
+		ID:         uuid.NewV7(),
+		At:         timestamp(),
+		ActorID:    actor.UserID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		IP:         actor.IP,
+		UserAgent:  actor.UserAgent,
+	}
+	entry.UserAgent = truncate(entry.UserAgent, maxUserAgentLen)
+	var err error
+	if entry.Before, err = auditJSON(before); err == nil {
+		entry.After, err = auditJSON(after)
+	}
+	if err == nil {
+		err = s.repo.Append(entry)
+	}
+	if err != nil {
+		log.Printf("audit: recording %s %s %s: %v", action, entityType, entityID, err)
+	}
+}
+
+func auditJSON(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// List returns a page of entries, newest first. since and until filters
+// must be RFC 3339 times.
+func (s *AuditService) List(q models.ListQuery) (*models.Page[models.AuditEntry], error) {
+	for _, name := range []string{"since", "until"} {
+		v, ok := q.Filters[name]
+		if !ok || v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, apperr.Validation(apperr.Field(name, "must be an RFC 3339 time"))
+		}
+		q.Filters[name] = t.UTC().Format(time.RFC3339Nano)
+	}
+	return s.repo.ListPage(q)
+}
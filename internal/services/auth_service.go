@@ -2,40 +2,108 @@ package services
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/ScriptVandal/backend-go/internal/apperr"
 	"github.com/ScriptVandal/backend-go/internal/config"
 	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/ratelimit"
 	"github.com/ScriptVandal/backend-go/internal/repositories"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/argon2"
 )
 
+// ErrRefreshTokenReuse is returned when an already rotated refresh token is
+// presented again; the whole token family is revoked when this happens.
+var ErrRefreshTokenReuse = apperr.Unauthorized("refresh token reuse detected, please log in again")
+
+// ThrottledError is returned by Login while an account or client IP has to
+// wait after failed logins.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return "login throttled for " + e.RetryAfter.String()
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return apperr.New(apperr.CodeTooManyRequests, "too many failed login attempts, try again later")
+}
+
+var (
+	// ErrEmailNotVerified is returned by Login when email verification is
+	// required and the user has not verified their email yet.
+	ErrEmailNotVerified = apperr.Forbidden("email not verified; follow the link in the verification mail")
+	ErrInvalidUserToken = apperr.Validation(apperr.Field("token", "is invalid, expired or already used"))
+)
+
+const (
+	// accountMailLimit caps the verification and password reset mails sent
+	// to one address per accountMailWindow, so that the endpoints cannot be
+	// used to flood someone's inbox.
+	accountMailLimit  = 3
+	accountMailWindow = time.Hour
+
+	// loginFreeFailures is how many failed logins for an account cost
+	// nothing; from then on each one waits loginBackoff, doubling, until
+	// LoginMaxFailures locks the account. Client IPs, which may be shared,
+	// get half of LoginIPMaxFailures for free.
+	loginFreeFailures = 3
+	loginBackoff      = time.Second
+)
+
 type AuthService struct {
 	userRepo         repositories.UserRepository
 	refreshTokenRepo repositories.RefreshTokenRepository
-	config           *config.Config
+	userTokenRepo    repositories.UserTokenRepository
+	audit            *AuditService
+	mail             *MailService
+	accountMail      *ratelimit.Limiter
+	accountFailures  *ratelimit.Backoff
+	ipFailures       *ratelimit.Backoff
+	// dummyHash is checked against for unknown emails, so that they take
+	// as long as a wrong password.
+	dummyHash string
+	// background tracks the work of requests that answer before it is done.
+	background sync.WaitGroup
+	config     *config.Config
 }
 
-func NewAuthService(userRepo repositories.UserRepository, refreshTokenRepo repositories.RefreshTokenRepository, cfg *config.Config) *AuthService {
-	return &AuthService{
+func NewAuthService(userRepo repositories.UserRepository, refreshTokenRepo repositories.RefreshTokenRepository, userTokenRepo repositories.UserTokenRepository, audit *AuditService, mail *MailService, cfg *config.Config) *AuthService {
+	s := &AuthService{
 		userRepo:         userRepo,
 		refreshTokenRepo: refreshTokenRepo,
+		userTokenRepo:    userTokenRepo,
+		audit:            audit,
+		mail:             mail,
+		accountMail:      ratelimit.New(accountMailLimit, accountMailWindow),
+		accountFailures:  ratelimit.NewBackoff(loginFreeFailures, cfg.LoginMaxFailures, loginBackoff, cfg.LoginLockout),
+		ipFailures:       ratelimit.NewBackoff(cfg.LoginIPMaxFailures/2, cfg.LoginIPMaxFailures, loginBackoff, cfg.LoginLockout),
 		config:           cfg,
 	}
+	s.dummyHash, _ = s.hashPassword(generateID())
+	return s
 }
 
-// Register creates a new user
-func (s *AuthService) Register(email, password string) (*models.User, error) {
+// Register creates a new user, mails them a link to verify their email and
+// notifies the site owners.
+func (s *AuthService) Register(email, password string, actor models.Actor) (*models.User, error) {
 	// Check if user already exists
 	existing, err := s.userRepo.GetByEmail(email)
 	if err != nil {
 		return nil, err
 	}
 	if existing != nil {
-		return nil, errors.New("user already exists")
+		return nil, apperr.Conflict("user already exists")
 	}
 
 	// Hash password
@@ -44,11 +112,13 @@ func (s *AuthService) Register(email, password string) (*models.User, error) {
 		return nil, err
 	}
 
-	// Create user
+	// Create user. Everyone starts as a viewer; the configured admin email
+	// is promoted once it has been verified, see claimAdmin.
 	user := &models.User{
 		ID:           generateID(),
 		Email:        email,
 		PasswordHash: passwordHash,
+		Role:         models.RoleViewer,
 		CreatedAt:    time.Now(),
 	}
 
@@ -56,51 +126,76 @@ func (s *AuthService) Register(email, password string) (*models.User, error) {
 		return nil, err
 	}
 
+	actor.UserID = user.ID
+	s.audit.Record(actor, models.AuditRegister, models.EntityUser, user.ID, nil, user)
+	s.mail.Notify("new_user", map[string]any{"Email": user.Email, "Role": user.Role})
+	if err := s.sendUserToken(user, models.TokenVerifyEmail); err != nil {
+		log.Printf("auth: sending verification mail to %s: %v", user.ID, err)
+	}
 	return user, nil
 }
 
-// Login authenticates a user and returns tokens
-func (s *AuthService) Login(email, password string) (*models.User, string, string, error) {
+// Login authenticates a user and returns tokens. Failed attempts are
+// audited with the email that was tried, and slow down further attempts for
+// that email and from that IP: repeated failures get a growing wait and
+// finally a lockout, during which Login returns a *ThrottledError without
+// checking the password. An unknown email costs as much time as a wrong
+// password, so responses do not tell which accounts exist.
+func (s *AuthService) Login(email, password string, actor models.Actor) (*models.User, string, string, error) {
+	now := time.Now()
+	account := strings.ToLower(strings.TrimSpace(email))
+	if wait := max(s.accountFailures.Wait(account, now), s.ipFailures.Wait(actor.IP, now)); wait > 0 {
+		return nil, "", "", &ThrottledError{RetryAfter: wait}
+	}
+
 	user, err := s.userRepo.GetByEmail(email)
 	if err != nil {
 		return nil, "", "", err
 	}
-	if user == nil {
-		return nil, "", "", errors.New("invalid credentials")
+	passwordHash := s.dummyHash
+	if user != nil {
+		passwordHash = user.PasswordHash
 	}
 
 	// Verify password
-	if !s.verifyPassword(password, user.PasswordHash) {
-		return nil, "", "", errors.New("invalid credentials")
+	if !s.verifyPassword(password, passwordHash) || user == nil {
+		userID := ""
+		if user != nil {
+			userID = user.ID
+		}
+		s.audit.Record(actor, models.AuditLoginFailed, models.EntityUser, userID, nil, map[string]string{"email": email})
+		s.loginFailed(account, userID, actor, now)
+		return nil, "", "", apperr.Unauthorized("invalid credentials")
+	}
+	s.accountFailures.Reset(account)
+	if s.config.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return user, "", "", ErrEmailNotVerified
 	}
 
 	// Generate tokens
-	accessToken, err := s.generateAccessToken(user.ID)
+	accessToken, err := s.generateAccessToken(user.ID, user.Role)
 	if err != nil {
 		return nil, "", "", err
 	}
 
-	refreshToken, jti, err := s.generateRefreshToken(user.ID)
+	// Every login starts a new token family
+	refreshToken, token, err := s.issueRefreshToken(user.ID, generateID())
 	if err != nil {
 		return nil, "", "", err
 	}
-
-	// Store refresh token
-	token := &models.RefreshToken{
-		JTI:       jti,
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(s.config.RefreshTTL),
-		CreatedAt: time.Now(),
-	}
 	if err := s.refreshTokenRepo.Create(token); err != nil {
 		return nil, "", "", err
 	}
 
+	actor.UserID = user.ID
+	s.audit.Record(actor, models.AuditLogin, models.EntityUser, user.ID, nil, nil)
 	return user, accessToken, refreshToken, nil
 }
 
-// Refresh generates new access token from refresh token
-func (s *AuthService) Refresh(refreshTokenString string) (string, error) {
+// Refresh rotates a refresh token: the presented token is revoked and a new
+// access/refresh pair in the same family is returned. Presenting a token that
+// has already been rotated revokes the whole family and forces a new login.
+func (s *AuthService) Refresh(refreshTokenString string, actor models.Actor) (string, string, error) {
 	// Parse refresh token
 	token, err := jwt.Parse(refreshTokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -110,50 +205,75 @@ func (s *AuthService) Refresh(refreshTokenString string) (string, error) {
 	})
 
 	if err != nil || !token.Valid {
-		return "", errors.New("invalid refresh token")
+		return "", "", apperr.Unauthorized("invalid refresh token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", errors.New("invalid token claims")
+		return "", "", apperr.Unauthorized("invalid token claims")
 	}
 
 	userID, ok := claims["sub"].(string)
 	if !ok {
-		return "", errors.New("invalid user ID in token")
+		return "", "", apperr.Unauthorized("invalid user ID in token")
 	}
 
 	jti, ok := claims["jti"].(string)
 	if !ok {
-		return "", errors.New("invalid JTI in token")
+		return "", "", apperr.Unauthorized("invalid JTI in token")
 	}
 
 	// Check if token is revoked
 	storedToken, err := s.refreshTokenRepo.GetByJTI(jti)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	if storedToken == nil {
-		return "", errors.New("token not found")
+	if storedToken == nil || storedToken.UserID != userID {
+		return "", "", apperr.Unauthorized("token not found")
 	}
 	if storedToken.RevokedAt != nil {
-		return "", errors.New("token has been revoked")
+		if storedToken.ReplacedBy != nil {
+			return "", "", s.revokeFamily(storedToken.FamilyID)
+		}
+		return "", "", apperr.Unauthorized("token has been revoked")
 	}
 	if time.Now().After(storedToken.ExpiresAt) {
-		return "", errors.New("token has expired")
+		return "", "", apperr.Unauthorized("token has expired")
 	}
 
-	// Generate new access token
-	accessToken, err := s.generateAccessToken(userID)
+	// Look the user up again so role changes apply on the next refresh
+	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	if user == nil {
+		return "", "", apperr.Unauthorized("user not found")
+	}
+
+	// Generate new token pair
+	accessToken, err := s.generateAccessToken(user.ID, user.Role)
+	if err != nil {
+		return "", "", err
 	}
 
-	return accessToken, nil
+	refreshToken, next, err := s.issueRefreshToken(userID, storedToken.FamilyID)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.refreshTokenRepo.Rotate(jti, next); err != nil {
+		if errors.Is(err, repositories.ErrTokenAlreadyRotated) {
+			return "", "", s.revokeFamily(storedToken.FamilyID)
+		}
+		return "", "", err
+	}
+
+	actor.UserID = userID
+	s.audit.Record(actor, models.AuditRefresh, models.EntityUser, userID, nil, nil)
+	return accessToken, refreshToken, nil
 }
 
 // Logout revokes the refresh token
-func (s *AuthService) Logout(refreshTokenString string) error {
+func (s *AuthService) Logout(refreshTokenString string, actor models.Actor) error {
 	token, err := jwt.Parse(refreshTokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")
@@ -162,24 +282,155 @@ func (s *AuthService) Logout(refreshTokenString string) error {
 	})
 
 	if err != nil {
-		return err
+		return apperr.Unauthorized("invalid refresh token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return errors.New("invalid token claims")
+		return apperr.Unauthorized("invalid token claims")
 	}
 
 	jti, ok := claims["jti"].(string)
 	if !ok {
-		return errors.New("invalid JTI in token")
+		return apperr.Unauthorized("invalid JTI in token")
+	}
+
+	if err := s.refreshTokenRepo.Revoke(jti); err != nil {
+		return err
+	}
+	if userID, ok := claims["sub"].(string); ok {
+		actor.UserID = userID
 	}
+	s.audit.Record(actor, models.AuditLogout, models.EntityUser, actor.UserID, nil, nil)
+	return nil
+}
 
-	return s.refreshTokenRepo.Revoke(jti)
+// SetUserRole changes the role of an existing user on behalf of actor. The
+// new role takes effect when the user's access token is next refreshed.
+func (s *AuthService) SetUserRole(userID, role string, actor models.Actor) (*models.User, error) {
+	if !models.ValidRole(role) {
+		return nil, apperr.Validation(apperr.Field("role", "must be one of admin, editor, viewer"))
+	}
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperr.NotFound("user not found")
+	}
+	if err := s.userRepo.UpdateRole(userID, role); err != nil {
+		return nil, err
+	}
+	before := *user
+	user.Role = role
+	s.audit.Record(actor, models.AuditRoleChange, models.EntityUser, userID, &before, user)
+	return user, nil
+}
+
+// VerifyEmail marks the email of the user a verification token was mailed
+// to as verified. The token is used up. The configured admin email becomes
+// admin here while no admin exists.
+func (s *AuthService) VerifyEmail(token string, actor models.Actor) (*models.User, error) {
+	user, err := s.consumeUserToken(token, models.TokenVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerifiedAt == nil {
+		now := timestamp()
+		if err := s.userRepo.MarkEmailVerified(user.ID, now); err != nil {
+			return nil, err
+		}
+		user.EmailVerifiedAt = &now
+	}
+	actor.UserID = user.ID
+	s.audit.Record(actor, models.AuditVerifyEmail, models.EntityUser, user.ID, nil, nil)
+	if err := s.claimAdmin(user, actor); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ResendVerification mails a new verification link to the user with email,
+// replacing any earlier link. Whether such an unverified user exists is not
+// revealed: the lookup and the mail happen after the call returns, so
+// neither the result nor the time it takes depends on the address.
+func (s *AuthService) ResendVerification(email string) {
+	s.inBackground("resending verification", func() error {
+		user, err := s.userRepo.GetByEmail(email)
+		if err != nil || user == nil || user.EmailVerifiedAt != nil {
+			return err
+		}
+		return s.sendUserToken(user, models.TokenVerifyEmail)
+	})
+}
+
+// ForgotPassword mails a password reset link to the user with email,
+// replacing any earlier link. As with ResendVerification, the work happens
+// after the call returns and unknown addresses are silently ignored.
+func (s *AuthService) ForgotPassword(email string, actor models.Actor) {
+	s.inBackground("requesting password reset", func() error {
+		user, err := s.userRepo.GetByEmail(email)
+		if err != nil || user == nil {
+			return err
+		}
+		if err := s.sendUserToken(user, models.TokenPasswordReset); err != nil {
+			return err
+		}
+		s.audit.Record(actor, models.AuditPasswordResetRequest, models.EntityUser, user.ID, nil, nil)
+		return nil
+	})
+}
+
+// inBackground runs fn after the caller has returned and logs its error.
+func (s *AuthService) inBackground(what string, fn func() error) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		if err := fn(); err != nil {
+			log.Printf("auth: %s: %v", what, err)
+		}
+	}()
+}
+
+// ResetPassword sets a new password for the user a reset token was mailed
+// to. The token is used up and every refresh token of the user is revoked,
+// so sessions that may have been opened with the old password end. Since
+// the user has proved they read mail sent to their address, their email
+// counts as verified, and the configured admin email may become admin.
+func (s *AuthService) ResetPassword(token, password string, actor models.Actor) error {
+	user, err := s.consumeUserToken(token, models.TokenPasswordReset)
+	if err != nil {
+		return err
+	}
+	passwordHash, err := s.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(user.ID, passwordHash); err != nil {
+		return err
+	}
+	now := timestamp()
+	if err := s.userTokenRepo.Invalidate(user.ID, models.TokenPasswordReset, now); err != nil {
+		return err
+	}
+	if err := s.refreshTokenRepo.RevokeAllForUser(user.ID); err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt == nil {
+		if err := s.userRepo.MarkEmailVerified(user.ID, now); err != nil {
+			return err
+		}
+	}
+	// The owner proved control of the account, so the backoff left by
+	// failed logins (perhaps someone else's) no longer applies.
+	s.accountFailures.Reset(strings.ToLower(strings.TrimSpace(user.Email)))
+	actor.UserID = user.ID
+	s.audit.Record(actor, models.AuditPasswordReset, models.EntityUser, user.ID, nil, nil)
+	return s.claimAdmin(user, actor)
 }
 
-// ValidateAccessToken validates an access token and returns user ID
-func (s *AuthService) ValidateAccessToken(tokenString string) (string, error) {
+// ValidateAccessToken validates an access token and returns user ID and role
+func (s *AuthService) ValidateAccessToken(tokenString string) (string, string, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")
@@ -188,20 +439,26 @@ func (s *AuthService) ValidateAccessToken(tokenString string) (string, error) {
 	})
 
 	if err != nil || !token.Valid {
-		return "", errors.New("invalid access token")
+		return "", "", apperr.Unauthorized("invalid access token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", errors.New("invalid token claims")
+		return "", "", apperr.Unauthorized("invalid token claims")
 	}
 
 	userID, ok := claims["sub"].(string)
 	if !ok {
-		return "", errors.New("invalid user ID in token")
+		return "", "", apperr.Unauthorized("invalid user ID in token")
 	}
 
-	return userID, nil
+	// Tokens issued before roles existed carry no role claim
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = models.RoleViewer
+	}
+
+	return userID, role, nil
 }
 
 // Helper functions
@@ -239,11 +496,34 @@ func (s *AuthService) verifyPassword(password, encoded string) bool {
 	return subtle(hash, testHash)
 }
 
-func (s *AuthService) generateAccessToken(userID string) (string, error) {
+// claimAdmin makes user an admin if their email is the configured admin
+// email and no admin exists yet. It is called once the user has proved they
+// read mail sent to that address, so that knowing the address is not enough
+// to take over a fresh deployment.
+func (s *AuthService) claimAdmin(user *models.User, actor models.Actor) error {
+	if s.config.AdminEmail == "" || !strings.EqualFold(user.Email, s.config.AdminEmail) || user.Role == models.RoleAdmin {
+		return nil
+	}
+	admins, err := s.userRepo.CountByRole(models.RoleAdmin)
+	if err != nil || admins > 0 {
+		return err
+	}
+	if err := s.userRepo.UpdateRole(user.ID, models.RoleAdmin); err != nil {
+		return err
+	}
+	before := *user
+	user.Role = models.RoleAdmin
+	s.audit.Record(actor, models.AuditRoleChange, models.EntityUser, user.ID, &before, user)
+	log.Printf("auth: %s verified the admin email and became admin", user.ID)
+	return nil
+}
+
+func (s *AuthService) generateAccessToken(userID, role string) (string, error) {
 	claims := jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(s.config.AccessTTL).Unix(),
-		"iat": time.Now().Unix(),
+		"sub":  userID,
+		"role": role,
+		"exp":  time.Now().Add(s.config.AccessTTL).Unix(),
+		"iat":  time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -265,6 +545,149 @@ func (s *AuthService) generateRefreshToken(userID string) (string, string, error
 	return tokenString, jti, err
 }
 
+// issueRefreshToken signs a new refresh token for familyID and returns it
+// together with the record to be stored.
+func (s *AuthService) issueRefreshToken(userID, familyID string) (string, *models.RefreshToken, error) {
+	tokenString, jti, err := s.generateRefreshToken(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	return tokenString, &models.RefreshToken{
+		JTI:       jti,
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(s.config.RefreshTTL),
+		CreatedAt: now,
+	}, nil
+}
+
+// revokeFamily invalidates every token in a family after reuse was detected.
+func (s *AuthService) revokeFamily(familyID string) error {
+	if err := s.refreshTokenRepo.RevokeFamily(familyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReuse
+}
+
+// loginFailed counts a failed login for account from actor. When that locks
+// the account or the client IP, admins are told through the audit log and
+// by mail.
+func (s *AuthService) loginFailed(account, userID string, actor models.Actor, now time.Time) {
+	until := now.Add(s.config.LoginLockout).UTC()
+	if _, locked := s.accountFailures.Fail(account, now); locked {
+		s.lockedOut("account", userID, account, actor, until)
+	}
+	if actor.IP == "" {
+		return
+	}
+	if _, locked := s.ipFailures.Fail(actor.IP, now); locked {
+		s.lockedOut("ip", "", "", actor, until)
+	}
+}
+
+// lockedOut reports that logins for an account, or from the client IP of
+// actor, are locked until until. scope is "account" or "ip".
+func (s *AuthService) lockedOut(scope, userID, email string, actor models.Actor, until time.Time) {
+	details := map[string]any{"scope": scope, "until": until}
+	if scope == "account" {
+		details["email"] = email
+		log.Printf("auth: locked out %s after repeated failed logins, last from %s", email, actor.IP)
+	} else {
+		details["ip"] = actor.IP
+		log.Printf("auth: locked out IP %s after repeated failed logins", actor.IP)
+	}
+	s.audit.Record(actor, models.AuditLockout, models.EntityUser, userID, nil, details)
+	s.mail.Notify("login_lockout", map[string]any{
+		"Scope": scope,
+		"Email": email,
+		"IP":    actor.IP,
+		"Until": until.Format("02.01.2006 15:04 MST"),
+	})
+}
+
+// sendUserToken mails user a fresh token for purpose, used up by following
+// the link in the mail. Earlier tokens for the same purpose stop working.
+// Mail beyond accountMailLimit per address is dropped.
+func (s *AuthService) sendUserToken(user *models.User, purpose string) error {
+	if !s.mail.Enabled() {
+		return nil
+	}
+	if ok, _ := s.accountMail.Allow(strings.ToLower(user.Email), time.Now()); !ok {
+		log.Printf("auth: not mailing %s to %s: too many mails", purpose, user.ID)
+		return nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	ttl, template, urlTemplate := s.config.EmailVerifyTTL, "verify_email", s.config.EmailVerifyURLTemplate
+	if purpose == models.TokenPasswordReset {
+		ttl, template, urlTemplate = s.config.PasswordResetTTL, "password_reset", s.config.PasswordResetURLTemplate
+	}
+	now := timestamp()
+	if err := s.userTokenRepo.Invalidate(user.ID, purpose, now); err != nil {
+		return err
+	}
+	record := &models.UserToken{
+		Hash:      hashUserToken(token),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := s.userTokenRepo.Create(record); err != nil {
+		return err
+	}
+	return s.mail.Send(template, map[string]any{
+		"Email":   user.Email,
+		"Link":    s.userTokenLink(urlTemplate, token),
+		"Expires": record.ExpiresAt.Format("02.01.2006 15:04 MST"),
+	}, user.Email)
+}
+
+// consumeUserToken uses up token for purpose and returns the user it was
+// issued to.
+func (s *AuthService) consumeUserToken(token, purpose string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidUserToken
+	}
+	record, err := s.userTokenRepo.Consume(hashUserToken(token), purpose, timestamp())
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrInvalidUserToken
+	}
+	user, err := s.userRepo.GetByID(record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidUserToken
+	}
+	return user, nil
+}
+
+// userTokenLink expands a link template with token. Relative templates are
+// resolved against the site URL.
+func (s *AuthService) userTokenLink(template, token string) string {
+	link := strings.ReplaceAll(template, "{token}", url.QueryEscape(token))
+	if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		return link
+	}
+	return s.config.SiteURL + "/" + strings.TrimPrefix(link, "/")
+}
+
+// hashUserToken is what is stored of a mailed token: a leaked table does
+// not let anyone verify an email or reset a password.
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func generateID() string {
 	b := make([]byte, 16)
 	rand.Read(b)
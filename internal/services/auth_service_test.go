@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/config"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+var tokenLink = regexp.MustCompile(`token=([A-Za-z0-9_%-]+)`)
+
+// mailedToken waits for mail requested in the background, delivers the
+// queued mail and returns the token in the link of the last message sent.
+func (f *authFixture) mailedToken(t *testing.T) string {
+	t.Helper()
+	f.auth.background.Wait()
+	f.mail.deliverDue(context.Background())
+	sent := f.mailer.messages()
+	if len(sent) == 0 {
+		t.Fatal("no mail was sent")
+	}
+	m := tokenLink.FindStringSubmatch(sent[len(sent)-1].Text)
+	if m == nil {
+		t.Fatalf("no token link in mail:\n%s", sent[len(sent)-1].Text)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func (f *authFixture) addUser(t *testing.T, email, password, role string) *models.User {
+	t.Helper()
+	hash, err := f.auth.hashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	u := &models.User{ID: generateID(), Email: email, PasswordHash: hash, Role: role, EmailVerifiedAt: &now, CreatedAt: now}
+	if err := f.users.Create(u); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestMailedTokensAreNotKeptAtRest(t *testing.T) {
+	f := newAuthFixture(t, nil)
+	f.addUser(t, "user@example.com", "old-password", models.RoleViewer)
+
+	f.auth.ForgotPassword("user@example.com", models.Actor{})
+	token := f.mailedToken(t)
+
+	for _, m := range f.queue.all() {
+		if m.Status != models.MailStatusSent {
+			t.Errorf("mail %s is %s, want sent", m.ID, m.Status)
+		}
+		if strings.Contains(m.Text, token) || strings.Contains(m.HTML, token) || strings.Contains(m.Subject, token) {
+			t.Errorf("queued mail %s still contains the raw token", m.ID)
+		}
+	}
+	for _, rec := range f.userTokens.tokens {
+		if strings.Contains(rec.Hash, token) {
+			t.Errorf("user token record contains the raw token")
+		}
+	}
+
+	// The token still works from the mail itself.
+	if err := f.auth.ResetPassword(token, "new-password", models.Actor{}); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+}
+
+func TestAdminEmailBecomesAdminOnlyOnceVerified(t *testing.T) {
+	f := newAuthFixture(t, func(cfg *config.Config) { cfg.AdminEmail = "owner@example.com" })
+
+	user, err := f.auth.Register("Owner@example.com", "password", models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleViewer {
+		t.Fatalf("registered role = %s, want viewer", user.Role)
+	}
+	_, access, _, err := f.auth.Login("owner@example.com", "password", models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, role, _ := f.auth.ValidateAccessToken(access); role != models.RoleViewer {
+		t.Fatalf("role in access token before verification = %s, want viewer", role)
+	}
+
+	verified, err := f.auth.VerifyEmail(f.mailedToken(t), models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Role != models.RoleAdmin {
+		t.Fatalf("role after verification = %s, want admin", verified.Role)
+	}
+
+	// Once an admin exists, the address grants nothing more.
+	other, _ := f.users.GetByID(user.ID)
+	other.ID, other.Role, other.EmailVerifiedAt = "second", models.RoleViewer, nil
+	f.users.Create(other)
+	if err := f.auth.claimAdmin(other, models.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.users.GetByID("second"); got.Role != models.RoleViewer {
+		t.Errorf("second user with the admin email became %s", got.Role)
+	}
+}
+
+func TestAdminEmailTakenByOthersIsReclaimedByPasswordReset(t *testing.T) {
+	f := newAuthFixture(t, func(cfg *config.Config) { cfg.AdminEmail = "owner@example.com" })
+
+	// Someone who knows the address registers it first, and stays a viewer.
+	if _, err := f.auth.Register("owner@example.com", "attacker", models.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	_, _, refresh, err := f.auth.Login("owner@example.com", "attacker", models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The owner resets the password from their mailbox.
+	f.auth.ForgotPassword("owner@example.com", models.Actor{})
+	if err := f.auth.ResetPassword(f.mailedToken(t), "owner-password", models.Actor{}); err != nil {
+		t.Fatal(err)
+	}
+	user, _ := f.users.GetByEmail("owner@example.com")
+	if user.Role != models.RoleAdmin {
+		t.Errorf("role after reset = %s, want admin", user.Role)
+	}
+	if _, _, err := f.auth.Refresh(refresh, models.Actor{}); err == nil {
+		t.Error("the earlier session survived the reset")
+	}
+}
+
+func TestMailRequestsAnswerBeforeLookingUpTheAddress(t *testing.T) {
+	f := newAuthFixture(t, nil)
+	f.addUser(t, "user@example.com", "password", models.RoleViewer)
+	unverified, err := f.auth.Register("new@example.com", "password", models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.auth.background.Wait()
+	f.mail.deliverDue(context.Background())
+	sent := len(f.mailer.messages())
+
+	// With the user repository stuck, the calls still return at once, so
+	// how long they take cannot depend on whether the address is known.
+	f.users.mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		f.auth.ForgotPassword("user@example.com", models.Actor{})
+		f.auth.ForgotPassword("nobody@example.com", models.Actor{})
+		f.auth.ResendVerification(unverified.Email)
+		f.auth.ResendVerification("nobody@example.com")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("mail requests waited for the user lookup")
+	}
+	f.users.mu.Unlock()
+
+	f.auth.background.Wait()
+	f.mail.deliverDue(context.Background())
+	var to []string
+	for _, m := range f.mailer.messages()[sent:] {
+		to = append(to, m.To...)
+	}
+	slices.Sort(to)
+	if want := []string{"new@example.com", "user@example.com"}; !slices.Equal(to, want) {
+		t.Errorf("mailed %v, want %v", to, want)
+	}
+}
+
+func TestRefreshTokenReuseRevokesTheFamily(t *testing.T) {
+	f := newAuthFixture(t, nil)
+	f.addUser(t, "user@example.com", "password", models.RoleViewer)
+	_, _, first, err := f.auth.Login("user@example.com", "password", models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, other, err := f.auth.Login("user@example.com", "password", models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, second, err := f.auth.Refresh(first, models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, third, err := f.auth.Refresh(second, models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone replays a token that was already rotated.
+	if _, _, err := f.auth.Refresh(first, models.Actor{}); err != ErrRefreshTokenReuse {
+		t.Fatalf("replaying a rotated token: error = %v, want reuse", err)
+	}
+	if _, _, err := f.auth.Refresh(third, models.Actor{}); err == nil {
+		t.Error("the newest token of the family survived the reuse")
+	}
+
+	// Sessions of other logins are not affected.
+	if _, _, err := f.auth.Refresh(other, models.Actor{}); err != nil {
+		t.Errorf("token of another login: %v", err)
+	}
+}
+
+// staleRefreshTokens answers lookups as if no token had been revoked, as a
+// request does that read the token just before another one rotated it.
+type staleRefreshTokens struct {
+	*fakeRefreshTokens
+}
+
+func (r staleRefreshTokens) GetByJTI(jti string) (*models.RefreshToken, error) {
+	t, err := r.fakeRefreshTokens.GetByJTI(jti)
+	if t != nil {
+		t.RevokedAt, t.ReplacedBy = nil, nil
+	}
+	return t, err
+}
+
+func TestRefreshTokenRotationRaceRevokesTheFamily(t *testing.T) {
+	f := newAuthFixture(t, nil)
+	f.addUser(t, "user@example.com", "password", models.RoleViewer)
+	_, _, first, err := f.auth.Login("user@example.com", "password", models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := f.auth.Refresh(first, models.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second use of first gets past the lookup and loses in Rotate.
+	f.auth.refreshTokenRepo = staleRefreshTokens{f.refreshTokens}
+	if _, _, err := f.auth.Refresh(first, models.Actor{}); err != ErrRefreshTokenReuse {
+		t.Fatalf("error = %v, want reuse", err)
+	}
+
+	f.auth.refreshTokenRepo = f.refreshTokens
+	if _, _, err := f.auth.Refresh(second, models.Actor{}); err == nil {
+		t.Error("the token issued by the first rotation survived the race")
+	}
+	for jti, tok := range f.refreshTokens.tokens {
+		if tok.RevokedAt == nil {
+			t.Errorf("token %s of the family is still valid", jti)
+		}
+	}
+}
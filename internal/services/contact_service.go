@@ -1,39 +1,179 @@
 package services
 
-import "github.com/ScriptVandal/backend-go/internal/models"
+import (
+    "time"
+
+    "github.com/ScriptVandal/backend-go/internal/apperr"
+    "github.com/ScriptVandal/backend-go/internal/models"
+    "github.com/ScriptVandal/backend-go/internal/validate"
+)
 
 type ContactRepo interface {
     List() ([]models.Contact, error)
+    ListPage(q models.ListQuery) (*models.Page[models.Contact], error)
     GetByID(id string) (*models.Contact, error)
     Create(contact *models.Contact) error
     Update(contact *models.Contact) error
-    Delete(id string) error
+    Modify(id string, fn func(*models.Contact) error) (*models.Contact, error)
+    Delete(id string, version int) error
+    Restore(id string) (*models.Contact, error)
+    ListDeleted() ([]models.Contact, error)
+    Purge(before time.Time) (int, error)
 }
 
 type ContactService struct {
-    repo ContactRepo
+    repo           ContactRepo
+    audit          *AuditService
+    allowClientIDs bool
 }
 
-func NewContactService(repo ContactRepo) *ContactService {
-    return &ContactService{repo: repo}
+func NewContactService(repo ContactRepo, audit *AuditService, allowClientIDs bool) *ContactService {
+    return &ContactService{repo: repo, audit: audit, allowClientIDs: allowClientIDs}
 }
 
-func (s *ContactService) ListContacts() ([]models.Contact, error) {
-    return s.repo.List()
+func (s *ContactService) ListContacts(q models.ListQuery) (*models.Page[models.Contact], error) {
+    return s.repo.ListPage(q)
 }
 
 func (s *ContactService) GetContact(id string) (*models.Contact, error) {
     return s.repo.GetByID(id)
 }
 
-func (s *ContactService) CreateContact(contact *models.Contact) error {
-    return s.repo.Create(contact)
+// CreateContact stores a new contact on behalf of actor.
+func (s *ContactService) CreateContact(contact *models.Contact, actor models.Actor) error {
+    if err := validate.Struct(contact); err != nil {
+        return err
+    }
+    if err := assignID(&contact.ID, s.allowClientIDs); err != nil {
+        return err
+    }
+    contact.CreatedAt = timestamp()
+    contact.UpdatedAt = contact.CreatedAt
+    contact.Version = 1
+    contact.DeletedAt = nil
+    if err := s.repo.Create(contact); err != nil {
+        return err
+    }
+    s.audit.Record(actor, models.AuditCreate, models.EntityContact, contact.ID, nil, contact)
+    return nil
+}
+
+// UpdateContact replaces the stored contact on behalf of actor if it is at a
+// version ifMatch allows.
+func (s *ContactService) UpdateContact(contact *models.Contact, ifMatch models.IfMatch, actor models.Actor) error {
+    return s.update(contact, ifMatch, actor, models.AuditUpdate)
+}
+
+// update saves contact over the stored contact, recording the change as action.
+func (s *ContactService) update(contact *models.Contact, ifMatch models.IfMatch, actor models.Actor, action string) error {
+    current, err := s.repo.GetByID(contact.ID)
+    if err != nil {
+        return err
+    }
+    if current == nil {
+        return apperr.NotFound("contact not found")
+    }
+    if !ifMatch.Allows(current.Version) {
+        return ErrVersionMismatch
+    }
+    if err := s.prepareUpdate(contact, current); err != nil {
+        return err
+    }
+    if err := s.repo.Update(contact); err != nil {
+        return err
+    }
+    s.audit.Record(actor, action, models.EntityContact, contact.ID, current, contact)
+    return nil
+}
+
+// PatchContact applies patch to the stored contact and saves the result in one
+// transaction. The patched contact is checked like an update.
+func (s *ContactService) PatchContact(id string, ifMatch models.IfMatch, actor models.Actor, patch func(*models.Contact) error) (*models.Contact, error) {
+    var before models.Contact
+    contact, err := s.repo.Modify(id, func(contact *models.Contact) error {
+        before = *contact
+        if !ifMatch.Allows(before.Version) {
+            return ErrVersionMismatch
+        }
+        if err := patch(contact); err != nil {
+            return err
+        }
+        contact.ID = id
+        return s.prepareUpdate(contact, &before)
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.audit.Record(actor, models.AuditUpdate, models.EntityContact, contact.ID, &before, contact)
+    return contact, nil
+}
+
+// prepareUpdate validates contact and fills in what the server derives,
+// given the contact as currently stored.
+func (s *ContactService) prepareUpdate(contact, current *models.Contact) error {
+    if err := validate.Struct(contact); err != nil {
+        return err
+    }
+    contact.UpdatedAt = timestamp()
+    contact.CreatedAt = current.CreatedAt
+    contact.Version = current.Version
+    contact.DeletedAt = current.DeletedAt
+    return nil
+}
+
+// DeleteContact moves the contact to the trash on behalf of actor if it is at a
+// version ifMatch allows.
+func (s *ContactService) DeleteContact(id string, ifMatch models.IfMatch, actor models.Actor) error {
+    current, err := s.repo.GetByID(id)
+    if err != nil {
+        return err
+    }
+    if current == nil {
+        return apperr.NotFound("contact not found")
+    }
+    if !ifMatch.Allows(current.Version) {
+        return ErrVersionMismatch
+    }
+    if err := s.repo.Delete(id, current.Version); err != nil {
+        return err
+    }
+    s.audit.Record(actor, models.AuditDelete, models.EntityContact, id, current, nil)
+    return nil
+}
+
+// RestoreContact takes the contact out of the trash on behalf of actor.
+func (s *ContactService) RestoreContact(id string, actor models.Actor) (*models.Contact, error) {
+    contact, err := s.repo.Restore(id)
+    if err != nil {
+        return nil, err
+    }
+    s.audit.Record(actor, models.AuditRestore, models.EntityContact, id, nil, contact)
+    return contact, nil
+}
+
+// ListTrash lists the contacts in the trash.
+func (s *ContactService) ListTrash() ([]models.TrashItem, error) {
+    items, err := s.repo.ListDeleted()
+    if err != nil {
+        return nil, err
+    }
+    trash := make([]models.TrashItem, 0, len(items))
+    for _, item := range items {
+        trash = append(trash, models.TrashItem{Type: models.EntityContact, ID: item.ID, Title: contactTitle(&item), DeletedAt: *item.DeletedAt})
+    }
+    return trash, nil
 }
 
-func (s *ContactService) UpdateContact(contact *models.Contact) error {
-    return s.repo.Update(contact)
+// PurgeTrash permanently deletes contacts moved to the trash before before.
+func (s *ContactService) PurgeTrash(before time.Time) (int, error) {
+    return s.repo.Purge(before)
 }
 
-func (s *ContactService) DeleteContact(id string) error {
-    return s.repo.Delete(id)
+// contactTitle names a contact in the trash by its email, or its Telegram
+// handle if it has none.
+func contactTitle(contact *models.Contact) string {
+    if contact.Email != "" {
+        return contact.Email
+    }
+    return contact.Telegram
 }
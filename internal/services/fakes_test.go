@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/config"
+	"github.com/ScriptVandal/backend-go/internal/mail"
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/repositories"
+)
+
+// In-memory stand-ins for the repositories the auth and mail services use,
+// following the semantics of the PostgreSQL implementations.
+
+type fakeUsers struct {
+	mu    sync.Mutex
+	users map[string]*models.User
+}
+
+func (r *fakeUsers) Create(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u := *user
+	r.users[u.ID] = &u
+	return nil
+}
+
+func (r *fakeUsers) GetByEmail(email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, email) {
+			c := *u
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) GetByID(id string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok {
+		c := *u
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) UpdateRole(id, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok {
+		u.Role = role
+		return nil
+	}
+	return repositories.ErrNotFound
+}
+
+func (r *fakeUsers) CountByRole(role string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, u := range r.users {
+		if u.Role == role {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *fakeUsers) MarkEmailVerified(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok {
+		u.EmailVerifiedAt = &at
+	}
+	return nil
+}
+
+func (r *fakeUsers) UpdatePassword(id, passwordHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok {
+		u.PasswordHash = passwordHash
+	}
+	return nil
+}
+
+type fakeUserTokens struct {
+	mu     sync.Mutex
+	tokens []models.UserToken
+}
+
+func (r *fakeUserTokens) Create(token *models.UserToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+func (r *fakeUserTokens) Consume(hash, purpose string, now time.Time) (*models.UserToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tokens {
+		t := &r.tokens[i]
+		if t.Hash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = &now
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUserTokens) Invalidate(userID, purpose string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.tokens {
+		if t := &r.tokens[i]; t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeRefreshTokens struct {
+	mu     sync.Mutex
+	tokens map[string]*models.RefreshToken
+}
+
+func (r *fakeRefreshTokens) Create(token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := *token
+	r.tokens[t.JTI] = &t
+	return nil
+}
+
+func (r *fakeRefreshTokens) GetByJTI(jti string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tokens[jti]; ok {
+		c := *t
+		return &c, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeRefreshTokens) Revoke(jti string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tokens[jti]; ok && t.RevokedAt == nil {
+		now := time.Now()
+		t.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *fakeRefreshTokens) Rotate(oldJTI string, next *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.tokens[oldJTI]
+	if !ok || old.RevokedAt != nil {
+		return repositories.ErrTokenAlreadyRotated
+	}
+	now := time.Now()
+	old.RevokedAt, old.ReplacedBy = &now, &next.JTI
+	t := *next
+	r.tokens[t.JTI] = &t
+	return nil
+}
+
+func (r *fakeRefreshTokens) RevokeFamily(familyID string) error {
+	return r.revokeWhere(func(t *models.RefreshToken) bool { return t.FamilyID == familyID })
+}
+
+func (r *fakeRefreshTokens) RevokeAllForUser(userID string) error {
+	return r.revokeWhere(func(t *models.RefreshToken) bool { return t.UserID == userID })
+}
+
+func (r *fakeRefreshTokens) revokeWhere(match func(*models.RefreshToken) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, t := range r.tokens {
+		if match(t) && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeRefreshTokens) DeleteExpired() error { return nil }
+
+type fakeAudit struct {
+	mu      sync.Mutex
+	entries []models.AuditEntry
+}
+
+func (r *fakeAudit) Append(entry *models.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+func (r *fakeAudit) ListPage(q models.ListQuery) (*models.Page[models.AuditEntry], error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &models.Page[models.AuditEntry]{Items: append([]models.AuditEntry(nil), r.entries...)}, nil
+}
+
+type fakeMailQueue struct {
+	mu    sync.Mutex
+	mails []models.OutgoingMail
+}
+
+func (r *fakeMailQueue) Enqueue(m *models.OutgoingMail) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mails = append(r.mails, *m)
+	return nil
+}
+
+func (r *fakeMailQueue) Claim(now time.Time, lease time.Duration, limit int) ([]models.OutgoingMail, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var claimed []models.OutgoingMail
+	for i := range r.mails {
+		if len(claimed) == limit {
+			break
+		}
+		if m := &r.mails[i]; m.Status == models.MailStatusPending && !m.NextAttemptAt.After(now) {
+			m.NextAttemptAt = now.Add(lease)
+			claimed = append(claimed, *m)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *fakeMailQueue) Save(m *models.OutgoingMail) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.mails {
+		if r.mails[i].ID == m.ID {
+			r.mails[i] = *m
+			return nil
+		}
+	}
+	return repositories.ErrNotFound
+}
+
+func (r *fakeMailQueue) Prune(before time.Time) (int, error) { return 0, nil }
+
+// all returns a copy of the queue.
+func (r *fakeMailQueue) all() []models.OutgoingMail {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.OutgoingMail(nil), r.mails...)
+}
+
+// fakeMailer records the messages it is given, failing with err if set.
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg *mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, *msg)
+	return nil
+}
+
+func (m *fakeMailer) messages() []mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mail.Message(nil), m.sent...)
+}
+
+// authFixture is an AuthService over fake repositories.
+type authFixture struct {
+	auth          *AuthService
+	users         *fakeUsers
+	userTokens    *fakeUserTokens
+	refreshTokens *fakeRefreshTokens
+	queue         *fakeMailQueue
+	mailer        *fakeMailer
+	mail          *MailService
+}
+
+func newAuthFixture(t *testing.T, configure func(*config.Config)) *authFixture {
+	t.Helper()
+	cfg := &config.Config{
+		JWTSecret:                "access-secret",
+		JWTRefreshSecret:         "refresh-secret",
+		AccessTTL:                15 * time.Minute,
+		RefreshTTL:               time.Hour,
+		SiteURL:                  "https://example.com",
+		EmailVerifyTTL:           48 * time.Hour,
+		PasswordResetTTL:         time.Hour,
+		EmailVerifyURLTemplate:   "/verify-email?token={token}",
+		PasswordResetURLTemplate: "/reset-password?token={token}",
+		LoginMaxFailures:         10,
+		LoginIPMaxFailures:       50,
+		LoginLockout:             15 * time.Minute,
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	f := &authFixture{
+		users:         &fakeUsers{users: map[string]*models.User{}},
+		userTokens:    &fakeUserTokens{},
+		refreshTokens: &fakeRefreshTokens{tokens: map[string]*models.RefreshToken{}},
+		queue:         &fakeMailQueue{},
+		mailer:        &fakeMailer{},
+	}
+	f.mail = NewMailService(f.queue, f.mailer, "Blog", cfg.SiteURL, nil, 3)
+	f.auth = NewAuthService(f.users, f.refreshTokens, f.userTokens, NewAuditService(&fakeAudit{}), f.mail, cfg)
+	return f
+}
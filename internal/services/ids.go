@@ -0,0 +1,29 @@
+package services
+
+import (
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/uuid"
+)
+
+var ErrClientID = apperr.Validation(apperr.Field("id", "is assigned by the server"))
+
+// assignID gives a new item a server-generated, time-sortable ID. A
+// client-supplied ID is kept only when allowClient is set, e.g. for imports.
+func assignID(id *string, allowClient bool) error {
+	if *id != "" {
+		if !allowClient {
+			return ErrClientID
+		}
+		return nil
+	}
+	*id = uuid.NewV7()
+	return nil
+}
+
+// timestamp returns the time recorded in created_at and updated_at,
+// truncated to microseconds, the resolution Postgres stores.
+func timestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/i18n"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+// checkLocales validates the locale fields of an item being saved. Stored
+// items are in the default locale, so a localized copy of another locale
+// cannot be saved back, and translations must be into the other supported
+// locales.
+func checkLocales[T any](locales i18n.Locales, locale string, translations map[string]T) error {
+	var fields []apperr.FieldError
+	if locale != "" && locale != locales.Default {
+		fields = append(fields, apperr.Field("locale", "must be "+locales.Default+"; edit other locales through translations"))
+	}
+	keys := make([]string, 0, len(translations))
+	for key := range translations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !locales.Translatable(key) {
+			fields = append(fields, apperr.Field("translations."+key, "is not a supported locale other than "+locales.Default))
+		}
+	}
+	if len(fields) > 0 {
+		return apperr.Validation(fields...)
+	}
+	return nil
+}
+
+// pickTranslation negotiates the locale of an item with translations for
+// prefs. ok is false if the item is to be shown in the default locale.
+func pickTranslation[T any](locales i18n.Locales, prefs []string, translations map[string]T) (locale string, t T, ok bool) {
+	locale = locales.Pick(prefs, func(l string) bool {
+		_, has := translations[l]
+		return has
+	})
+	t, ok = translations[locale]
+	return locale, t, ok && locale != locales.Default
+}
+
+// localeSet lists the locales an item with translations can be read in: the
+// default and every supported locale it is translated into, in the
+// configured order.
+func localeSet[T any](locales i18n.Locales, translations map[string]T) *models.LocaleSet {
+	set := &models.LocaleSet{Default: locales.Default, Locales: []string{}}
+	for _, locale := range locales.Supported {
+		if _, ok := translations[locale]; ok || locale == locales.Default {
+			set.Locales = append(set.Locales, locale)
+		}
+	}
+	return set
+}
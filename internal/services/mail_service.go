@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/mail"
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/uuid"
+)
+
+const (
+	// mailBatch is how many mails the worker sends per round.
+	mailBatch = 20
+	// mailLease is how long a claimed mail is held by the worker sending it.
+	mailLease = 5 * time.Minute
+	// mailRetry is the delay before the first retry; it doubles with every
+	// further attempt up to mailMaxRetry.
+	mailRetry    = time.Minute
+	mailMaxRetry = 6 * time.Hour
+	// mailRetention is how long sent and failed mail stays in the queue.
+	mailRetention = 30 * 24 * time.Hour
+)
+
+type MailQueueRepo interface {
+	Enqueue(m *models.OutgoingMail) error
+	Claim(now time.Time, lease time.Duration, limit int) ([]models.OutgoingMail, error)
+	Save(m *models.OutgoingMail) error
+	Prune(before time.Time) (int, error)
+}
+
+// MailService renders emails from the mail templates and delivers them
+// through a persistent queue, so that a mail server being down delays mail
+// rather than losing it.
+type MailService struct {
+	repo   MailQueueRepo
+	mailer mail.Mailer
+	// siteTitle and siteURL are passed to every template as SiteTitle and
+	// SiteURL.
+	siteTitle, siteURL string
+	// notifyTo receives notifications meant for the site owners.
+	notifyTo    []string
+	maxAttempts int
+	wake        chan struct{}
+}
+
+// NewMailService sends mail through mailer. With a nil mailer, mail is
+// turned off and nothing is queued.
+func NewMailService(repo MailQueueRepo, mailer mail.Mailer, siteTitle, siteURL string, notifyTo []string, maxAttempts int) *MailService {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &MailService{
+		repo:        repo,
+		mailer:      mailer,
+		siteTitle:   siteTitle,
+		siteURL:     siteURL,
+		notifyTo:    notifyTo,
+		maxAttempts: maxAttempts,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enabled reports whether mail is sent at all.
+func (s *MailService) Enabled() bool {
+	return s.mailer != nil
+}
+
+// Send renders the template with data and queues the mail for to.
+func (s *MailService) Send(template string, data map[string]any, to ...string) error {
+	if !s.Enabled() || len(to) == 0 {
+		return nil
+	}
+	fields := map[string]any{"SiteTitle": s.siteTitle, "SiteURL": s.siteURL}
+	for k, v := range data {
+		fields[k] = v
+	}
+	msg, err := mail.Render(template, fields, to...)
+	if err != nil {
+		return err
+	}
+	now := timestamp()
+	m := &models.OutgoingMail{
+		ID:            uuid.NewV7(),
+		To:            msg.To,
+		Subject:       msg.Subject,
+		Text:          msg.Text,
+		HTML:          msg.HTML,
+		Status:        models.MailStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := s.repo.Enqueue(m); err != nil {
+		return err
+	}
+	// Wake the worker so that mail goes out without waiting for its tick.
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Notify queues a notification for the site owners. It is sent on behalf of
+// a change that has already happened, so a failure is logged rather than
+// returned.
+func (s *MailService) Notify(template string, data map[string]any) {
+	if err := s.Send(template, data, s.notifyTo...); err != nil {
+		log.Printf("mail: queueing %s notification: %v", template, err)
+	}
+}
+
+// RunWorker delivers queued mail every interval, and as soon as mail is
+// queued, until ctx is cancelled. A failed delivery is retried with
+// exponential backoff until maxAttempts is reached. Once a mail is sent or
+// has failed, its bodies are dropped from the queue: verification and reset
+// mails carry live tokens, which must not stay readable at rest.
+func (s *MailService) RunWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.deliverDue(ctx)
+		if n, err := s.repo.Prune(time.Now().Add(-mailRetention)); err != nil {
+			log.Printf("mail: pruning queue: %v", err)
+		} else if n > 0 {
+			log.Printf("mail: pruned %d old mail(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// deliverDue sends the mail that is due, batch by batch.
+func (s *MailService) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		mails, err := s.repo.Claim(time.Now(), mailLease, mailBatch)
+		if err != nil {
+			log.Printf("mail: claiming queued mail: %v", err)
+			return
+		}
+		for i := range mails {
+			s.deliver(ctx, &mails[i])
+		}
+		if len(mails) < mailBatch {
+			return
+		}
+	}
+}
+
+func (s *MailService) deliver(ctx context.Context, m *models.OutgoingMail) {
+	m.Attempts++
+	err := s.mailer.Send(ctx, &mail.Message{To: m.To, Subject: m.Subject, Text: m.Text, HTML: m.HTML})
+	now := timestamp()
+	switch {
+	case err == nil:
+		m.Status, m.SentAt, m.LastError = models.MailStatusSent, &now, ""
+		m.Text, m.HTML = "", ""
+	case m.Attempts >= s.maxAttempts:
+		m.Status, m.LastError = models.MailStatusFailed, err.Error()
+		m.Text, m.HTML = "", ""
+		log.Printf("mail: giving up on %s to %v after %d attempt(s): %v", m.ID, m.To, m.Attempts, err)
+	default:
+		m.NextAttemptAt, m.LastError = now.Add(mailBackoff(m.Attempts)), err.Error()
+		log.Printf("mail: sending %s to %v (attempt %d): %v; retrying at %s", m.ID, m.To, m.Attempts, err, m.NextAttemptAt.Format(time.RFC3339))
+	}
+	if err := s.repo.Save(m); err != nil {
+		log.Printf("mail: saving %s: %v", m.ID, err)
+	}
+}
+
+// mailBackoff is the delay after the given number of failed attempts.
+func mailBackoff(attempts int) time.Duration {
+	d := mailRetry
+	for i := 1; i < attempts && d < mailMaxRetry; i++ {
+		d *= 2
+	}
+	return min(d, mailMaxRetry)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+func TestFailedMailIsRetriedWithBackoffUntilItFails(t *testing.T) {
+	queue, mailer := &fakeMailQueue{}, &fakeMailer{err: errors.New("451 try again later")}
+	svc := NewMailService(queue, mailer, "Blog", "https://example.com", nil, 3)
+
+	data := map[string]any{"Email": "user@example.com", "Link": "https://example.com/verify?token=secret", "Expires": "soon"}
+	if err := svc.Send("verify_email", data, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		svc.deliverDue(context.Background())
+		m := queue.all()[0]
+		if m.Attempts != attempt {
+			t.Fatalf("attempts = %d, want %d", m.Attempts, attempt)
+		}
+		if m.LastError != "451 try again later" {
+			t.Errorf("attempt %d: last error = %q", attempt, m.LastError)
+		}
+		if attempt == 3 {
+			if m.Status != models.MailStatusFailed {
+				t.Errorf("status after the last attempt = %s, want failed", m.Status)
+			}
+			if m.Text != "" || m.HTML != "" {
+				t.Error("failed mail still has its body")
+			}
+			break
+		}
+
+		if m.Status != models.MailStatusPending {
+			t.Fatalf("attempt %d: status = %s, want pending", attempt, m.Status)
+		}
+		wait := mailRetry << (attempt - 1)
+		if d := m.NextAttemptAt.Sub(before); d < wait-time.Second || d > wait+time.Second {
+			t.Errorf("attempt %d: retry in %v, want %v", attempt, d, wait)
+		}
+
+		// Nothing is sent again before the retry is due.
+		svc.deliverDue(context.Background())
+		if got := queue.all()[0].Attempts; got != attempt {
+			t.Fatalf("mail was retried early: attempts = %d", got)
+		}
+		queue.mu.Lock()
+		queue.mails[0].NextAttemptAt = time.Now().Add(-time.Second)
+		queue.mu.Unlock()
+	}
+
+	// A failed mail is never picked up again.
+	mailer.mu.Lock()
+	mailer.err = nil
+	mailer.mu.Unlock()
+	svc.deliverDue(context.Background())
+	if len(mailer.messages()) != 0 || queue.all()[0].Attempts != 3 {
+		t.Error("failed mail was sent after all attempts were used")
+	}
+}
+
+func TestMailBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := mailBackoff(tt.attempts); got != tt.want {
+			t.Errorf("mailBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
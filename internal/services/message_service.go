@@ -0,0 +1,258 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/uuid"
+	"github.com/ScriptVandal/backend-go/internal/validate"
+)
+
+// maxFormAge is how long a contact form stays valid after it is served.
+const maxFormAge = 24 * time.Hour
+
+var (
+	ErrFormToken            = apperr.Validation(apperr.Field("form_token", "is missing, invalid, expired or already used; reload the form"))
+	ErrInvalidMessageStatus = apperr.Validation(apperr.Field("status", "must be one of new, read, archived"))
+)
+
+type MessageRepo interface {
+	Create(m *models.Message) error
+	GetByID(id string) (*models.Message, error)
+	ListPage(q models.ListQuery) (*models.Page[models.Message], error)
+	SetStatus(id, status string, at time.Time) (*models.Message, error)
+	Delete(id string) error
+}
+
+// MessageService takes contact-form messages from visitors and lets editors
+// work through them.
+type MessageService struct {
+	repo  MessageRepo
+	audit *AuditService
+	mail  *MailService
+	forms *formTokens
+	// minDelay is how long a form must be open before it is sent; faster
+	// submissions are taken for bots.
+	minDelay time.Duration
+}
+
+// NewMessageService signs form tokens with formSecret. Without one a
+// random key is used, and forms served before a restart stop working.
+func NewMessageService(repo MessageRepo, audit *AuditService, mail *MailService, formSecret string, minDelay time.Duration) *MessageService {
+	key := []byte(formSecret)
+	if formSecret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &MessageService{repo: repo, audit: audit, mail: mail, forms: newFormTokens(key), minDelay: minDelay}
+}
+
+// Form returns a fresh token for the contact form.
+func (s *MessageService) Form() models.MessageForm {
+	return models.MessageForm{
+		Token:           s.forms.issue(time.Now()),
+		MinDelaySeconds: int(s.minDelay.Round(time.Second) / time.Second),
+	}
+}
+
+// Submit stores a contact-form submission from actor and notifies the site
+// owners by mail. Submissions that look automated, with the honeypot filled
+// in or sent sooner than minDelay after the form was served, are dropped
+// without an error so that bots cannot tell; Submit then returns a nil
+// message. Each form token is accepted once.
+func (s *MessageService) Submit(sub *models.MessageSubmission, actor models.Actor) (*models.Message, error) {
+	now := time.Now()
+	if sub.Website != "" {
+		log.Printf("messages: dropped submission from %s: honeypot filled in", actor.IP)
+		return nil, nil
+	}
+	served, ok := s.forms.check(sub.FormToken, now)
+	if !ok {
+		return nil, ErrFormToken
+	}
+	if now.Sub(served) < s.minDelay {
+		// Spend the token, or the bot could simply retry with it later.
+		s.forms.consume(sub.FormToken, served, now)
+		log.Printf("messages: dropped submission from %s: sent %s after the form was served", actor.IP, now.Sub(served).Round(time.Millisecond))
+		return nil, nil
+	}
+	if err := validate.Struct(sub); err != nil {
+		return nil, err
+	}
+	// The token is spent only now, so that a visitor can fix invalid fields
+	// and send the same form again.
+	if !s.forms.consume(sub.FormToken, served, now) {
+		return nil, ErrFormToken
+	}
+
+	m := &models.Message{
+		ID:        uuid.NewV7(),
+		Name:      strings.TrimSpace(sub.Name),
+		Email:     sub.Email,
+		Subject:   strings.TrimSpace(sub.Subject),
+		Body:      strings.TrimSpace(sub.Body),
+		Status:    models.MessageStatusNew,
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		CreatedAt: timestamp(),
+	}
+	m.UserAgent = truncate(m.UserAgent, maxUserAgentLen)
+	m.UpdatedAt = m.CreatedAt
+	if err := s.repo.Create(m); err != nil {
+		return nil, err
+	}
+	s.mail.Notify("new_message", map[string]any{
+		"Name":    m.Name,
+		"Email":   m.Email,
+		"Subject": m.Subject,
+		"Body":    m.Body,
+	})
+	return m, nil
+}
+
+// ListMessages returns a page of messages, optionally filtered by status
+// and email.
+func (s *MessageService) ListMessages(q models.ListQuery) (*models.Page[models.Message], error) {
+	if status, ok := q.Filters["status"]; ok && !validMessageStatus(status) {
+		return nil, ErrInvalidMessageStatus
+	}
+	return s.repo.ListPage(q)
+}
+
+// GetMessage returns a message, or nil if it does not exist.
+func (s *MessageService) GetMessage(id string) (*models.Message, error) {
+	return s.repo.GetByID(id)
+}
+
+// SetMessageStatus marks a message new, read or archived on behalf of actor.
+func (s *MessageService) SetMessageStatus(id, status string, actor models.Actor) (*models.Message, error) {
+	if !validMessageStatus(status) {
+		return nil, ErrInvalidMessageStatus
+	}
+	current, err := s.requireMessage(id)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.repo.SetStatus(id, status, timestamp())
+	if err != nil {
+		return nil, err
+	}
+	s.audit.Record(actor, models.AuditUpdate, models.EntityMessage, id, current, m)
+	return m, nil
+}
+
+// DeleteMessage deletes a message for good on behalf of actor.
+func (s *MessageService) DeleteMessage(id string, actor models.Actor) error {
+	current, err := s.requireMessage(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.audit.Record(actor, models.AuditDelete, models.EntityMessage, id, current, nil)
+	return nil
+}
+
+func (s *MessageService) requireMessage(id string) (*models.Message, error) {
+	m, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, apperr.NotFound("message not found")
+	}
+	return m, nil
+}
+
+func validMessageStatus(status string) bool {
+	switch status {
+	case models.MessageStatusNew, models.MessageStatusRead, models.MessageStatusArchived:
+		return true
+	}
+	return false
+}
+
+// formTokens issues and checks contact-form tokens, which record when the
+// form was served. A token is "<unix milliseconds>.<nonce>.<HMAC-SHA256
+// signature>", and is remembered once consumed until it expires, so that it
+// cannot be replayed. Consumed tokens are kept in memory: a restart forgets
+// them, and each instance keeps its own.
+type formTokens struct {
+	key []byte
+
+	mu        sync.Mutex
+	used      map[string]time.Time
+	lastSweep time.Time
+}
+
+func newFormTokens(key []byte) *formTokens {
+	return &formTokens{key: key, used: map[string]time.Time{}}
+}
+
+func (f *formTokens) issue(now time.Time) string {
+	nonce := make([]byte, 9)
+	rand.Read(nonce)
+	payload := strconv.FormatInt(now.UnixMilli(), 10) + "." + base64.RawURLEncoding.EncodeToString(nonce)
+	return payload + "." + f.sign(payload)
+}
+
+// check returns when the form of token was served, if the token is
+// genuine and not older than maxFormAge. It does not consume the token.
+func (f *formTokens) check(token string, now time.Time) (time.Time, bool) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(f.sign(token[:i]))) {
+		return time.Time{}, false
+	}
+	ts, _, ok := strings.Cut(token[:i], ".")
+	if !ok {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	served := time.UnixMilli(ms)
+	if served.After(now) || now.Sub(served) > maxFormAge {
+		return time.Time{}, false
+	}
+	return served, true
+}
+
+// consume marks a token served at served as used. It reports false if the
+// token has been used before.
+func (f *formTokens) consume(token string, served, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Tokens older than maxFormAge fail check anyway, so they can be
+	// forgotten; sweep at most once an hour.
+	if now.Sub(f.lastSweep) >= time.Hour {
+		for t, at := range f.used {
+			if now.Sub(at) > maxFormAge {
+				delete(f.used, t)
+			}
+		}
+		f.lastSweep = now
+	}
+	if _, ok := f.used[token]; ok {
+		return false
+	}
+	f.used[token] = served
+	return true
+}
+
+func (f *formTokens) sign(ts string) string {
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte(ts))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
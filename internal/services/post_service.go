@@ -1,39 +1,465 @@
 package services
 
-import "github.com/ScriptVandal/backend-go/internal/models"
+import (
+    "context"
+    "log"
+    "time"
+
+    "github.com/ScriptVandal/backend-go/internal/apperr"
+    "github.com/ScriptVandal/backend-go/internal/i18n"
+    "github.com/ScriptVandal/backend-go/internal/markdown"
+    "github.com/ScriptVandal/backend-go/internal/models"
+    "github.com/ScriptVandal/backend-go/internal/validate"
+)
+
+var (
+    ErrInvalidPostStatus  = apperr.Validation(apperr.Field("status", "must be one of draft, scheduled, published, archived"))
+    ErrScheduledWithoutAt = apperr.Validation(apperr.Field("published_at", "is required for scheduled posts"))
+)
 
 type PostRepo interface {
     List() ([]models.Post, error)
+    ListPage(q models.ListQuery) (*models.Page[models.Post], error)
     GetByID(id string) (*models.Post, error)
+    GetBySlug(slug string) (*models.Post, error)
+    SlugTaken(slug, exceptID string) (bool, error)
     Create(post *models.Post) error
     Update(post *models.Post) error
-    Delete(id string) error
+    Modify(id string, fn func(*models.Post) error) (*models.Post, error)
+    Delete(id string, version int) error
+    Restore(id string) (*models.Post, error)
+    ListDeleted() ([]models.Post, error)
+    Purge(before time.Time) (int, error)
+    PublishDue(now time.Time) (int, error)
 }
 
 type PostService struct {
-    repo PostRepo
+    repo           PostRepo
+    redirects      SlugRedirectRepo
+    history        history
+    audit          *AuditService
+    locales        i18n.Locales
+    allowClientIDs bool
+}
+
+func NewPostService(repo PostRepo, redirects SlugRedirectRepo, revisions RevisionRepo, audit *AuditService, locales i18n.Locales, allowClientIDs bool) *PostService {
+    return &PostService{
+        repo:           repo,
+        redirects:      redirects,
+        history:        history{repo: revisions, entityType: models.EntityPost},
+        audit:          audit,
+        locales:        locales,
+        allowClientIDs: allowClientIDs,
+    }
+}
+
+// ListPosts returns a page of posts. Set q.PublishedOnly for public callers.
+func (s *PostService) ListPosts(q models.ListQuery) (*models.Page[models.Post], error) {
+    page, err := s.repo.ListPage(q)
+    if err != nil {
+        return nil, err
+    }
+    for i := range page.Items {
+        ensureRendered(&page.Items[i])
+    }
+    return page, nil
+}
+
+// GetPost returns a post, or nil if it does not exist or is not visible and
+// includeUnpublished is false.
+func (s *PostService) GetPost(id string, includeUnpublished bool) (*models.Post, error) {
+    post, err := s.repo.GetByID(id)
+    if err != nil || post == nil {
+        return post, err
+    }
+    if !includeUnpublished && !post.IsVisible(time.Now()) {
+        return nil, nil
+    }
+    ensureRendered(post)
+    return post, nil
+}
+
+// GetPostBySlug looks a post up by its current slug. If slug was retired by
+// a rename, the post is not returned; instead redirectSlug holds its current
+// slug.
+func (s *PostService) GetPostBySlug(slug string, includeUnpublished bool) (post *models.Post, redirectSlug string, err error) {
+    post, err = s.repo.GetBySlug(slug)
+    if err != nil {
+        return nil, "", err
+    }
+    if post == nil {
+        targetID, err := s.redirects.Resolve(models.EntityPost, slug)
+        if err != nil || targetID == "" {
+            return nil, "", err
+        }
+        if post, err = s.repo.GetByID(targetID); err != nil || post == nil {
+            return nil, "", err
+        }
+        if post.Slug != slug {
+            redirectSlug = post.Slug
+        }
+    }
+    if !includeUnpublished && !post.IsVisible(time.Now()) {
+        return nil, "", nil
+    }
+    if redirectSlug != "" {
+        return nil, redirectSlug, nil
+    }
+    ensureRendered(post)
+    return post, "", nil
+}
+
+// GetPostLocales lists the locales a post can be read in, or returns nil
+// like GetPost.
+func (s *PostService) GetPostLocales(id string, includeUnpublished bool) (*models.LocaleSet, error) {
+    post, err := s.GetPost(id, includeUnpublished)
+    if err != nil || post == nil {
+        return nil, err
+    }
+    return localeSet(s.locales, post.Translations), nil
+}
+
+// LocalizePost presents post in the locale negotiated from prefs and
+// returns that locale. The translations are left for the caller to drop.
+func (s *PostService) LocalizePost(post *models.Post, prefs []string) string {
+    locale, t, ok := pickTranslation(s.locales, prefs, post.Translations)
+    if ok {
+        post.Title = t.Title
+        post.Content = t.Content
+        post.ContentHTML = t.ContentHTML
+        post.TOC = t.TOC
+        post.ReadingTime = t.ReadingTime
+    }
+    post.Locale = locale
+    return locale
+}
+
+// CreatePost stores a new post on behalf of actor.
+func (s *PostService) CreatePost(post *models.Post, actor models.Actor) error {
+    if err := validate.Struct(post); err != nil {
+        return err
+    }
+    if err := checkLocales(s.locales, post.Locale, post.Translations); err != nil {
+        return err
+    }
+    if err := normalizePostStatus(post, time.Now()); err != nil {
+        return err
+    }
+    if err := assignID(&post.ID, s.allowClientIDs); err != nil {
+        return err
+    }
+    slug, err := resolveSlug(slugChange{
+        entityType: models.EntityPost,
+        id:         post.ID,
+        title:      post.Title,
+        requested:  post.Slug,
+    }, s.repo.SlugTaken, s.redirects)
+    if err != nil {
+        return err
+    }
+    post.Slug = slug
+    post.Locale = ""
+    renderPost(post)
+    post.CreatedAt = timestamp()
+    post.UpdatedAt = post.CreatedAt
+    post.Version = 1
+    post.DeletedAt = nil
+    if err := s.repo.Create(post); err != nil {
+        return err
+    }
+    s.history.record(post.ID, post.Version, actor.UserID, post.UpdatedAt, post)
+    s.audit.Record(actor, models.AuditCreate, models.EntityPost, post.ID, nil, post)
+    return nil
+}
+
+// UpdatePost replaces the stored post on behalf of actor if it is at a
+// version ifMatch allows.
+func (s *PostService) UpdatePost(post *models.Post, ifMatch models.IfMatch, actor models.Actor) error {
+    return s.update(post, ifMatch, actor, models.AuditUpdate)
+}
+
+// update saves post over the stored post, recording the change as action.
+func (s *PostService) update(post *models.Post, ifMatch models.IfMatch, actor models.Actor, action string) error {
+    current, err := s.repo.GetByID(post.ID)
+    if err != nil {
+        return err
+    }
+    if current == nil {
+        return apperr.NotFound("post not found")
+    }
+    if !ifMatch.Allows(current.Version) {
+        return ErrVersionMismatch
+    }
+    if err := s.prepareUpdate(post, current); err != nil {
+        return err
+    }
+    if err := s.repo.Update(post); err != nil {
+        return err
+    }
+    s.history.record(post.ID, post.Version, actor.UserID, post.UpdatedAt, post)
+    s.audit.Record(actor, action, models.EntityPost, post.ID, current, post)
+    return nil
+}
+
+// PatchPost applies patch to the stored post and saves the result in one
+// transaction. The patched post is checked like an update.
+func (s *PostService) PatchPost(id string, ifMatch models.IfMatch, actor models.Actor, patch func(*models.Post) error) (*models.Post, error) {
+    var before models.Post
+    post, err := s.repo.Modify(id, func(post *models.Post) error {
+        before = *post
+        if !ifMatch.Allows(before.Version) {
+            return ErrVersionMismatch
+        }
+        if err := patch(post); err != nil {
+            return err
+        }
+        post.ID = id
+        return s.prepareUpdate(post, &before)
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.history.record(post.ID, post.Version, actor.UserID, post.UpdatedAt, post)
+    s.audit.Record(actor, models.AuditUpdate, models.EntityPost, post.ID, &before, post)
+    return post, nil
 }
 
-func NewPostService(repo PostRepo) *PostService {
-    return &PostService{repo: repo}
+// prepareUpdate validates post and fills in what the server derives,
+// given the post as currently stored.
+func (s *PostService) prepareUpdate(post, current *models.Post) error {
+    if err := validate.Struct(post); err != nil {
+        return err
+    }
+    if err := checkLocales(s.locales, post.Locale, post.Translations); err != nil {
+        return err
+    }
+    if err := normalizePostStatus(post, time.Now()); err != nil {
+        return err
+    }
+    slug, err := resolveSlug(slugChange{
+        entityType:   models.EntityPost,
+        id:           post.ID,
+        title:        post.Title,
+        requested:    post.Slug,
+        currentTitle: current.Title,
+        currentSlug:  current.Slug,
+    }, s.repo.SlugTaken, s.redirects)
+    if err != nil {
+        return err
+    }
+    post.Slug = slug
+    post.Locale = ""
+    // Translations left out of the request are kept; {} removes them.
+    if post.Translations == nil {
+        post.Translations = current.Translations
+    }
+    post.CreatedAt = current.CreatedAt
+    post.UpdatedAt = timestamp()
+    renderPost(post)
+    post.Version = current.Version
+    post.DeletedAt = current.DeletedAt
+    return nil
 }
 
-func (s *PostService) ListPosts() ([]models.Post, error) {
-    return s.repo.List()
+// DeletePost moves the post to the trash on behalf of actor if it is at a
+// version ifMatch allows.
+func (s *PostService) DeletePost(id string, ifMatch models.IfMatch, actor models.Actor) error {
+    current, err := s.repo.GetByID(id)
+    if err != nil {
+        return err
+    }
+    if current == nil {
+        return apperr.NotFound("post not found")
+    }
+    if !ifMatch.Allows(current.Version) {
+        return ErrVersionMismatch
+    }
+    if err := s.repo.Delete(id, current.Version); err != nil {
+        return err
+    }
+    s.audit.Record(actor, models.AuditDelete, models.EntityPost, id, current, nil)
+    return nil
 }
 
-func (s *PostService) GetPost(id string) (*models.Post, error) {
-    return s.repo.GetByID(id)
+// ListPostRevisions lists the saved versions of a post, newest first.
+func (s *PostService) ListPostRevisions(id string) ([]models.Revision, error) {
+    if err := s.requirePost(id); err != nil {
+        return nil, err
+    }
+    return s.history.list(id)
 }
 
-func (s *PostService) CreatePost(post *models.Post) error {
-    return s.repo.Create(post)
+// GetPostRevision returns the post as saved at version.
+func (s *PostService) GetPostRevision(id string, version int) (*models.Revision, error) {
+    if err := s.requirePost(id); err != nil {
+        return nil, err
+    }
+    return s.history.get(id, version)
 }
 
-func (s *PostService) UpdatePost(post *models.Post) error {
-    return s.repo.Update(post)
+// DiffPostRevisions compares the post as saved at two versions.
+func (s *PostService) DiffPostRevisions(id string, from, to int) (*models.RevisionDiff, error) {
+    if err := s.requirePost(id); err != nil {
+        return nil, err
+    }
+    return s.history.diff(id, from, to)
 }
 
-func (s *PostService) DeletePost(id string) error {
-    return s.repo.Delete(id)
-}
\ No newline at end of file
+// RollbackPost saves the post as it was at version as a new edit, so the
+// rollback itself shows up in the history.
+func (s *PostService) RollbackPost(id string, version int, ifMatch models.IfMatch, actor models.Actor) (*models.Post, error) {
+    if err := s.requirePost(id); err != nil {
+        return nil, err
+    }
+    var post models.Post
+    if err := s.history.restore(id, version, &post); err != nil {
+        return nil, err
+    }
+    post.ID = id
+    if err := s.update(&post, ifMatch, actor, models.AuditRollback); err != nil {
+        return nil, err
+    }
+    return &post, nil
+}
+
+func (s *PostService) requirePost(id string) error {
+    post, err := s.repo.GetByID(id)
+    if err != nil {
+        return err
+    }
+    if post == nil {
+        return apperr.NotFound("post not found")
+    }
+    return nil
+}
+
+// RestorePost takes the post out of the trash on behalf of actor.
+func (s *PostService) RestorePost(id string, actor models.Actor) (*models.Post, error) {
+    post, err := s.repo.Restore(id)
+    if err != nil {
+        return nil, err
+    }
+    ensureRendered(post)
+    s.audit.Record(actor, models.AuditRestore, models.EntityPost, id, nil, post)
+    return post, nil
+}
+
+// ListTrash lists the posts in the trash.
+func (s *PostService) ListTrash() ([]models.TrashItem, error) {
+    items, err := s.repo.ListDeleted()
+    if err != nil {
+        return nil, err
+    }
+    trash := make([]models.TrashItem, 0, len(items))
+    for _, item := range items {
+        trash = append(trash, models.TrashItem{Type: models.EntityPost, ID: item.ID, Title: item.Title, DeletedAt: *item.DeletedAt})
+    }
+    return trash, nil
+}
+
+// PurgeTrash permanently deletes posts moved to the trash before before.
+func (s *PostService) PurgeTrash(before time.Time) (int, error) {
+    return s.repo.Purge(before)
+}
+
+// RunScheduler publishes due scheduled posts every interval until ctx is done.
+func (s *PostService) RunScheduler(ctx context.Context, interval time.Duration) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        if n, err := s.repo.PublishDue(time.Now()); err != nil {
+            log.Printf("post scheduler: %v", err)
+        } else if n > 0 {
+            log.Printf("post scheduler: published %d post(s)", n)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// renderPost renders the Markdown content of the post and its translations
+// into the cached HTML, table of contents and reading time. Anything the
+// client sent for them is replaced.
+func renderPost(post *models.Post) {
+    post.ContentHTML, post.TOC, post.ReadingTime = renderContent(post.Content)
+    if post.Translations == nil {
+        return
+    }
+    translations := make(map[string]models.PostTranslation, len(post.Translations))
+    for locale, t := range post.Translations {
+        t.ContentHTML, t.TOC, t.ReadingTime = renderContent(t.Content)
+        translations[locale] = t
+    }
+    post.Translations = translations
+}
+
+func renderContent(content string) (html string, toc []models.TOCEntry, readingTime int) {
+    res := markdown.Render(content)
+    toc = make([]models.TOCEntry, 0, len(res.TOC))
+    for _, h := range res.TOC {
+        toc = append(toc, models.TOCEntry{Level: h.Level, ID: h.ID, Text: h.Text})
+    }
+    return res.HTML, toc, markdown.ReadingTime(res.Words)
+}
+
+// ensureRendered renders posts stored before rendering existed. They are
+// rendered on every read until they are next saved.
+func ensureRendered(post *models.Post) {
+    if post.ContentHTML == "" && post.Content != "" {
+        renderPost(post)
+    }
+    if post.TOC == nil {
+        post.TOC = []models.TOCEntry{}
+    }
+    if len(post.Translations) == 0 {
+        return
+    }
+    // Copy the map: post may share it with a cached item.
+    translations := make(map[string]models.PostTranslation, len(post.Translations))
+    for locale, t := range post.Translations {
+        if t.ContentHTML == "" && t.Content != "" {
+            t.ContentHTML, t.TOC, t.ReadingTime = renderContent(t.Content)
+        }
+        if t.TOC == nil {
+            t.TOC = []models.TOCEntry{}
+        }
+        translations[locale] = t
+    }
+    post.Translations = translations
+}
+
+// normalizePostStatus fills in workflow defaults. A post without a status is
+// published if it has a publish time and a draft otherwise; publishing in the
+// future schedules the post, and scheduling in the past publishes it.
+func normalizePostStatus(post *models.Post, now time.Time) error {
+    if post.Status == "" {
+        post.Status = models.PostStatusDraft
+        if post.PublishedAt != nil {
+            post.Status = models.PostStatusPublished
+        }
+    }
+    if !models.ValidPostStatus(post.Status) {
+        return ErrInvalidPostStatus
+    }
+
+    switch post.Status {
+    case models.PostStatusPublished:
+        if post.PublishedAt == nil {
+            post.PublishedAt = &now
+        } else if post.PublishedAt.After(now) {
+            post.Status = models.PostStatusScheduled
+        }
+    case models.PostStatusScheduled:
+        if post.PublishedAt == nil {
+            return ErrScheduledWithoutAt
+        }
+        if !post.PublishedAt.After(now) {
+            post.Status = models.PostStatusPublished
+        }
+    }
+    return nil
+}
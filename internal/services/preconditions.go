@@ -0,0 +1,7 @@
+package services
+
+import "github.com/ScriptVandal/backend-go/internal/apperr"
+
+// ErrVersionMismatch is returned when a write's If-Match names a version
+// other than the stored one.
+var ErrVersionMismatch = apperr.New(apperr.CodePreconditionFailed, "If-Match does not match the current version")
@@ -1,39 +1,313 @@
 package services
 
-import "github.com/ScriptVandal/backend-go/internal/models"
+import (
+    "time"
+
+    "github.com/ScriptVandal/backend-go/internal/apperr"
+    "github.com/ScriptVandal/backend-go/internal/i18n"
+    "github.com/ScriptVandal/backend-go/internal/models"
+    "github.com/ScriptVandal/backend-go/internal/validate"
+)
 
 type ProjectRepo interface {
     List() ([]models.Project, error)
+    ListPage(q models.ListQuery) (*models.Page[models.Project], error)
     GetByID(id string) (*models.Project, error)
+    GetBySlug(slug string) (*models.Project, error)
+    SlugTaken(slug, exceptID string) (bool, error)
     Create(project *models.Project) error
     Update(project *models.Project) error
-    Delete(id string) error
+    Modify(id string, fn func(*models.Project) error) (*models.Project, error)
+    Delete(id string, version int) error
+    Restore(id string) (*models.Project, error)
+    ListDeleted() ([]models.Project, error)
+    Purge(before time.Time) (int, error)
 }
 
 type ProjectService struct {
-    repo ProjectRepo
+    repo           ProjectRepo
+    redirects      SlugRedirectRepo
+    history        history
+    audit          *AuditService
+    locales        i18n.Locales
+    allowClientIDs bool
 }
 
-func NewProjectService(repo ProjectRepo) *ProjectService {
-    return &ProjectService{repo: repo}
+func NewProjectService(repo ProjectRepo, redirects SlugRedirectRepo, revisions RevisionRepo, audit *AuditService, locales i18n.Locales, allowClientIDs bool) *ProjectService {
+    return &ProjectService{
+        repo:           repo,
+        redirects:      redirects,
+        history:        history{repo: revisions, entityType: models.EntityProject},
+        audit:          audit,
+        locales:        locales,
+        allowClientIDs: allowClientIDs,
+    }
 }
 
-func (s *ProjectService) ListProjects() ([]models.Project, error) {
-    return s.repo.List()
+func (s *ProjectService) ListProjects(q models.ListQuery) (*models.Page[models.Project], error) {
+    return s.repo.ListPage(q)
 }
 
 func (s *ProjectService) GetProject(id string) (*models.Project, error) {
     return s.repo.GetByID(id)
 }
 
-func (s *ProjectService) CreateProject(project *models.Project) error {
-    return s.repo.Create(project)
+// GetProjectBySlug looks a project up by its current slug. If slug was
+// retired by a rename, the project is not returned; instead redirectSlug
+// holds its current slug.
+func (s *ProjectService) GetProjectBySlug(slug string) (project *models.Project, redirectSlug string, err error) {
+    project, err = s.repo.GetBySlug(slug)
+    if err != nil || project != nil {
+        return project, "", err
+    }
+    targetID, err := s.redirects.Resolve(models.EntityProject, slug)
+    if err != nil || targetID == "" {
+        return nil, "", err
+    }
+    project, err = s.repo.GetByID(targetID)
+    if err != nil || project == nil {
+        return nil, "", err
+    }
+    return nil, project.Slug, nil
+}
+
+// GetProjectLocales lists the locales a project can be read in, or returns
+// nil if it does not exist.
+func (s *ProjectService) GetProjectLocales(id string) (*models.LocaleSet, error) {
+    project, err := s.repo.GetByID(id)
+    if err != nil || project == nil {
+        return nil, err
+    }
+    return localeSet(s.locales, project.Translations), nil
+}
+
+// LocalizeProject presents project in the locale negotiated from prefs and
+// returns that locale. The translations are left for the caller to drop.
+func (s *ProjectService) LocalizeProject(project *models.Project, prefs []string) string {
+    locale, t, ok := pickTranslation(s.locales, prefs, project.Translations)
+    if ok {
+        project.Title = t.Title
+        project.Description = t.Description
+    }
+    project.Locale = locale
+    return locale
+}
+
+// CreateProject stores a new project on behalf of actor.
+func (s *ProjectService) CreateProject(project *models.Project, actor models.Actor) error {
+    if err := validate.Struct(project); err != nil {
+        return err
+    }
+    if err := checkLocales(s.locales, project.Locale, project.Translations); err != nil {
+        return err
+    }
+    if err := assignID(&project.ID, s.allowClientIDs); err != nil {
+        return err
+    }
+    slug, err := resolveSlug(slugChange{
+        entityType: models.EntityProject,
+        id:         project.ID,
+        title:      project.Title,
+        requested:  project.Slug,
+    }, s.repo.SlugTaken, s.redirects)
+    if err != nil {
+        return err
+    }
+    project.Slug = slug
+    project.Locale = ""
+    project.CreatedAt = timestamp()
+    project.UpdatedAt = project.CreatedAt
+    project.Version = 1
+    project.DeletedAt = nil
+    if err := s.repo.Create(project); err != nil {
+        return err
+    }
+    s.history.record(project.ID, project.Version, actor.UserID, project.UpdatedAt, project)
+    s.audit.Record(actor, models.AuditCreate, models.EntityProject, project.ID, nil, project)
+    return nil
+}
+
+// UpdateProject replaces the stored project on behalf of actor if it is at a
+// version ifMatch allows.
+func (s *ProjectService) UpdateProject(project *models.Project, ifMatch models.IfMatch, actor models.Actor) error {
+    return s.update(project, ifMatch, actor, models.AuditUpdate)
+}
+
+// update saves project over the stored project, recording the change as action.
+func (s *ProjectService) update(project *models.Project, ifMatch models.IfMatch, actor models.Actor, action string) error {
+    current, err := s.repo.GetByID(project.ID)
+    if err != nil {
+        return err
+    }
+    if current == nil {
+        return apperr.NotFound("project not found")
+    }
+    if !ifMatch.Allows(current.Version) {
+        return ErrVersionMismatch
+    }
+    if err := s.prepareUpdate(project, current); err != nil {
+        return err
+    }
+    if err := s.repo.Update(project); err != nil {
+        return err
+    }
+    s.history.record(project.ID, project.Version, actor.UserID, project.UpdatedAt, project)
+    s.audit.Record(actor, action, models.EntityProject, project.ID, current, project)
+    return nil
+}
+
+// PatchProject applies patch to the stored project and saves the result in one
+// transaction. The patched project is checked like an update.
+func (s *ProjectService) PatchProject(id string, ifMatch models.IfMatch, actor models.Actor, patch func(*models.Project) error) (*models.Project, error) {
+    var before models.Project
+    project, err := s.repo.Modify(id, func(project *models.Project) error {
+        before = *project
+        if !ifMatch.Allows(before.Version) {
+            return ErrVersionMismatch
+        }
+        if err := patch(project); err != nil {
+            return err
+        }
+        project.ID = id
+        return s.prepareUpdate(project, &before)
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.history.record(project.ID, project.Version, actor.UserID, project.UpdatedAt, project)
+    s.audit.Record(actor, models.AuditUpdate, models.EntityProject, project.ID, &before, project)
+    return project, nil
+}
+
+// prepareUpdate validates project and fills in what the server derives,
+// given the project as currently stored.
+func (s *ProjectService) prepareUpdate(project, current *models.Project) error {
+    if err := validate.Struct(project); err != nil {
+        return err
+    }
+    if err := checkLocales(s.locales, project.Locale, project.Translations); err != nil {
+        return err
+    }
+    slug, err := resolveSlug(slugChange{
+        entityType:   models.EntityProject,
+        id:           project.ID,
+        title:        project.Title,
+        requested:    project.Slug,
+        currentTitle: current.Title,
+        currentSlug:  current.Slug,
+    }, s.repo.SlugTaken, s.redirects)
+    if err != nil {
+        return err
+    }
+    project.Slug = slug
+    project.Locale = ""
+    // Translations left out of the request are kept; {} removes them.
+    if project.Translations == nil {
+        project.Translations = current.Translations
+    }
+    project.CreatedAt = current.CreatedAt
+    project.UpdatedAt = timestamp()
+    project.Version = current.Version
+    project.DeletedAt = current.DeletedAt
+    return nil
+}
+
+// DeleteProject moves the project to the trash on behalf of actor if it is at a
+// version ifMatch allows.
+func (s *ProjectService) DeleteProject(id string, ifMatch models.IfMatch, actor models.Actor) error {
+    current, err := s.repo.GetByID(id)
+    if err != nil {
+        return err
+    }
+    if current == nil {
+        return apperr.NotFound("project not found")
+    }
+    if !ifMatch.Allows(current.Version) {
+        return ErrVersionMismatch
+    }
+    if err := s.repo.Delete(id, current.Version); err != nil {
+        return err
+    }
+    s.audit.Record(actor, models.AuditDelete, models.EntityProject, id, current, nil)
+    return nil
+}
+
+// ListProjectRevisions lists the saved versions of a project, newest first.
+func (s *ProjectService) ListProjectRevisions(id string) ([]models.Revision, error) {
+    if err := s.requireProject(id); err != nil {
+        return nil, err
+    }
+    return s.history.list(id)
+}
+
+// GetProjectRevision returns the project as saved at version.
+func (s *ProjectService) GetProjectRevision(id string, version int) (*models.Revision, error) {
+    if err := s.requireProject(id); err != nil {
+        return nil, err
+    }
+    return s.history.get(id, version)
+}
+
+// DiffProjectRevisions compares the project as saved at two versions.
+func (s *ProjectService) DiffProjectRevisions(id string, from, to int) (*models.RevisionDiff, error) {
+    if err := s.requireProject(id); err != nil {
+        return nil, err
+    }
+    return s.history.diff(id, from, to)
+}
+
+// RollbackProject saves the project as it was at version as a new edit, so the
+// rollback itself shows up in the history.
+func (s *ProjectService) RollbackProject(id string, version int, ifMatch models.IfMatch, actor models.Actor) (*models.Project, error) {
+    if err := s.requireProject(id); err != nil {
+        return nil, err
+    }
+    var project models.Project
+    if err := s.history.restore(id, version, &project); err != nil {
+        return nil, err
+    }
+    project.ID = id
+    if err := s.update(&project, ifMatch, actor, models.AuditRollback); err != nil {
+        return nil, err
+    }
+    return &project, nil
+}
+
+func (s *ProjectService) requireProject(id string) error {
+    project, err := s.repo.GetByID(id)
+    if err != nil {
+        return err
+    }
+    if project == nil {
+        return apperr.NotFound("project not found")
+    }
+    return nil
+}
+
+// RestoreProject takes the project out of the trash on behalf of actor.
+func (s *ProjectService) RestoreProject(id string, actor models.Actor) (*models.Project, error) {
+    project, err := s.repo.Restore(id)
+    if err != nil {
+        return nil, err
+    }
+    s.audit.Record(actor, models.AuditRestore, models.EntityProject, id, nil, project)
+    return project, nil
 }
 
-func (s *ProjectService) UpdateProject(project *models.Project) error {
-    return s.repo.Update(project)
+// ListTrash lists the projects in the trash.
+func (s *ProjectService) ListTrash() ([]models.TrashItem, error) {
+    items, err := s.repo.ListDeleted()
+    if err != nil {
+        return nil, err
+    }
+    trash := make([]models.TrashItem, 0, len(items))
+    for _, item := range items {
+        trash = append(trash, models.TrashItem{Type: models.EntityProject, ID: item.ID, Title: item.Title, DeletedAt: *item.DeletedAt})
+    }
+    return trash, nil
 }
 
-func (s *ProjectService) DeleteProject(id string) error {
-    return s.repo.Delete(id)
+// PurgeTrash permanently deletes projects moved to the trash before before.
+func (s *ProjectService) PurgeTrash(before time.Time) (int, error) {
+    return s.repo.Purge(before)
 }
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+var ErrRevisionNotFound = apperr.NotFound("revision not found")
+
+type RevisionRepo interface {
+	Add(rev *models.Revision) error
+	List(entityType, entityID string) ([]models.Revision, error)
+	Get(entityType, entityID string, version int) (*models.Revision, error)
+}
+
+// history records and reads the revisions of one entity type.
+type history struct {
+	repo       RevisionRepo
+	entityType string
+}
+
+// record stores item as saved at version. The item itself is already saved
+// by then, so a failure is logged rather than failing the request.
+func (h history) record(id string, version int, author string, at time.Time, item any) {
+	snapshot, err := json.Marshal(item)
+	if err == nil {
+		err = h.repo.Add(&models.Revision{
+			EntityType: h.entityType,
+			EntityID:   id,
+			Version:    version,
+			AuthorID:   author,
+			CreatedAt:  at,
+			Snapshot:   snapshot,
+		})
+	}
+	if err != nil {
+		log.Printf("%s %s: recording revision %d: %v", h.entityType, id, version, err)
+	}
+}
+
+func (h history) list(id string) ([]models.Revision, error) {
+	return h.repo.List(h.entityType, id)
+}
+
+func (h history) get(id string, version int) (*models.Revision, error) {
+	rev, err := h.repo.Get(h.entityType, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if rev == nil {
+		return nil, ErrRevisionNotFound
+	}
+	return rev, nil
+}
+
+// restore decodes the snapshot of a revision into item, ready to be saved
+// again as a new version.
+func (h history) restore(id string, version int, item any) error {
+	rev, err := h.get(id, version)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(rev.Snapshot, item)
+}
+
+// diff compares the top-level fields of two revisions.
+func (h history) diff(id string, from, to int) (*models.RevisionDiff, error) {
+	a, err := h.get(id, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := h.get(id, to)
+	if err != nil {
+		return nil, err
+	}
+	var before, after map[string]json.RawMessage
+	if err := json.Unmarshal(a.Snapshot, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b.Snapshot, &after); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(before)+len(after))
+	for field := range before {
+		fields = append(fields, field)
+	}
+	for field := range after {
+		if _, ok := before[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	d := &models.RevisionDiff{From: from, To: to, Changes: []models.FieldChange{}}
+	for _, field := range fields {
+		if !sameJSON(before[field], after[field]) {
+			d.Changes = append(d.Changes, models.FieldChange{Field: field, From: before[field], To: after[field]})
+		}
+	}
+	return d, nil
+}
+
+// sameJSON reports whether two JSON values are equal, ignoring formatting
+// and member order.
+func sameJSON(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
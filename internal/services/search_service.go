@@ -0,0 +1,56 @@
+package services
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	maxSearchQueryLen  = 200
+)
+
+var ErrEmptySearchQuery = apperr.Validation(apperr.Field("q", "is required"))
+
+type SearchRepo interface {
+	Search(query string, limit int) ([]models.SearchResult, error)
+}
+
+type SearchService struct {
+	repo SearchRepo
+}
+
+func NewSearchService(repo SearchRepo) *SearchService {
+	return &SearchService{repo: repo}
+}
+
+// Search returns posts and projects matching query, most relevant first.
+func (s *SearchService) Search(query string, limit int) ([]models.SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	query = truncate(query, maxSearchQueryLen)
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	return s.repo.Search(query, limit)
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
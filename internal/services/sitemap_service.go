@@ -0,0 +1,21 @@
+package services
+
+import "github.com/ScriptVandal/backend-go/internal/models"
+
+type SitemapRepo interface {
+	Entries() ([]models.SitemapEntry, error)
+}
+
+type SitemapService struct {
+	repo SitemapRepo
+}
+
+func NewSitemapService(repo SitemapRepo) *SitemapService {
+	return &SitemapService{repo: repo}
+}
+
+// Entries returns every published post and project, ordered by type and ID
+// so that sitemap pages stay stable between requests.
+func (s *SitemapService) Entries() ([]models.SitemapEntry, error) {
+	return s.repo.Entries()
+}
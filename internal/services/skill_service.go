@@ -1,39 +1,206 @@
 package services
 
-import "github.com/ScriptVandal/backend-go/internal/models"
+import (
+    "time"
+
+    "github.com/ScriptVandal/backend-go/internal/apperr"
+    "github.com/ScriptVandal/backend-go/internal/i18n"
+    "github.com/ScriptVandal/backend-go/internal/models"
+    "github.com/ScriptVandal/backend-go/internal/validate"
+)
 
 type SkillRepo interface {
     List() ([]models.Skill, error)
+    ListPage(q models.ListQuery) (*models.Page[models.Skill], error)
     GetByID(id string) (*models.Skill, error)
     Create(skill *models.Skill) error
     Update(skill *models.Skill) error
-    Delete(id string) error
+    Modify(id string, fn func(*models.Skill) error) (*models.Skill, error)
+    Delete(id string, version int) error
+    Restore(id string) (*models.Skill, error)
+    ListDeleted() ([]models.Skill, error)
+    Purge(before time.Time) (int, error)
 }
 
 type SkillService struct {
-    repo SkillRepo
+    repo           SkillRepo
+    audit          *AuditService
+    locales        i18n.Locales
+    allowClientIDs bool
 }
 
-func NewSkillService(repo SkillRepo) *SkillService {
-    return &SkillService{repo: repo}
+func NewSkillService(repo SkillRepo, audit *AuditService, locales i18n.Locales, allowClientIDs bool) *SkillService {
+    return &SkillService{repo: repo, audit: audit, locales: locales, allowClientIDs: allowClientIDs}
 }
 
-func (s *SkillService) ListSkills() ([]models.Skill, error) {
-    return s.repo.List()
+func (s *SkillService) ListSkills(q models.ListQuery) (*models.Page[models.Skill], error) {
+    return s.repo.ListPage(q)
 }
 
 func (s *SkillService) GetSkill(id string) (*models.Skill, error) {
     return s.repo.GetByID(id)
 }
 
-func (s *SkillService) CreateSkill(skill *models.Skill) error {
-    return s.repo.Create(skill)
+// GetSkillLocales lists the locales a skill can be read in, or returns nil
+// if it does not exist.
+func (s *SkillService) GetSkillLocales(id string) (*models.LocaleSet, error) {
+    skill, err := s.repo.GetByID(id)
+    if err != nil || skill == nil {
+        return nil, err
+    }
+    return localeSet(s.locales, skill.Translations), nil
+}
+
+// LocalizeSkill presents skill in the locale negotiated from prefs and
+// returns that locale. The translations are left for the caller to drop.
+func (s *SkillService) LocalizeSkill(skill *models.Skill, prefs []string) string {
+    locale, t, ok := pickTranslation(s.locales, prefs, skill.Translations)
+    if ok {
+        skill.Name = t.Name
+        skill.Category = t.Category
+    }
+    skill.Locale = locale
+    return locale
+}
+
+// CreateSkill stores a new skill on behalf of actor.
+func (s *SkillService) CreateSkill(skill *models.Skill, actor models.Actor) error {
+    if err := validate.Struct(skill); err != nil {
+        return err
+    }
+    if err := checkLocales(s.locales, skill.Locale, skill.Translations); err != nil {
+        return err
+    }
+    if err := assignID(&skill.ID, s.allowClientIDs); err != nil {
+        return err
+    }
+    skill.Locale = ""
+    skill.CreatedAt = timestamp()
+    skill.UpdatedAt = skill.CreatedAt
+    skill.Version = 1
+    skill.DeletedAt = nil
+    if err := s.repo.Create(skill); err != nil {
+        return err
+    }
+    s.audit.Record(actor, models.AuditCreate, models.EntitySkill, skill.ID, nil, skill)
+    return nil
+}
+
+// UpdateSkill replaces the stored skill on behalf of actor if it is at a
+// version ifMatch allows.
+func (s *SkillService) UpdateSkill(skill *models.Skill, ifMatch models.IfMatch, actor models.Actor) error {
+    return s.update(skill, ifMatch, actor, models.AuditUpdate)
+}
+
+// update saves skill over the stored skill, recording the change as action.
+func (s *SkillService) update(skill *models.Skill, ifMatch models.IfMatch, actor models.Actor, action string) error {
+    current, err := s.repo.GetByID(skill.ID)
+    if err != nil {
+        return err
+    }
+    if current == nil {
+        return apperr.NotFound("skill not found")
+    }
+    if !ifMatch.Allows(current.Version) {
+        return ErrVersionMismatch
+    }
+    if err := s.prepareUpdate(skill, current); err != nil {
+        return err
+    }
+    if err := s.repo.Update(skill); err != nil {
+        return err
+    }
+    s.audit.Record(actor, action, models.EntitySkill, skill.ID, current, skill)
+    return nil
+}
+
+// PatchSkill applies patch to the stored skill and saves the result in one
+// transaction. The patched skill is checked like an update.
+func (s *SkillService) PatchSkill(id string, ifMatch models.IfMatch, actor models.Actor, patch func(*models.Skill) error) (*models.Skill, error) {
+    var before models.Skill
+    skill, err := s.repo.Modify(id, func(skill *models.Skill) error {
+        before = *skill
+        if !ifMatch.Allows(before.Version) {
+            return ErrVersionMismatch
+        }
+        if err := patch(skill); err != nil {
+            return err
+        }
+        skill.ID = id
+        return s.prepareUpdate(skill, &before)
+    })
+    if err != nil {
+        return nil, err
+    }
+    s.audit.Record(actor, models.AuditUpdate, models.EntitySkill, skill.ID, &before, skill)
+    return skill, nil
+}
+
+// prepareUpdate validates skill and fills in what the server derives,
+// given the skill as currently stored.
+func (s *SkillService) prepareUpdate(skill, current *models.Skill) error {
+    if err := validate.Struct(skill); err != nil {
+        return err
+    }
+    if err := checkLocales(s.locales, skill.Locale, skill.Translations); err != nil {
+        return err
+    }
+    skill.Locale = ""
+    // Translations left out of the request are kept; {} removes them.
+    if skill.Translations == nil {
+        skill.Translations = current.Translations
+    }
+    skill.UpdatedAt = timestamp()
+    skill.CreatedAt = current.CreatedAt
+    skill.Version = current.Version
+    skill.DeletedAt = current.DeletedAt
+    return nil
+}
+
+// DeleteSkill moves the skill to the trash on behalf of actor if it is at a
+// version ifMatch allows.
+func (s *SkillService) DeleteSkill(id string, ifMatch models.IfMatch, actor models.Actor) error {
+    current, err := s.repo.GetByID(id)
+    if err != nil {
+        return err
+    }
+    if current == nil {
+        return apperr.NotFound("skill not found")
+    }
+    if !ifMatch.Allows(current.Version) {
+        return ErrVersionMismatch
+    }
+    if err := s.repo.Delete(id, current.Version); err != nil {
+        return err
+    }
+    s.audit.Record(actor, models.AuditDelete, models.EntitySkill, id, current, nil)
+    return nil
+}
+
+// RestoreSkill takes the skill out of the trash on behalf of actor.
+func (s *SkillService) RestoreSkill(id string, actor models.Actor) (*models.Skill, error) {
+    skill, err := s.repo.Restore(id)
+    if err != nil {
+        return nil, err
+    }
+    s.audit.Record(actor, models.AuditRestore, models.EntitySkill, id, nil, skill)
+    return skill, nil
 }
 
-func (s *SkillService) UpdateSkill(skill *models.Skill) error {
-    return s.repo.Update(skill)
+// ListTrash lists the skills in the trash.
+func (s *SkillService) ListTrash() ([]models.TrashItem, error) {
+    items, err := s.repo.ListDeleted()
+    if err != nil {
+        return nil, err
+    }
+    trash := make([]models.TrashItem, 0, len(items))
+    for _, item := range items {
+        trash = append(trash, models.TrashItem{Type: models.EntitySkill, ID: item.ID, Title: item.Name, DeletedAt: *item.DeletedAt})
+    }
+    return trash, nil
 }
 
-func (s *SkillService) DeleteSkill(id string) error {
-    return s.repo.Delete(id)
+// PurgeTrash permanently deletes skills moved to the trash before before.
+func (s *SkillService) PurgeTrash(before time.Time) (int, error) {
+    return s.repo.Purge(before)
 }
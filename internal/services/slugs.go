@@ -0,0 +1,80 @@
+package services
+
+import (
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+	"github.com/ScriptVandal/backend-go/internal/slug"
+)
+
+// maxSlugAttempts bounds the search for a free collision suffix.
+const maxSlugAttempts = 1000
+
+var errNoFreeSlug = apperr.Conflict("could not find a free slug")
+
+type SlugRedirectRepo interface {
+	Add(redirect *models.SlugRedirect) error
+	Resolve(entityType, slug string) (string, error)
+}
+
+// slugChange describes the slug an item is saved with.
+type slugChange struct {
+	entityType string
+	id         string
+	// title and requested come from the request; requested may be empty.
+	title     string
+	requested string
+	// currentTitle and currentSlug describe the stored item, if any.
+	currentTitle string
+	currentSlug  string
+}
+
+// resolveSlug picks the slug for an item being saved. A slug the client
+// changed explicitly wins; otherwise the slug is kept while the title stays
+// the same and regenerated from the title when it changes. If the slug
+// changes, the old one is recorded as a redirect to the item.
+func resolveSlug(c slugChange, taken func(slug, exceptID string) (bool, error), redirects SlugRedirectRepo) (string, error) {
+	var base string
+	switch {
+	case c.requested != "" && c.requested != c.currentSlug:
+		base = slug.Make(c.requested)
+	case c.currentSlug != "" && c.title == c.currentTitle:
+		return c.currentSlug, nil
+	}
+	if base == "" {
+		base = slug.Make(c.title)
+	}
+	if base == "" {
+		base = c.entityType
+	}
+	if base == c.currentSlug {
+		return base, nil
+	}
+
+	next, err := uniqueSlug(base, c.id, taken)
+	if err != nil {
+		return "", err
+	}
+	if c.currentSlug != "" && next != c.currentSlug {
+		err := redirects.Add(&models.SlugRedirect{EntityType: c.entityType, Slug: c.currentSlug, TargetID: c.id})
+		if err != nil {
+			return "", err
+		}
+	}
+	return next, nil
+}
+
+// uniqueSlug returns base, or base with the lowest free numeric suffix.
+func uniqueSlug(base, exceptID string, taken func(slug, exceptID string) (bool, error)) (string, error) {
+	candidate := base
+	for n := 2; n < maxSlugAttempts; n++ {
+		used, err := taken(candidate, exceptID)
+		if err != nil {
+			return "", err
+		}
+		if !used {
+			return candidate, nil
+		}
+		candidate = slug.WithSuffix(base, n)
+	}
+	return "", errNoFreeSlug
+}
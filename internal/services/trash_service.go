@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+	"github.com/ScriptVandal/backend-go/internal/models"
+)
+
+var ErrInvalidTrashType = apperr.Validation(apperr.Field("type", "must be one of post, project, skill, contact"))
+
+// TrashBin is implemented by the services of entities that are soft deleted.
+type TrashBin interface {
+	ListTrash() ([]models.TrashItem, error)
+	PurgeTrash(before time.Time) (int, error)
+}
+
+// TrashService lists and purges the trash of several entity types at once.
+type TrashService struct {
+	bins []TrashBin
+}
+
+func NewTrashService(bins ...TrashBin) *TrashService {
+	return &TrashService{bins: bins}
+}
+
+// List returns the items in the trash, most recently deleted first. A
+// non-empty entityType limits the list to one type.
+func (s *TrashService) List(entityType string) ([]models.TrashItem, error) {
+	switch entityType {
+	case "", models.EntityPost, models.EntityProject, models.EntitySkill, models.EntityContact:
+	default:
+		return nil, ErrInvalidTrashType
+	}
+
+	items := []models.TrashItem{}
+	for _, bin := range s.bins {
+		trash, err := bin.ListTrash()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range trash {
+			if entityType == "" || item.Type == entityType {
+				items = append(items, item)
+			}
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+// Purge permanently deletes everything moved to the trash before before and
+// returns how many items were removed.
+func (s *TrashService) Purge(before time.Time) (int, error) {
+	total := 0
+	for _, bin := range s.bins {
+		n, err := bin.PurgeTrash(before)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// RunPurger purges items that have been in the trash longer than retention
+// every interval until ctx is done.
+func (s *TrashService) RunPurger(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.Purge(time.Now().Add(-retention)); err != nil {
+			log.Printf("trash purger: %v", err)
+		} else if n > 0 {
+			log.Printf("trash purger: purged %d item(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Package sitemap encodes sitemaps and sitemap indexes following the
+// sitemaps.org protocol.
+package sitemap
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// MaxURLs is the most URLs a single sitemap may list.
+const MaxURLs = 50000
+
+const (
+	ContentType = "application/xml; charset=utf-8"
+	namespace   = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+// URL is a page or, in an index, a sitemap. LastMod is optional.
+type URL struct {
+	Loc     string
+	LastMod *time.Time
+}
+
+type entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func entries(urls []URL) []entry {
+	out := make([]entry, 0, len(urls))
+	for _, u := range urls {
+		e := entry{Loc: u.Loc}
+		if u.LastMod != nil {
+			e.LastMod = u.LastMod.UTC().Format(time.RFC3339)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// URLSet encodes a sitemap listing urls.
+func URLSet(urls []URL) ([]byte, error) {
+	return encode(struct {
+		XMLName xml.Name `xml:"urlset"`
+		NS      string   `xml:"xmlns,attr"`
+		URLs    []entry  `xml:"url"`
+	}{NS: namespace, URLs: entries(urls)})
+}
+
+// Index encodes a sitemap index pointing at sitemaps.
+func Index(sitemaps []URL) ([]byte, error) {
+	return encode(struct {
+		XMLName  xml.Name `xml:"sitemapindex"`
+		NS       string   `xml:"xmlns,attr"`
+		Sitemaps []entry  `xml:"sitemap"`
+	}{NS: namespace, Sitemaps: entries(sitemaps)})
+}
+
+func encode(doc any) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// LastMod returns the latest modification time among urls, or nil.
+func LastMod(urls []URL) *time.Time {
+	var latest *time.Time
+	for _, u := range urls {
+		if u.LastMod != nil && (latest == nil || u.LastMod.After(*latest)) {
+			latest = u.LastMod
+		}
+	}
+	return latest
+}
@@ -0,0 +1,100 @@
+// Package slug turns titles into URL-friendly identifiers.
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLength is the longest slug Make returns.
+const MaxLength = 80
+
+// translit maps letters to their ASCII spelling. Cyrillic follows the common
+// passport-style transliteration; Latin letters lose their diacritics.
+var translit = map[rune]string{
+	// Russian
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	// Ukrainian and Belarusian
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+	// Latin with diacritics
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a", 'ă': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'œ': "oe", 'ř': "r",
+	'ś': "s", 'š': "s", 'ş': "s", 'ș': "s", 'ß': "ss",
+	'ť': "t", 'ţ': "t", 'ț': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Make builds a slug from s: lower-cased, transliterated to ASCII where a
+// mapping exists, with every other run of non-alphanumerics collapsed into a
+// single hyphen. Letters without a mapping are kept as they are. The result
+// may be empty if s contains no letters or digits.
+func Make(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if t, ok := translit[r]; ok {
+			if t != "" {
+				b.WriteString(t)
+				hyphen = false
+			}
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+	return truncate(strings.TrimSuffix(b.String(), "-"))
+}
+
+// WithSuffix returns base with a numeric collision suffix, keeping the
+// result within MaxLength.
+func WithSuffix(base string, n int) string {
+	suffix := "-" + strconv.Itoa(n)
+	base = strings.TrimSuffix(cutAt(base, MaxLength-len(suffix)), "-")
+	return base + suffix
+}
+
+// truncate shortens s to MaxLength bytes, preferring to cut at a hyphen.
+func truncate(s string) string {
+	if len(s) <= MaxLength {
+		return s
+	}
+	s = cutAt(s, MaxLength)
+	if i := strings.LastIndexByte(s, '-'); i > MaxLength/2 {
+		s = s[:i]
+	}
+	return strings.TrimSuffix(s, "-")
+}
+
+// cutAt shortens s to at most n bytes without splitting a character.
+func cutAt(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,67 @@
+// Package uuid generates version 7 UUIDs (RFC 9562): a millisecond Unix
+// timestamp followed by random bits, so IDs sort by creation time.
+package uuid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+var (
+	mu     sync.Mutex
+	lastMs int64
+	seq    uint16
+)
+
+// NewV7 returns a new UUIDv7 in its canonical 36-character form. IDs made
+// within the same millisecond by this process still sort in order, using
+// the 12-bit rand_a field as a counter seeded from random bits.
+func NewV7() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("uuid: " + err.Error())
+	}
+
+	mu.Lock()
+	ms := time.Now().UnixMilli()
+	if ms > lastMs {
+		lastMs = ms
+		seq = uint16(b[6])<<8 | uint16(b[7])
+		seq &= 0x07ff // leave room to count up within the millisecond
+	} else {
+		ms = lastMs
+		seq++
+		if seq > 0x0fff {
+			// Counter exhausted: borrow the next millisecond.
+			lastMs++
+			ms = lastMs
+			seq = 0
+		}
+	}
+	counter := seq
+	mu.Unlock()
+
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	b[6] = 0x70 | byte(counter>>8) // version 7
+	b[7] = byte(counter)
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
@@ -0,0 +1,175 @@
+// Package validate checks structs against declarative `validate` tags.
+//
+// Rules are comma-separated and applied in order:
+//
+//	required     the value must not be empty (blank strings count as empty)
+//	omitempty    skip the remaining rules when the value is empty
+//	max=N        at most N characters, or N items for slices
+//	min=N        at least N characters, or N items for slices
+//	url          an absolute http or https URL
+//	email        a bare email address
+//	oneof=a b c  one of the listed values
+//	dive         apply the remaining rules to every slice element or map value
+//
+// Struct values, including slice elements and map values, are validated
+// field by field once their own rules pass. Fields are reported under their
+// JSON names, nested ones as "parent.key.field".
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ScriptVandal/backend-go/internal/apperr"
+)
+
+type rule struct {
+	name string
+	arg  string
+}
+
+type field struct {
+	index int
+	name  string
+	rules []rule
+}
+
+var cache sync.Map // reflect.Type -> []field
+
+// Struct validates v, a struct or a pointer to one, and returns an
+// *apperr.Error listing every invalid field, or nil.
+func Struct(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var errs []apperr.FieldError
+	for _, f := range fields(rv.Type()) {
+		errs = check(errs, f.name, rv.Field(f.index), f.rules)
+	}
+	if len(errs) > 0 {
+		return apperr.Validation(errs...)
+	}
+	return nil
+}
+
+func fields(t reflect.Type) []field {
+	if cached, ok := cache.Load(t); ok {
+		return cached.([]field)
+	}
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" {
+			name = sf.Name
+		}
+		f := field{index: i, name: name}
+		for _, spec := range strings.Split(tag, ",") {
+			ruleName, arg, _ := strings.Cut(spec, "=")
+			f.rules = append(f.rules, rule{name: ruleName, arg: arg})
+		}
+		out = append(out, f)
+	}
+	cache.Store(t, out)
+	return out
+}
+
+func check(errs []apperr.FieldError, name string, v reflect.Value, rules []rule) []apperr.FieldError {
+	for i, r := range rules {
+		switch r.name {
+		case "required":
+			if isEmpty(v) {
+				return append(errs, apperr.Field(name, "is required"))
+			}
+		case "omitempty":
+			if isEmpty(v) {
+				return errs
+			}
+		case "dive":
+			if v.Kind() == reflect.Map {
+				keys := v.MapKeys()
+				sort.Slice(keys, func(a, b int) bool { return keys[a].String() < keys[b].String() })
+				for _, k := range keys {
+					errs = check(errs, name+"."+k.String(), v.MapIndex(k), rules[i+1:])
+				}
+				return errs
+			}
+			for j := 0; j < v.Len(); j++ {
+				errs = check(errs, name+"["+strconv.Itoa(j)+"]", v.Index(j), rules[i+1:])
+			}
+			return errs
+		default:
+			if msg := apply(r, v); msg != "" {
+				return append(errs, apperr.Field(name, msg))
+			}
+		}
+	}
+	if v.Kind() == reflect.Struct {
+		for _, f := range fields(v.Type()) {
+			errs = check(errs, name+"."+f.name, v.Field(f.index), f.rules)
+		}
+	}
+	return errs
+}
+
+func isEmpty(v reflect.Value) bool {
+	if v.Kind() == reflect.String {
+		return strings.TrimSpace(v.String()) == ""
+	}
+	return v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0)
+}
+
+// apply checks one rule and returns a message if it fails.
+func apply(r rule, v reflect.Value) string {
+	switch r.name {
+	case "max", "min":
+		n, err := strconv.Atoi(r.arg)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad %s argument %q", r.name, r.arg))
+		}
+		size, unit := length(v)
+		if r.name == "max" && size > n {
+			return fmt.Sprintf("must be at most %d %s", n, unit)
+		}
+		if r.name == "min" && size < n {
+			return fmt.Sprintf("must be at least %d %s", n, unit)
+		}
+	case "url":
+		u, err := url.Parse(v.String())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http or https URL"
+		}
+	case "email":
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Address != v.String() {
+			return "must be a valid email address"
+		}
+	case "oneof":
+		options := strings.Fields(r.arg)
+		for _, option := range options {
+			if v.String() == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(options, ", ")
+	default:
+		panic("validate: unknown rule " + r.name)
+	}
+	return ""
+}
+
+func length(v reflect.Value) (int, string) {
+	if v.Kind() == reflect.String {
+		return utf8.RuneCountInString(v.String()), "characters"
+	}
+	return v.Len(), "items"
+}